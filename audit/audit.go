@@ -0,0 +1,208 @@
+// Package audit reads MongoDB Enterprise audit logs, in either of the formats auditLog.format selects, and filters
+// their events
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// An Event is one audit message (https://docs.mongodb.com/manual/reference/audit-message/)
+type Event struct {
+	AType  string    `bson:"atype"`
+	TS     time.Time `bson:"ts"`
+	Local  Address   `bson:"local"`
+	Remote Address   `bson:"remote"`
+	Users  []User    `bson:"users"`
+	Roles  []User    `bson:"roles"` // roles have the same form as users, with the role name in User
+	Param  bson.Raw  `bson:"param"`
+	Result int       `bson:"result"` // 0 for success, otherwise a MongoDB error code
+}
+
+// An Address is the client or server end of the connection an event happened on
+type Address struct {
+	IP   string `bson:"ip"`
+	Port int    `bson:"port"`
+}
+
+func (a Address) String() string {
+	if a.IP == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", a.IP, a.Port)
+}
+
+// A User authenticated on the connection
+type User struct {
+	User string `bson:"user"`
+	DB   string `bson:"db"`
+}
+
+func (u User) String() string {
+	return u.User + "@" + u.DB
+}
+
+// ParamJSON is the event's parameters as relaxed extended JSON, "{}" if there are none
+func (e *Event) ParamJSON() string {
+	if len(e.Param) == 0 {
+		return "{}"
+	}
+	out, err := bson.MarshalExtJSON(e.Param, false, false)
+	if err != nil {
+		return "{}"
+	}
+	return string(out)
+}
+
+// String is the event on one line: time, action type, users, client, result and parameters
+func (e *Event) String() string {
+	users := make([]string, len(e.Users))
+	for i, u := range e.Users {
+		users[i] = u.String()
+	}
+	who := strings.Join(users, ",")
+	if who == "" {
+		who = "-"
+	}
+	return fmt.Sprintf("%s %-22s %-20s %-21s result=%d %s", e.TS.UTC().Format("2006-01-02T15:04:05.000Z"), e.AType, who,
+		e.Remote, e.Result, e.ParamJSON())
+}
+
+// Read every event from an audit log, JSON (one extended JSON document per line) or BSON (documents back to back)
+// The format is recognized from the first byte, since a BSON document can't start with '{'.
+func Read(r io.Reader) ([]Event, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if first[0] == '{' {
+		return readJSON(br)
+	}
+	return readBSON(br)
+}
+
+// ReadFile reads every event from an audit log file
+func ReadFile(fn string) ([]Event, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	events, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return events, nil
+}
+
+func readJSON(r *bufio.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		err := bson.UnmarshalExtJSON(line, false, &e)
+		if err != nil {
+			return events, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+func readBSON(r io.Reader) ([]Event, error) {
+	var events []Event
+	header := make([]byte, 4)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, fmt.Errorf("event %d: %v", len(events)+1, err)
+		}
+		length := int(binary.LittleEndian.Uint32(header))
+		if length < 5 || length > 16*1024*1024 {
+			return events, fmt.Errorf("event %d: bad BSON document length %d", len(events)+1, length)
+		}
+		doc := make([]byte, length)
+		copy(doc, header)
+		_, err = io.ReadFull(r, doc[4:])
+		if err != nil {
+			// mongod may be in the middle of writing the last event
+			return events, fmt.Errorf("event %d: %v", len(events)+1, err)
+		}
+		var e Event
+		err = bson.Unmarshal(doc, &e)
+		if err != nil {
+			return events, fmt.Errorf("event %d: %v", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+}
+
+// A Filter selects events; empty fields select everything
+type Filter struct {
+	Users  []string // "user" or "user@db"; any authenticated user may match
+	ATypes []string // action types, e.g. "authenticate", "createCollection"
+	Since  time.Time
+	Until  time.Time
+	Failed bool // only events with a nonzero result
+}
+
+// Match reports whether an event passes the filter
+func (f *Filter) Match(e *Event) bool {
+	if !f.Since.IsZero() && e.TS.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.TS.Before(f.Until) {
+		return false
+	}
+	if f.Failed && e.Result == 0 {
+		return false
+	}
+	if len(f.ATypes) > 0 && !contains(f.ATypes, e.AType) {
+		return false
+	}
+	if len(f.Users) == 0 {
+		return true
+	}
+	for _, u := range e.Users {
+		if contains(f.Users, u.User) || contains(f.Users, u.String()) {
+			return true
+		}
+	}
+	// authentication events name the user in their parameters rather than in users
+	if e.AType == "authenticate" {
+		user, _ := e.Param.Lookup("user").StringValueOK()
+		db, _ := e.Param.Lookup("db").StringValueOK()
+		return contains(f.Users, user) || contains(f.Users, user+"@"+db)
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"bytes"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testJSON = `{ "atype" : "authenticate", "ts" : { "$date" : "2021-03-04T10:00:00.000+00:00" }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "127.0.0.1", "port" : 50000 }, "users" : [], "roles" : [], "param" : { "user" : "alice", "db" : "admin", "mechanism" : "SCRAM-SHA-256" }, "result" : 18 }
+{ "atype" : "authenticate", "ts" : { "$date" : "2021-03-04T10:00:01.000+00:00" }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "127.0.0.1", "port" : 50000 }, "users" : [ { "user" : "alice", "db" : "admin" } ], "roles" : [ { "role" : "root", "db" : "admin" } ], "param" : { "user" : "alice", "db" : "admin", "mechanism" : "SCRAM-SHA-256" }, "result" : 0 }
+
+{ "atype" : "createCollection", "ts" : { "$date" : 1614852060000 }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "127.0.0.1", "port" : 50000 }, "users" : [ { "user" : "alice", "db" : "admin" } ], "roles" : [], "param" : { "ns" : "test.orders" }, "result" : 0 }
+{ "atype" : "dropCollection", "ts" : { "$date" : "2021-03-04T11:00:00.000Z" }, "users" : [ { "user" : "bob", "db" : "test" } ], "param" : { "ns" : "test.orders" }, "result" : 0 }
+`
+
+func TestRead(t *testing.T) {
+	events, err := Read(strings.NewReader(testJSON))
+	if err != nil {
+		t.Fatalf("Read(JSON): %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Read(JSON): got %d events, wanted 4", len(events))
+	}
+	if events[2].TS != time.Date(2021, 3, 4, 10, 1, 0, 0, time.UTC) {
+		t.Errorf("Read(JSON): legacy $date got %v", events[2].TS)
+	}
+	if got := events[0].String(); !strings.Contains(got, "authenticate") || !strings.Contains(got, `"mechanism":"SCRAM-SHA-256"`) {
+		t.Errorf("Event.String(): got %s", got)
+	}
+
+	// the same events written as BSON
+	var buf bytes.Buffer
+	for _, e := range events {
+		doc, err := bson.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(doc)
+	}
+	fromBSON, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read(BSON): %v", err)
+	}
+	if len(fromBSON) != len(events) || fromBSON[3].AType != "dropCollection" || fromBSON[3].Users[0].String() != "bob@test" {
+		t.Errorf("Read(BSON): got %v", fromBSON)
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	events, err := Read(strings.NewReader(testJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string // action types of the matching events
+	}{
+		{"everything", Filter{}, []string{"authenticate", "authenticate", "createCollection", "dropCollection"}},
+		{"user", Filter{Users: []string{"alice"}}, []string{"authenticate", "authenticate", "createCollection"}},
+		{"user@db", Filter{Users: []string{"bob@test"}}, []string{"dropCollection"}},
+		{"action types", Filter{ATypes: []string{"createCollection", "dropCollection"}}, []string{"createCollection", "dropCollection"}},
+		{"time range", Filter{Since: time.Date(2021, 3, 4, 10, 0, 1, 0, time.UTC), Until: time.Date(2021, 3, 4, 11, 0, 0, 0, time.UTC)},
+			[]string{"authenticate", "createCollection"}},
+		{"failed", Filter{Failed: true}, []string{"authenticate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for i := range events {
+				if tt.filter.Match(&events[i]) {
+					got = append(got, events[i].AType)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Match(): got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Package certs creates a local test certificate authority and the certificates a deployment needs for TLS
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Files are PEM encoded, in the forms mongod expects:
+	CA file			the CA certificate (net.tls.CAFile); its key is kept next to it with the extension .key
+	certificate key file	a certificate followed by its private key (net.tls.certificateKeyFile, clusterFile)
+*/
+
+// Kinds of certificate
+type Kind int
+
+const (
+	Server Kind = iota // a member's certificate for incoming connections, also valid as its x509 member certificate
+	Client             // a user's certificate, for connecting to members and x509 authentication
+	Member             // an x509 member certificate for connections between members (net.tls.clusterFile)
+)
+
+func (k Kind) String() string {
+	return [...]string{"server", "client", "member"}[k]
+}
+
+// Organization in the subject of member certificates; mongod treats any certificate whose O, OU and DC match its own
+// as another member, so clients get a different one
+const memberOrg = "mongodb-repro"
+const clientOrg = "mongodb-repro clients"
+
+// Options for issuing a certificate, including the broken variants used to reproduce handshake failures
+type Options struct {
+	Hosts      []string // host names and IP addresses for the subject alternative names
+	Unit       string   // organizational unit; members of a cluster must share it
+	Expired    bool     // valid only in the past
+	WrongSAN   bool     // names a host nobody connects to, instead of Hosts
+	SelfSigned bool     // signed by its own key instead of the CA
+}
+
+const keyBits = 2048
+
+// A CA issues certificates
+type CA struct {
+	Cert *x509.Certificate
+	Key  *rsa.PrivateKey
+}
+
+// NewCA creates a CA with a new key, valid for ten years
+func NewCA(name string) (*CA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating CA key: %v", err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{memberOrg}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA certificate: %v", err)
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// KeyFile is where the key for the CA file fn is kept
+func KeyFile(fn string) string {
+	return strings.TrimSuffix(fn, filepath.Ext(fn)) + ".key"
+}
+
+// LoadCA reads a CA written by Save
+func LoadCA(fn string) (*CA, error) {
+	certPEM, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA: %v", err)
+	}
+	keyPEM, err := ioutil.ReadFile(KeyFile(fn))
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA key: %v", err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("error loading CA %s: %v", fn, err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA certificate: %v", err)
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("CA key in %s is not an RSA key", KeyFile(fn))
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// Save writes the CA certificate to fn, and its key next to it readable only by its owner
+func (ca *CA) Save(fn string) error {
+	err := writePEM(fn, 0644, pemBlock("CERTIFICATE", ca.Cert.Raw))
+	if err != nil {
+		return err
+	}
+	return writePEM(KeyFile(fn), 0600, pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(ca.Key)))
+}
+
+// Issue creates a certificate and key, returned PEM encoded in a form mongod reads as a certificate key file
+func (ca *CA) Issue(kind Kind, name string, opts Options) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key: %v", err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name, Organization: []string{memberOrg}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	if opts.Unit != "" {
+		tmpl.Subject.OrganizationalUnit = []string{opts.Unit}
+	}
+	switch kind {
+	case Server, Member:
+		// members present their certificate both ways: to clients, and to other members as x509 member certificates
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	case Client:
+		tmpl.Subject.Organization = []string{clientOrg}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	if kind != Client {
+		hosts := opts.Hosts
+		if opts.WrongSAN {
+			hosts = []string{"wrong-host.invalid"}
+			tmpl.Subject.CommonName = hosts[0]
+		}
+		for _, h := range hosts {
+			if ip := net.ParseIP(h); ip != nil {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+			} else {
+				tmpl.DNSNames = append(tmpl.DNSNames, h)
+			}
+		}
+	}
+	if opts.Expired {
+		tmpl.NotBefore = time.Now().AddDate(-2, 0, 0)
+		tmpl.NotAfter = time.Now().AddDate(-1, 0, 0)
+	}
+	parent, signer := ca.Cert, ca.Key
+	if opts.SelfSigned {
+		parent, signer = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s certificate: %v", kind, err)
+	}
+	out := pemBlock("CERTIFICATE", der)
+	return append(out, pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))...), nil
+}
+
+// IssueFile issues a certificate and writes it to fn, readable only by its owner
+func (ca *CA) IssueFile(fn string, kind Kind, name string, opts Options) error {
+	out, err := ca.Issue(kind, name, opts)
+	if err != nil {
+		return err
+	}
+	return writePEM(fn, 0600, out)
+}
+
+// LocalHosts are the names a member on this machine is reached by: localhost, the loopback addresses and the host name
+func LocalHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if h, err := os.Hostname(); err == nil && h != "" && h != "localhost" {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// ClientTLS is a TLS config for connecting to members whose certificates were issued by the CA in caFile
+// The client certificate in certFile is presented if certFile is not "".
+func ClientTLS(caFile string, certFile string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if caFile != "" {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	if certFile != "" {
+		pair, err := tls.LoadX509KeyPair(certFile, certFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// ServerTLS is a TLS config for a server presenting the certificate in certFile that requires clients to present
+// certificates issued by the CA in caFile
+func ServerTLS(caFile string, certFile string) (*tls.Config, error) {
+	cfg, err := ClientTLS(caFile, certFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientCAs, cfg.RootCAs = cfg.RootCAs, nil
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// Subject is the subject of the certificate in a certificate key file, in the RFC 2253 form mongod uses as the name of
+// an x.509 user, e.g. "CN=admin,O=mongodb-repro clients"
+func Subject(fn string) (string, error) {
+	pair, err := tls.LoadX509KeyPair(fn, fn)
+	if err != nil {
+		return "", fmt.Errorf("error loading certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return "", fmt.Errorf("error parsing certificate: %v", err)
+	}
+	return cert.Subject.String(), nil
+}
+
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("error generating serial number: %v", err)
+	}
+	return serial, nil
+}
+
+func pemBlock(kind string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+}
+
+func writePEM(fn string, perm os.FileMode, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(fn), 0777)
+	if err != nil {
+		return fmt.Errorf("MkDirAll error: %v", err)
+	}
+	err = ioutil.WriteFile(fn, data, perm)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", fn, err)
+	}
+	err = os.Chmod(fn, perm)
+	if err != nil {
+		return fmt.Errorf("Chmod error: %v", err)
+	}
+	return nil
+}
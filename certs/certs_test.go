@@ -0,0 +1,85 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+)
+
+func TestCA_Issue(t *testing.T) {
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatalf("NewCA(): %v", err)
+	}
+	fn := filepath.Join(t.TempDir(), "ca.pem")
+	err = ca.Save(fn)
+	if err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	ca, err = LoadCA(fn)
+	if err != nil {
+		t.Fatalf("LoadCA(): %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	tests := []struct {
+		name    string
+		kind    Kind
+		opts    Options
+		wantErr bool
+	}{
+		{"server", Server, Options{Hosts: []string{"localhost", "127.0.0.1"}}, false},
+		{"member", Member, Options{Hosts: []string{"localhost"}, Unit: "rs0"}, false},
+		{"client", Client, Options{}, false},
+		{"expired", Server, Options{Hosts: []string{"localhost"}, Expired: true}, true},
+		{"wrong SAN", Server, Options{Hosts: []string{"localhost"}, WrongSAN: true}, true},
+		{"self-signed", Server, Options{Hosts: []string{"localhost"}, SelfSigned: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ca.Issue(tt.kind, "test", tt.opts)
+			if err != nil {
+				t.Fatalf("Issue(): %v", err)
+			}
+			pair, err := tls.X509KeyPair(out, out)
+			if err != nil {
+				t.Fatalf("Issue(): not a certificate key file: %v", err)
+			}
+			cert, err := x509.ParseCertificate(pair.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+			if tt.kind == Client {
+				opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+			} else {
+				opts.DNSName = "localhost"
+			}
+			_, err = cert.Verify(opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubject(t *testing.T) {
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatalf("NewCA(): %v", err)
+	}
+	fn := filepath.Join(t.TempDir(), "client.pem")
+	err = ca.IssueFile(fn, Client, "admin", Options{})
+	if err != nil {
+		t.Fatalf("IssueFile(): %v", err)
+	}
+	got, err := Subject(fn)
+	if err != nil {
+		t.Fatalf("Subject(): %v", err)
+	}
+	if want := "CN=admin,O=mongodb-repro clients"; got != want {
+		t.Errorf("Subject() = %s, want %s", got, want)
+	}
+}
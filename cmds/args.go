@@ -0,0 +1,76 @@
+package cmds
+
+import (
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"strings"
+)
+
+// Print the mongod command line equivalent to each config named by args[0]: a YAML file, a deployment (or
+// deployment/member), "defaults" or "ours"
+func printArgs(args []string, isWindows bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: config args <file|deployment[/member]|defaults|ours>")
+	}
+	members, err := resolveConfigs(args[0])
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		argv, err := m.Config.ToArgs(isWindows)
+		if err != nil {
+			return fmt.Errorf("%s: %v", args[0], err)
+		}
+		if m.Name != "" {
+			fmt.Printf("%s:\n", m.Name)
+		}
+		fmt.Printf("%s %s\n", programName(m.Config, isWindows), quoteArgs(argv, isWindows))
+	}
+	return nil
+}
+
+// the program that runs a config: mongos for routers, which have a sharding.configDB, otherwise mongod
+func programName(c *config.Type, isWindows bool) string {
+	name := "mongod"
+	if c.Sharding.ConfigDB != "" {
+		name = "mongos"
+	}
+	if isWindows {
+		name += ".exe"
+	}
+	return name
+}
+
+// join arguments into a command line, quoting those the shell would split or expand
+// On Windows arguments are quoted as CommandLineToArgvW reads them back: in double quotes, with backslashes doubled
+// only before a double quote.
+func quoteArgs(argv []string, isWindows bool) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		switch {
+		case isWindows && (arg == "" || strings.ContainsAny(arg, " \t\n\"")):
+			var b strings.Builder
+			b.WriteByte('"')
+			backslashes := 0
+			for _, r := range arg {
+				switch r {
+				case '\\':
+					backslashes++
+				case '"':
+					b.WriteString(strings.Repeat(`\`, backslashes+1))
+					backslashes = 0
+				default:
+					backslashes = 0
+				}
+				b.WriteRune(r)
+			}
+			b.WriteString(strings.Repeat(`\`, backslashes)) // before the closing quote
+			b.WriteByte('"')
+			arg = b.String()
+		case !isWindows && (arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~")):
+			arg = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
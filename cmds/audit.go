@@ -0,0 +1,125 @@
+package cmds
+
+import (
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/audit"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Turn on auditing for a deployment, or show the events in audit logs
+// args are "setup [-format f] [-filter f] <deployment>" or "[filters] <deployment[/member]|file>"
+func auditCmd(args []string, isWindows bool) error {
+	if len(args) > 0 && args[0] == "setup" {
+		return auditSetup(args[1:], isWindows)
+	}
+	return auditShow(args)
+}
+
+// Make every member of a deployment audit to a file in its directory
+func auditSetup(args []string, isWindows bool) error {
+	fs := flag.NewFlagSet("audit setup", flag.ContinueOnError)
+	format := fs.String("format", "JSON", "auditLog.format, JSON or BSON")
+	filter := fs.String("filter", "", "auditLog.filter, a query document selecting the events to audit; \"\" for all")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: audit setup [-format JSON|BSON] [-filter f] <deployment>")
+	}
+	if *format != "JSON" && *format != "BSON" {
+		return fmt.Errorf("audit format must be JSON or BSON, not %s", *format)
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		a := &m.Config.AuditLog
+		a.Destination = "file"
+		a.Format = *format
+		a.Path = filepath.Join(d.MemberPath(m.Name), "auditLog."+strings.ToLower(*format))
+		a.Filter = *filter
+	}
+	fmt.Printf("Deployment %s now audits to auditLog.%s in each member's directory; restart it to apply\n", d.Name, strings.ToLower(*format))
+	return d.Write(isWindows)
+}
+
+// Print the events in the audit logs of a deployment, one of its members, or a customer's audit log file
+// Events from several members are merged in time order.
+func auditShow(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	users := fs.String("user", "", "Only events by these users (user or user@db), comma separated")
+	atypes := fs.String("atype", "", "Only events of these action types, comma separated, e.g. authenticate,dropCollection")
+	since := fs.String("since", "", "Only events at or after this time (e.g. 2021-03-04T10:00:00Z, or 15m for 15 minutes ago)")
+	until := fs.String("until", "", "Only events before this time")
+	failed := fs.Bool("failed", false, "Only events that failed")
+	full := fs.Bool("full", false, "Print the parameters of each event in full instead of cutting long lines short")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: audit [-user u] [-atype a] [-since t] [-until t] [-failed] [-full] <deployment[/member]|file>")
+	}
+	filter := audit.Filter{Users: splitList(*users), ATypes: splitList(*atypes), Failed: *failed}
+	filter.Since, err = parseTimeArg(*since)
+	if err != nil {
+		return err
+	}
+	filter.Until, err = parseTimeArg(*until)
+	if err != nil {
+		return err
+	}
+	files, err := resolveMemberFiles(fs.Arg(0), "audit log", func(m *deploy.Member) string { return m.Config.AuditFile() })
+	if err != nil {
+		return err
+	}
+
+	type memberEvent struct {
+		member string
+		event  audit.Event
+	}
+	var selected []memberEvent
+	for _, f := range files {
+		events, err := audit.ReadFile(f.Path)
+		if err != nil {
+			if len(events) == 0 {
+				return err
+			}
+			fmt.Printf("Warning: %v\n", err)
+		}
+		for _, e := range events {
+			if filter.Match(&e) {
+				selected = append(selected, memberEvent{f.Member, e})
+			}
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].event.TS.Before(selected[j].event.TS) })
+	width := memberWidth(files)
+	for _, me := range selected {
+		line := me.event.String()
+		if !*full {
+			line = truncate(line, 200)
+		}
+		if width > 0 {
+			fmt.Printf("%-*s %s\n", width, me.member, line)
+		} else {
+			fmt.Println(line)
+		}
+	}
+	fmt.Printf("%d events\n", len(selected))
+	return nil
+}
+
+// shorten s to at most n characters, marking the cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
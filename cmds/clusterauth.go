@@ -0,0 +1,97 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/version"
+)
+
+// Move a running deployment to another cluster auth mode, one mode at a time across every member
+// Moving towards x509 is done with setParameter on the running members; mongod can't move back at runtime, so moving
+// towards keyFile restarts the members one by one. Each member's config is rewritten as soon as it has moved.
+// args are the deployment name and the target mode
+func clusterAuthCmd(args []string, v *version.Version, isWindows bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: clusterauth <deployment> <keyFile|sendKeyFile|sendX509|x509>")
+	}
+	d, err := deploy.Open(runtimePath, args[0])
+	if err != nil {
+		return err
+	}
+	from := d.Members[0].Config.ClusterAuthMode()
+	for _, m := range d.Members {
+		if m.Config.ClusterAuthMode() != from {
+			return fmt.Errorf("members are in different modes (%s is in %s, %s in %s), finish the previous transition first",
+				d.Members[0].Name, from, m.Name, m.Config.ClusterAuthMode())
+		}
+	}
+	steps, err := config.ClusterAuthSteps(from, args[1])
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Printf("Deployment %s is already in %s mode\n", d.Name, from)
+		return nil
+	}
+
+	// check every member can run in every mode along the way before changing anything
+	for _, mode := range steps {
+		for _, m := range d.Members {
+			cfg := m.Config.Copy()
+			cfg.Security.ClusterAuthMode = mode
+			_, err = cfg.Validate(v)
+			if err != nil {
+				return fmt.Errorf("member %s can't move to %s: %v", m.Name, mode, err)
+			}
+		}
+	}
+
+	forward := modeIndex(args[1]) > modeIndex(from)
+	for _, mode := range steps {
+		for _, m := range d.Members {
+			m.Config.Security.ClusterAuthMode = mode
+			if forward {
+				err = setClusterAuthMode(d, m, mode)
+				if err == nil {
+					err = d.Write(isWindows)
+				}
+			} else {
+				err = d.Write(isWindows)
+				var mv *version.Version
+				if err == nil {
+					mv, err = memberVersion(v, d, m)
+				}
+				if err == nil {
+					err = restartMember(mv, d, m, isWindows)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("moving %s to %s: %v", m.Name, mode, err)
+			}
+			fmt.Printf("%s is now in %s mode\n", m.Name, mode)
+		}
+	}
+	return nil
+}
+
+// position of a mode in the keyFile to x509 order
+func modeIndex(mode string) int {
+	for i, m := range config.ClusterAuthModes {
+		if m == mode {
+			return i
+		}
+	}
+	return -1
+}
+
+// change a running member's cluster auth mode
+func setClusterAuthMode(d *deploy.Deployment, m *deploy.Member, mode string) error {
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting: %v", err)
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	return setParameters(client, config.Parameters{"clusterAuthMode": mode})
+}
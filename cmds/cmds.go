@@ -2,8 +2,10 @@ package cmds
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
 	"github.com/SpencerBrown/mongodb-repro/get"
 	"github.com/SpencerBrown/mongodb-repro/version"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,21 +14,30 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const binaryDir = "mongodb-binaries"
 const runtimeDir = "mongodb-runtime"
+const profileDir = "mongodb-profiles"
+const snapshotDir = "mongodb-snapshots"
 
 var binaryPath string
 var runtimePath string
+var profilePath string
+var snapshotPath string
 
 func init() {
 	binaryPath = getPath(binaryDir)
 	runtimePath = getPath(runtimeDir)
+	profilePath = getPath(profileDir)
+	snapshotPath = getPath(snapshotDir)
 }
 
 func getPath(dir string) string {
@@ -37,8 +48,13 @@ func getPath(dir string) string {
 	return filepath.Join(homedir, dir)
 }
 
-func Cmds(cmd string, v *version.Version, isWindows bool) error {
+func Cmds(args []string, v *version.Version, isWindows bool) error {
 
+	err := migrateOldLayout(isWindows)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	cmd := args[0]
 	switch cmd {
 	case "marshal":
 		fmt.Println("MongoDB Defaults applied")
@@ -49,15 +65,25 @@ func Cmds(cmd string, v *version.Version, isWindows bool) error {
 		cfg = config.OurDefaults
 		buf = cfg.ToYaml(isWindows)
 		_, _ = buf.WriteTo(os.Stdout)
-		fmt.Println("Unmarshaling back to config struct")
-		cfgbytes, err := ioutil.ReadFile(filepath.Join(runtimePath, "sa.yaml.gob"))
+		fmt.Println("Parsing YAML back to config struct")
+		yamlbytes, err := ioutil.ReadFile(filepath.Join(runtimePath, "sa", "sa.yaml"))
+		if err != nil {
+			fmt.Printf("Error reading YAML file %v\n", err)
+			break
+		}
+		cfg1, unknown, err := config.FromYaml(yamlbytes)
 		if err != nil {
-			fmt.Printf("Error reading GoB file %v\n", err)
+			fmt.Printf("Error parsing YAML file %v\n", err)
 			break
 		}
-		cfg2, err := config.FromGoB(cfgbytes)
+		for _, key := range unknown {
+			fmt.Printf("Unknown setting %s\n", key)
+		}
+		fmt.Println(cfg1)
+		fmt.Println("Reading side file back to config struct")
+		cfg2, err := config.ReadSideFile(filepath.Join(runtimePath, "sa", "sa.yaml"))
 		if err != nil {
-			fmt.Printf("Error unmarshaling GoB file %v\n", err)
+			fmt.Printf("Error reading side file %v\n", err)
 			break
 		}
 		fmt.Println(cfg2)
@@ -72,75 +98,428 @@ func Cmds(cmd string, v *version.Version, isWindows bool) error {
 			fmt.Printf("Error: %v\n", err)
 		}
 	case "config":
-		var cfg config.Type
-		cfg = *config.OurDefaults // makes a copy so we don't pollute the static global variable. This makes a full copy because we don't have any reference types in the struct.
-		cfg.Storage.DbPath = filepath.Join(runtimePath, "data")
-		cfg.SystemLog.Path = filepath.Join(runtimePath, "sa.log")
-		//cfg.ProcessManagement.Fork = true
-		//isWindows = true
-		err := config.WriteConfig(&cfg, runtimePath, "sa.yaml", isWindows)
+		if len(args) > 1 && args[1] == "import" {
+			err := importConfig(args[2:], v, isWindows)
+			if err != nil {
+				fmt.Printf("Import error: %v\n", err)
+			}
+			break
+		}
+		if len(args) > 1 && args[1] == "diff" {
+			err := diffConfigs(args[2:])
+			if err != nil {
+				fmt.Printf("Diff error: %v\n", err)
+			}
+			break
+		}
+		if len(args) > 1 && args[1] == "args" {
+			err := printArgs(args[2:], isWindows)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			break
+		}
+		if len(args) > 1 && args[1] == "schema" {
+			err := printSchema()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			break
+		}
+		if len(args) > 1 && args[1] == "profiles" {
+			err := listProfiles()
+			if err != nil {
+				fmt.Printf("Error listing profiles: %v\n", err)
+			}
+			break
+		}
+		err := configureDeployment(args[1:], v, isWindows)
 		if err == nil {
 			fmt.Printf("Configuration complete!\n")
 		} else {
 			fmt.Printf("Setup error: %v\n", err)
 		}
 	case "run":
-		loc, err := v.ToLocation()
+		d, err := deploy.Open(runtimePath, deploymentName(args, 1))
 		if err != nil {
-			fmt.Printf("Error converting to filename: %v\n", loc)
+			fmt.Printf("Error: %v\n", err)
 			break
 		}
-		fn := loc.Filename
-		mongoExt := ""
-		if isWindows {
-			mongoExt = ".exe"
+		// members run the release they were last started with, which an upgrade may have moved past v
+		versions := make(map[string]*version.Version)
+		for _, m := range d.Members {
+			versions[m.Name], err = memberVersion(v, d, m)
+			if err != nil {
+				break
+			}
 		}
-		runcmd := exec.Command(filepath.Join(binaryPath, fn, "bin", "mongod"+mongoExt), "-f", filepath.Join(runtimePath, "sa.yaml"))
-		err = runcmd.Start()
-		if err != nil {
-			fmt.Printf("Error ) MongoDB: %v\n", err)
-			break
+		if err == nil {
+			err = validateMembers(d, versions, isWindows)
 		}
-		fmt.Printf("Started!\n")
-		client, err := connectMongo(false)
 		if err != nil {
-			fmt.Printf("Error connecting to server: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			break
 		}
-		defer func() {
-			if err = client.Disconnect(context.Background()); err != nil {
-				panic(err)
+		// a member that fails doesn't stop the others, whose errors are reported too
+		for _, g := range memberGroups(d) {
+			err = runGroup(d, g, versions, isWindows)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
 			}
-		}()
-		err = setupAdminUser(client)
-		if err != nil {
-			fmt.Printf("Error setting up admin user: %v\n", err)
-			break
 		}
-		fmt.Printf("Successfully set up admin user!\n")
 	case "stop":
-		client, err := connectMongo(true)
+		d, err := deploy.Open(runtimePath, deploymentName(args, 1))
 		if err != nil {
-			fmt.Printf("Error connecting to server: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			break
 		}
-		defer func() {
-			if err = client.Disconnect(context.Background()); err != nil {
-				panic(err)
+		for _, m := range d.Members {
+			client, err := connectMember(d, m, true)
+			if err != nil {
+				fmt.Printf("Error connecting to %s: %v\n", m.Name, err)
+				continue
+			}
+			err = shutdownServer(client)
+			_ = client.Disconnect(context.Background())
+			if err != nil {
+				fmt.Printf("Error shutting down %s: %v\n", m.Name, err)
+				continue
 			}
-		}()
-		err = shutdownServer(client)
+			fmt.Printf("Successfully shut down %s!\n", m.Name)
+		}
+	case "clusterauth":
+		err := clusterAuthCmd(args[1:], v, isWindows)
 		if err != nil {
-			fmt.Printf("Error shutting down server: %v\n", err)
-			break
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "tls":
+		err := tlsCmd(args[1:], isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "logs":
+		err := logsCmd(args[1:], isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "audit":
+		err := auditCmd(args[1:], isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "encryption":
+		err := encryptionCmd(args[1:], v, isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "ldap":
+		err := ldapCmd(args[1:], v, isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "status":
+		err := statusCmd(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "upgrade", "downgrade":
+		err := upgradeCmd(args[1:], v, isWindows, cmd == "downgrade")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "fcv":
+		err := fcvCmd(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "load":
+		err := loadCmd(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "seed":
+		err := seedCmd(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "snapshot":
+		err := snapshotCmd(args[1:], v, isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "scenario":
+		err := scenarioCmd(args[1:], v, isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "fault":
+		err := faultCmd(args[1:], v, isWindows)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "setparameter":
+		err := setParameterCmd(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
 		}
-		fmt.Printf("Successfully shut down server!\n")
 	default:
 		fmt.Printf("Unrecognized command %s\n", cmd)
 	}
 	return nil
 }
 
+// name of the deployment given as args[i], "sa" (the standalone set up by "config") if there is none
+func deploymentName(args []string, i int) string {
+	if len(args) > i {
+		return args[i]
+	}
+	return "sa"
+}
+
+// check every member's config against the version about to run it, rewriting configs whose options were renamed
+func validateDeployment(d *deploy.Deployment, v *version.Version, isWindows bool) error {
+	versions := make(map[string]*version.Version)
+	for _, m := range d.Members {
+		versions[m.Name] = v
+	}
+	return validateMembers(d, versions, isWindows)
+}
+
+// check every member's config against the version about to run that member, rewriting configs whose options were
+// renamed
+func validateMembers(d *deploy.Deployment, versions map[string]*version.Version, isWindows bool) error {
+	rewrite := false
+	for _, m := range d.Members {
+		changes, err := m.Config.Validate(versions[m.Name])
+		if err != nil {
+			return fmt.Errorf("member %s: %v", m.Name, err)
+		}
+		for _, c := range changes {
+			fmt.Printf("%s: %v\n", m.Name, c)
+		}
+		rewrite = rewrite || len(changes) > 0
+	}
+	if rewrite {
+		return d.Write(isWindows)
+	}
+	return nil
+}
+
+// start a member's mongod with the binaries for the requested version, recording the release for the next start
+func startMember(v *version.Version, d *deploy.Deployment, m *deploy.Member, isWindows bool) error {
+	runcmd, err := mongodCommand(v, d, m, isWindows)
+	if err != nil {
+		return err
+	}
+	err = runcmd.Start()
+	if err != nil {
+		return fmt.Errorf("error starting MongoDB: %v", err)
+	}
+	return d.SetRelease(m.Name, v.Release.String())
+}
+
+// the binaries to start a member with: those for the release it was last started with, which upgrades and downgrades
+// change, or v's if there is none
+func memberVersion(v *version.Version, d *deploy.Deployment, m *deploy.Member) (*version.Version, error) {
+	releases, err := d.Releases()
+	if err != nil {
+		return nil, err
+	}
+	if releases[m.Name] == "" || releases[m.Name] == v.Release.String() {
+		return v, nil
+	}
+	mv, err := installedVersion(v, releases[m.Name])
+	if err != nil {
+		return nil, fmt.Errorf("member %s last ran %s: %v", m.Name, releases[m.Name], err)
+	}
+	return mv, nil
+}
+
+// Start a replica set's members, or a standalone or mongos, with the versions given by member
+// A replica set that isn't yet is initiated once its members are up, and waited on for a primary. The admin users are
+// created when a replica set is initiated, through its primary, or on a standalone; those of a sharded cluster live on
+// its config servers, so none are created through a mongos.
+func runGroup(d *deploy.Deployment, g *memberGroup, versions map[string]*version.Version, isWindows bool) error {
+	var started []*deploy.Member
+	for _, m := range g.members {
+		err := startMember(versions[m.Name], d, m, isWindows)
+		if err == nil {
+			err = waitForPort(m.HostPort(), true)
+		}
+		if err != nil {
+			fmt.Printf("Error starting %s: %v\n", m.Name, err)
+			continue
+		}
+		fmt.Printf("Started %s!\n", m.Name)
+		started = append(started, m)
+	}
+	if len(started) < len(g.members) {
+		if g.name != "" {
+			return fmt.Errorf("replica set %s was not initiated, as not all of its members started", g.name)
+		}
+		return nil
+	}
+	target := g.members[0]
+	if g.name != "" {
+		initiated, err := initiateReplSet(d, g)
+		if err != nil {
+			return fmt.Errorf("replica set %s: %v", g.name, err)
+		}
+		target, err = waitForPrimary(d, g)
+		if err != nil {
+			return err
+		}
+		if !initiated {
+			return nil // its users were created when it was
+		}
+	}
+	if g.kind == routerGroup {
+		return nil
+	}
+	err := setupUsers(d, target)
+	if err != nil {
+		return fmt.Errorf("error setting up admin user on %s: %v", target.Name, err)
+	}
+	fmt.Printf("Successfully set up admin user!\n")
+	if target.Config.Security.Ldap.Authz.QueryTemplate != "" {
+		err = setupLDAPRoles(d, target)
+		if err != nil {
+			return fmt.Errorf("error setting up LDAP roles on %s: %v", target.Name, err)
+		}
+	}
+	return nil
+}
+
+// the command running mongod with a member's config file, followed by any extra options
+// Members with a sharding.configDB are routers, run by mongos instead.
+func mongodCommand(v *version.Version, d *deploy.Deployment, m *deploy.Member, isWindows bool, extra ...string) (*exec.Cmd, error) {
+	loc, err := v.ToLocation()
+	if err != nil {
+		return nil, fmt.Errorf("error converting to filename: %v", err)
+	}
+	args := append([]string{"-f", d.ConfigFile(m.Name)}, extra...)
+	return exec.Command(filepath.Join(binaryPath, loc.Filename, "bin", programName(m.Config, isWindows)), args...), nil
+}
+
+// restart a running member with its current config file, waiting until it accepts connections again
+func restartMember(v *version.Version, d *deploy.Deployment, m *deploy.Member, isWindows bool) error {
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting: %v", err)
+	}
+	err = shutdownServer(client)
+	_ = client.Disconnect(context.Background())
+	if err != nil {
+		return err
+	}
+	err = waitForPort(m.HostPort(), false)
+	if err != nil {
+		return err
+	}
+	err = startMember(v, d, m, isWindows)
+	if err != nil {
+		return err
+	}
+	return waitForPort(m.HostPort(), true)
+}
+
+// the process id of a running member: from the pid file mongod writes as it starts, which works even if the member
+// can't answer, or else from serverStatus
+func memberPid(d *deploy.Deployment, m *deploy.Member) (int, error) {
+	if pid, ok := pidFileProcess(d, m); ok {
+		return pid, nil
+	}
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var result struct {
+		Pid int64 `bson:"pid"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{"serverStatus", 1}}).Decode(&result)
+	if err != nil {
+		return 0, fmt.Errorf("error running serverStatus: %v", err)
+	}
+	return int(result.Pid), nil
+}
+
+// the process in a member's pid file, if it runs the member's config file
+// mongod leaves its pid file behind when killed, and the pid may since have been reused, so one known to be stale
+// is removed.
+func pidFileProcess(d *deploy.Deployment, m *deploy.Member) (int, bool) {
+	fn := m.Config.ProcessManagement.PidFilePath
+	if fn == "" {
+		return 0, false
+	}
+	in, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(in)))
+	if err == nil {
+		var args string
+		args, err = processArgs(pid)
+		if err == nil && strings.Contains(args, d.ConfigFile(m.Name)) {
+			return pid, true
+		}
+	}
+	if err == nil {
+		_ = os.Remove(fn)
+	}
+	return 0, false
+}
+
+// whether a member is up, even if it can't answer: its port is open or its pid file names its process
+func memberUp(d *deploy.Deployment, m *deploy.Member) bool {
+	conn, err := net.DialTimeout("tcp", m.HostPort(), 500*time.Millisecond)
+	if err == nil {
+		_ = conn.Close()
+		return true
+	}
+	_, ok := pidFileProcess(d, m)
+	return ok
+}
+
+// kill a running member's process without letting it shut down, as a crash would, waiting until it stops listening
+func killMember(d *deploy.Deployment, m *deploy.Member) error {
+	pid, err := memberPid(d, m)
+	if err != nil {
+		return err
+	}
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		err = p.Kill()
+	}
+	if err != nil {
+		return fmt.Errorf("error killing process %d: %v", pid, err)
+	}
+	if fn := m.Config.ProcessManagement.PidFilePath; fn != "" {
+		_ = os.Remove(fn) // it won't remove it itself
+	}
+	return waitForPort(m.HostPort(), false)
+}
+
+// wait up to 30 seconds for a member to start or stop listening
+func waitForPort(host string, listening bool) error {
+	for i := 0; i < 60; i++ {
+		conn, err := net.DialTimeout("tcp", host, 500*time.Millisecond)
+		if conn != nil {
+			_ = conn.Close()
+		}
+		if (err == nil) == listening {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if listening {
+		return fmt.Errorf("%s did not start listening", host)
+	}
+	return fmt.Errorf("%s did not shut down", host)
+}
+
 func setupAdminUser(client *mongo.Client) error {
 	cmd := bson.D{{"createUser", "admin"}, {"pwd", "tester"}, {"roles", bson.A{"root"}}}
 	db := client.Database("admin")
@@ -171,15 +550,33 @@ func shutdownServer(client *mongo.Client) error {
 	return nil
 }
 
-func connectMongo(auth bool) (*mongo.Client, error) {
-	copt := new(options.ClientOptions)
-	copt.Hosts = []string{"localhost:27017"}
+// connect to a member of a deployment, over TLS if the member prefers or requires it
+// With auth, the admin user authenticates with the deployment's client certificate if the member allows x.509
+// authentication, otherwise with its password.
+func connectMember(d *deploy.Deployment, m *deploy.Member, auth bool) (*mongo.Client, error) {
+	tlsConfig, err := memberTLS(d, m)
+	if err != nil {
+		return nil, err
+	}
+	var cred *options.Credential
 	if auth {
-		copt.Auth = &options.Credential{
+		cred = &options.Credential{
 			Username: "admin",
 			Password: "tester",
 		}
+		if useX509(d, m) {
+			cred = &options.Credential{AuthMechanism: "MONGODB-X509", AuthSource: "$external"}
+		}
 	}
+	return connectMongo(m.HostPort(), cred, tlsConfig)
+}
+
+func connectMongo(host string, cred *options.Credential, tlsConfig *tls.Config) (*mongo.Client, error) {
+	copt := new(options.ClientOptions)
+	copt.Hosts = []string{host}
+	copt.SetDirect(true) // talk to this member, not whichever is primary in its replica set
+	copt.TLSConfig = tlsConfig
+	copt.Auth = cred
 	client, err := mongo.NewClient(copt)
 	if err != nil {
 		return nil, fmt.Errorf("error setting up client: %v", err)
@@ -239,3 +636,120 @@ func listVersions() error {
 	}
 	return nil
 }
+
+// A file belonging to a deployment member, or given directly if Member is ""
+type memberFile struct {
+	Member string
+	Path   string
+}
+
+// resolve an argument naming a file or a deployment[/member] to files, using path to find each member's file
+// Members for which path returns "" are skipped, or reported if a single member was asked for.
+func resolveMemberFiles(arg string, what string, path func(m *deploy.Member) string) ([]memberFile, error) {
+	if fi, err := os.Stat(arg); err == nil && !fi.IsDir() {
+		return []memberFile{{Path: arg}}, nil
+	}
+	name, member := arg, ""
+	if i := strings.Index(arg, "/"); i >= 0 {
+		name, member = arg[:i], arg[i+1:]
+	}
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a file or deployment: %v", arg, err)
+	}
+	var files []memberFile
+	for _, m := range d.Members {
+		if member != "" && m.Name != member {
+			continue
+		}
+		fn := path(m)
+		if fn == "" {
+			if member != "" {
+				return nil, fmt.Errorf("member %s has no %s file", m.Name, what)
+			}
+			continue
+		}
+		files = append(files, memberFile{Member: m.Name, Path: fn})
+	}
+	if member != "" && len(files) == 0 {
+		return nil, fmt.Errorf("deployment %s has no member %s", name, member)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no member of deployment %s has a %s file", name, what)
+	}
+	return files, nil
+}
+
+// parse a time given on the command line: RFC 3339, a UTC date and time, a UTC date, or a duration meaning that long ago
+func parseTimeArg(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("bad time '%s', expected e.g. 2021-03-04T10:00:00Z, 2021-03-04 or 15m", s)
+}
+
+// split a comma-separated flag value, nil if it's empty
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// the member to send data to for "deployment[/member]": the member if one is named, otherwise a mongos if the
+// deployment has one, or else the primary of its first replica set other than the config servers, or its first standalone
+func dataMember(arg string) (*deploy.Deployment, *deploy.Member, error) {
+	name, member := arg, ""
+	if i := strings.Index(arg, "/"); i >= 0 {
+		name, member = arg[:i], arg[i+1:]
+	}
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if member != "" {
+		m := d.Member(member)
+		if m == nil {
+			return nil, nil, fmt.Errorf("deployment %s has no member %s", name, member)
+		}
+		return d, m, nil
+	}
+	m, err := defaultDataMember(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, m, nil
+}
+
+// a mongos if the deployment has one, or else the primary of its first replica set other than the config servers, or
+// its first standalone
+func defaultDataMember(d *deploy.Deployment) (*deploy.Member, error) {
+	groups := memberGroups(d)
+	if last := groups[len(groups)-1]; last.kind == routerGroup {
+		return last.members[0], nil
+	}
+	for _, g := range groups {
+		switch {
+		case g.kind == configGroup:
+			continue
+		case g.name == "":
+			return g.members[0], nil
+		}
+		return findPrimary(d, g)
+	}
+	return nil, fmt.Errorf("deployment %s has only config servers", d.Name)
+}
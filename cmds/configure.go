@@ -0,0 +1,144 @@
+package cmds
+
+import (
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/staticContent"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profiles built into the tool; a file <name>.yaml in the profile directory overrides a built-in profile
+var builtinProfiles = map[string]string{
+	"minimal":        staticContent.Profile_minimal_yaml,
+	"prod-like":      staticContent.Profile_prod_like_yaml,
+	"tls-everywhere": staticContent.Profile_tls_everywhere_yaml,
+	"inmemory":       staticContent.Profile_inmemory_yaml,
+}
+
+// Create a deployment from our defaults, a list of profiles and a spec giving its members and their overrides
+// args are [-profile p1,p2] [-spec file] [deployment]
+func configureDeployment(args []string, v *version.Version, isWindows bool) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	profileList := fs.String("profile", "", "Comma-separated profiles to apply in order, e.g. minimal,tls-everywhere")
+	specFile := fs.String("spec", "", "YAML file listing profiles and members with their overrides")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	spec := &config.Spec{Members: []config.MemberSpec{{Name: "sa"}}}
+	if *specFile != "" {
+		in, err := ioutil.ReadFile(*specFile)
+		if err != nil {
+			return fmt.Errorf("error reading spec: %v", err)
+		}
+		spec, err = config.ParseSpec(in)
+		if err != nil {
+			return err
+		}
+		if len(spec.Members) == 0 {
+			return fmt.Errorf("spec %s has no members", *specFile)
+		}
+	}
+	if *profileList != "" {
+		spec.Profiles = append(spec.Profiles, strings.Split(*profileList, ",")...)
+	}
+	var layers []*config.Layer
+	for _, name := range spec.Profiles {
+		l, err := loadProfile(name)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, l)
+	}
+
+	d := deploy.New(runtimePath, deploymentName(fs.Args(), 0))
+	for i, ms := range spec.Members {
+		// each member's files live in its own directory, unless a profile or override says otherwise
+		base := config.OurDefaults.Copy() // makes a deep copy so we don't pollute the static global variable
+		base.Storage.DbPath = filepath.Join(d.MemberPath(ms.Name), "data")
+		base.SystemLog.Path = filepath.Join(d.MemberPath(ms.Name), "mongod.log")
+		base.ProcessManagement.PidFilePath = filepath.Join(d.MemberPath(ms.Name), "mongod.pid")
+		if len(spec.Members) > 1 {
+			base.Net.Port = uint(27017 + i)
+		}
+		memberLayers := layers
+		if ms.Override != nil {
+			memberLayers = append(memberLayers[:len(layers):len(layers)], ms.Override)
+		}
+		vars := map[string]string{
+			"deployment":     d.Name,
+			"deploymentPath": d.Path,
+			"member":         ms.Name,
+			"memberPath":     d.MemberPath(ms.Name),
+		}
+		cfg, err := config.Build(base, vars, memberLayers...)
+		if err != nil {
+			return err
+		}
+		changes, err := cfg.Validate(v)
+		if err != nil {
+			return fmt.Errorf("member %s: %v", ms.Name, err)
+		}
+		for _, c := range changes {
+			fmt.Printf("%s: %v\n", ms.Name, c)
+		}
+		d.Add(ms.Name, cfg)
+	}
+	if d.NeedsClusterAuth() {
+		d.UseKeyFile()
+	}
+	err = ensureCerts(d)
+	if err != nil {
+		return err
+	}
+	return d.Write(isWindows)
+}
+
+// find a profile by name, in the profile directory or built in
+func loadProfile(name string) (*config.Layer, error) {
+	in, err := ioutil.ReadFile(filepath.Join(profilePath, name+".yaml"))
+	if os.IsNotExist(err) {
+		builtin, ok := builtinProfiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' not found in %s or built in", name, profilePath)
+		}
+		in, err = []byte(builtin), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile %s: %v", name, err)
+	}
+	return config.ParseLayer("profile "+name, in)
+}
+
+// list the built-in profiles and those in the profile directory
+func listProfiles() error {
+	names := make(map[string]string)
+	for name := range builtinProfiles {
+		names[name] = "built in"
+	}
+	files, err := ioutil.ReadDir(profilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".yaml" {
+			names[strings.TrimSuffix(f.Name(), ".yaml")] = filepath.Join(profilePath, f.Name())
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		fmt.Printf("%-16s %s\n", name, names[name])
+	}
+	return nil
+}
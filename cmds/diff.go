@@ -0,0 +1,135 @@
+package cmds
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Compare two configurations, each a YAML file, a deployment (or deployment/member), "defaults" or "ours"
+// args are [-json] <first> <second>
+func diffConfigs(args []string) error {
+	fs := flag.NewFlagSet("config diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Write the differences as JSON")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: config diff [-json] <file|deployment[/member]|defaults|ours> <file|deployment[/member]|defaults|ours>")
+	}
+	a, err := resolveConfigs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := resolveConfigs(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	// pair up members by name; a single config on either side is compared with every member on the other
+	diffs := make(map[string][]config.Difference)
+	var names []string
+	add := func(name string, x *config.Type, y *config.Type) {
+		names = append(names, name)
+		diffs[name] = config.Diff(x, y)
+	}
+	switch {
+	case len(a) == 1 && a[0].Name == "":
+		for _, m := range b {
+			add(m.Name, a[0].Config, m.Config)
+		}
+	case len(b) == 1 && b[0].Name == "":
+		for _, m := range a {
+			add(m.Name, m.Config, b[0].Config)
+		}
+	default:
+		for _, m := range a {
+			other := config.MongoDBDefaults
+			if o := findMember(b, m.Name); o != nil {
+				other = o.Config
+			} else {
+				fmt.Fprintf(os.Stderr, "Member %s is only in %s, comparing with defaults\n", m.Name, fs.Arg(0))
+			}
+			add(m.Name, m.Config, other)
+		}
+		for _, m := range b {
+			if findMember(a, m.Name) == nil {
+				fmt.Fprintf(os.Stderr, "Member %s is only in %s, comparing with defaults\n", m.Name, fs.Arg(1))
+				add(m.Name, config.MongoDBDefaults, m.Config)
+			}
+		}
+	}
+
+	if *asJSON {
+		var out interface{} = diffs
+		if len(names) == 1 && names[0] == "" {
+			out = diffs[""]
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	for _, name := range names {
+		if name != "" {
+			fmt.Printf("%s:\n", name)
+		}
+		if len(diffs[name]) == 0 {
+			fmt.Printf("  no differences\n")
+		}
+		for _, d := range diffs[name] {
+			fmt.Printf("  %v\n", d)
+		}
+	}
+	return nil
+}
+
+// the configs named by a diff argument; a single config that isn't a member has an empty name
+func resolveConfigs(arg string) ([]*deploy.Member, error) {
+	switch arg {
+	case "defaults":
+		return []*deploy.Member{{Config: config.MongoDBDefaults}}, nil
+	case "ours":
+		return []*deploy.Member{{Config: config.OurDefaults}}, nil
+	}
+	if in, err := ioutil.ReadFile(arg); err == nil {
+		cfg, unknown, err := config.FromYaml(in)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", arg, err)
+		}
+		for _, key := range unknown {
+			fmt.Fprintf(os.Stderr, "%s: ignoring unknown setting %s\n", arg, key)
+		}
+		return []*deploy.Member{{Config: cfg}}, nil
+	}
+	name, member := arg, ""
+	if i := strings.Index(arg, "/"); i >= 0 {
+		name, member = arg[:i], arg[i+1:]
+	}
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a file, deployment or \"defaults\": %v", arg, err)
+	}
+	if member == "" {
+		return d.Members, nil
+	}
+	m := d.Member(member)
+	if m == nil {
+		return nil, fmt.Errorf("deployment %s has no member %s", name, member)
+	}
+	return []*deploy.Member{{Config: m.Config}}, nil
+}
+
+func findMember(members []*deploy.Member, name string) *deploy.Member {
+	for _, m := range members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package cmds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/certs"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/kmip"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/*
+Encryption files for a deployment, unless its configs name other paths:
+	<runtime>/<deployment>/<member>/encryption.key	member's local master key (security.encryptionKeyFile)
+	<runtime>/<deployment>/<member>/kmip-client.pem	member's certificate for the KMIP server
+	<runtime>/<deployment>/kmip-server.pem	the local KMIP server's certificate, issued by the deployment's CA
+	<runtime>/<deployment>/kmip-keys.json	the local KMIP server's keys; the members' data is unreadable without them
+*/
+
+const kmipClientFileName = "kmip-client.pem"
+const kmipServerFileName = "kmip-server.pem"
+const kmipKeysFileName = "kmip-keys.json"
+
+// Set up encryption at rest for a deployment, serve its KMIP keys, or rotate its master keys
+// args are "setup [-kmip] [-cipher mode] <deployment>", "kmip [-addr a] <deployment>" or "rotate <deployment>"
+func encryptionCmd(args []string, v *version.Version, isWindows bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: encryption setup|kmip|rotate ...")
+	}
+	switch args[0] {
+	case "setup":
+		return encryptionSetup(args[1:], isWindows)
+	case "kmip":
+		return kmipServe(args[1:])
+	case "rotate":
+		return encryptionRotate(args[1:], v, isWindows)
+	}
+	return fmt.Errorf("unknown encryption command '%s', must be setup, kmip or rotate", args[0])
+}
+
+// Turn on encryption at rest for every member of a deployment, with local key files or master keys on a KMIP server
+// Encryption can only be turned on for empty data directories; members that already have data must be resynced.
+func encryptionSetup(args []string, isWindows bool) error {
+	fs := flag.NewFlagSet("encryption setup", flag.ContinueOnError)
+	useKMIP := fs.Bool("kmip", false, "Keep master keys on the local KMIP server instead of in key files")
+	addr := fs.String("addr", config.LocalKMIPServer, "Address of the KMIP server")
+	cipher := fs.String("cipher", "", "security.encryptionCipherMode, AES256-CBC or AES256-GCM")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: encryption setup [-kmip] [-addr a] [-cipher mode] <deployment>")
+	}
+	host, port, err := splitKMIPAddr(*addr)
+	if err != nil {
+		return err
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		if files, _ := filepath.Glob(filepath.Join(m.Config.Storage.DbPath, "*.wt")); len(files) > 0 && !m.Config.EncryptionEnabled() {
+			fmt.Printf("%s: already has unencrypted data in %s, remove it before starting the member again\n", m.Name, m.Config.Storage.DbPath)
+		}
+		s := &m.Config.Security
+		s.EnableEncryption = true
+		if *cipher != "" {
+			s.EncryptionCipherMode = *cipher
+		}
+		if *useKMIP {
+			s.EncryptionKeyFile = ""
+			s.Kmip.ServerName = host
+			s.Kmip.Port = port
+			setIfEmpty(&s.Kmip.ClientCertificateFile, filepath.Join(d.MemberPath(m.Name), kmipClientFileName))
+		} else {
+			s.Kmip.ServerName, s.Kmip.Port, s.Kmip.KeyIdentifier = "", 0, ""
+			s.Kmip.ClientCertificateFile, s.Kmip.ServerCAFile = "", ""
+			setIfEmpty(&s.EncryptionKeyFile, d.EncryptionKeyFile(m.Name))
+		}
+	}
+	err = ensureCerts(d)
+	if err != nil {
+		return err
+	}
+	err = d.Write(isWindows)
+	if err != nil {
+		return err
+	}
+	if *useKMIP {
+		fmt.Printf("Deployment %s now keeps its master keys on %s; start the server with \"encryption kmip %s\" before the members\n", d.Name, *addr, d.Name)
+	} else {
+		fmt.Printf("Deployment %s now encrypts its data with a key file for each member\n", d.Name)
+	}
+	return nil
+}
+
+// the host and port of a KMIP server address; port 0 is the default port
+func splitKMIPAddr(addr string) (string, uint, error) {
+	host, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad KMIP server address %s: %v", addr, err)
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad KMIP server port %s: %v", portString, err)
+	}
+	if port == 5696 {
+		port = 0
+	}
+	return host, uint(port), nil
+}
+
+// Serve KMIP in the foreground for a deployment's members, logging every operation, until interrupted
+// The server's certificate is issued by the deployment's CA, which must also have issued the members' certificates.
+func kmipServe(args []string) error {
+	fs := flag.NewFlagSet("encryption kmip", flag.ContinueOnError)
+	addr := fs.String("addr", config.LocalKMIPServer, "Address to listen on")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: encryption kmip [-addr a] <deployment>")
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	caFile := filepath.Join(d.Path, caFileName)
+	ca, err := ensureCA(d, caFile)
+	if err != nil {
+		return err
+	}
+	serverFile := filepath.Join(d.Path, kmipServerFileName)
+	if missingInside(d, serverFile) {
+		err = ca.IssueFile(serverFile, certs.Server, "kmip", certs.Options{Hosts: certs.LocalHosts(), Unit: "kmip"})
+		if err != nil {
+			return err
+		}
+	}
+	tlsConfig, err := certs.ServerTLS(caFile, serverFile)
+	if err != nil {
+		return err
+	}
+	keys, err := kmip.OpenKeyStore(filepath.Join(d.Path, kmipKeysFileName))
+	if err != nil {
+		return err
+	}
+	s := &kmip.Server{Keys: keys, Log: log.New(os.Stdout, "kmip: ", log.LstdFlags)}
+	fmt.Printf("Serving %d keys for deployment %s on %s, press Ctrl-C to stop\n", len(keys.IDs()), d.Name, *addr)
+	return s.ListenAndServe(*addr, tlsConfig)
+}
+
+// Rotate the KMIP master key of every member of a deployment, one member at a time
+// Each member is shut down, run once with kmipRotateMasterKey to re-encrypt its keystore with a new master key created
+// on the KMIP server, and started again. Members with local key files can only be re-keyed by resyncing them.
+func encryptionRotate(args []string, v *version.Version, isWindows bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: encryption rotate <deployment>")
+	}
+	d, err := deploy.Open(runtimePath, args[0])
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		if !m.Config.EncryptionEnabled() || !m.Config.KMIPEnabled() {
+			fmt.Printf("%s: master key is not on a KMIP server, skipped\n", m.Name)
+			continue
+		}
+		if m.Config.Security.Kmip.KeyIdentifier != "" {
+			// mongod records the new key with the data; the old identifier would now be refused
+			fmt.Printf("%s: %v\n", m.Name, config.Change{Key: "security.kmip.keyIdentifier", Old: m.Config.Security.Kmip.KeyIdentifier, Reason: "replaced by the rotated key"})
+			m.Config.Security.Kmip.KeyIdentifier = ""
+		}
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+		}
+		err = shutdownServer(client)
+		_ = client.Disconnect(context.Background())
+		if err != nil {
+			return fmt.Errorf("error shutting down %s: %v", m.Name, err)
+		}
+		err = waitForPort(m.HostPort(), false)
+		if err != nil {
+			return err
+		}
+		err = d.Write(isWindows)
+		if err != nil {
+			return err
+		}
+		mv, err := memberVersion(v, d, m)
+		if err != nil {
+			return err
+		}
+		rotate, err := mongodCommand(mv, d, m, isWindows, "--kmipRotateMasterKey")
+		if err != nil {
+			return err
+		}
+		out, err := rotate.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("rotating the master key of %s failed (%v), see %s:\n%s", m.Name, err, m.Config.SystemLog.Path, out)
+		}
+		err = startMember(mv, d, m, isWindows)
+		if err == nil {
+			err = waitForPort(m.HostPort(), true)
+		}
+		if err != nil {
+			return fmt.Errorf("error restarting %s: %v", m.Name, err)
+		}
+		fmt.Printf("%s: master key rotated\n", m.Name)
+	}
+	return nil
+}
+
+// issue the KMIP client certificates named in the members' configs that don't exist yet
+// Members without a KMIP server CA file are given the deployment's CA, which issues the local KMIP server's certificate.
+func ensureKMIPCerts(d *deploy.Deployment) error {
+	for _, m := range d.Members {
+		k := &m.Config.Security.Kmip
+		if !m.Config.KMIPEnabled() {
+			continue
+		}
+		setIfEmpty(&k.ServerCAFile, filepath.Join(d.Path, caFileName))
+		if !missingInside(d, k.ClientCertificateFile) {
+			continue
+		}
+		ca, err := ensureCA(d, k.ServerCAFile)
+		if err != nil {
+			return err
+		}
+		err = ca.IssueFile(k.ClientCertificateFile, certs.Client, m.Name, certs.Options{Unit: d.Name})
+		if err != nil {
+			return fmt.Errorf("member %s: %v", m.Name, err)
+		}
+	}
+	return nil
+}
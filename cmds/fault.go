@@ -0,0 +1,172 @@
+package cmds
+
+import (
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/scenario"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fault command actions and the scenario steps they run
+var faultActions = map[string]string{
+	"kill":     scenario.Kill,
+	"pause":    scenario.Pause,
+	"resume":   scenario.Resume,
+	"restart":  scenario.Restart,
+	"freeze":   scenario.Freeze,
+	"stepdown": scenario.StepDown,
+}
+
+const faultUsage = `usage: fault kill|pause|resume|restart <deployment>/<member>
+       fault freeze <deployment>/<member> [seconds]
+       fault stepdown <deployment>[/<replica set>] [seconds]
+       fault list|clear <deployment>
+       fault replay [-report file] [-keep-going] <deployment>`
+
+// Inject faults into the members of a running deployment, recording each so the sequence can be listed as the steps of
+// a scenario, or replayed with the same timing
+// Members are found by the pid files they write, so a paused member can still be resumed, killed or restarted.
+func faultCmd(args []string, v *version.Version, isWindows bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf(faultUsage)
+	}
+	switch args[0] {
+	case "list", "clear":
+		if len(args) != 2 {
+			return fmt.Errorf(faultUsage)
+		}
+		d, err := deploy.Open(runtimePath, args[1])
+		if err != nil {
+			return err
+		}
+		if args[0] == "clear" {
+			err = os.Remove(d.FaultLog())
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			fmt.Printf("Cleared the faults recorded for %s\n", d.Name)
+			return nil
+		}
+		events, err := scenario.ReadEvents(d.FaultLog())
+		if err != nil {
+			return err
+		}
+		if len(scenario.Replay(events).Steps) == 0 {
+			fmt.Printf("No faults recorded for %s\n", d.Name)
+			return nil
+		}
+		fmt.Printf("# faults injected into %s, replayed by: fault replay %s\n", d.Name, d.Name)
+		fmt.Print(scenario.FormatEvents(events))
+		return nil
+	case "replay":
+		return faultReplay(args[1:], v, isWindows)
+	}
+
+	action := faultActions[args[0]]
+	if action == "" || len(args) < 2 || len(args) > 3 || (len(args) == 3 && action != scenario.Freeze && action != scenario.StepDown) {
+		return fmt.Errorf(faultUsage)
+	}
+	name, target := args[1], ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		name, target = name[:i], name[i+1:]
+	}
+	if target == "" && action != scenario.StepDown {
+		return fmt.Errorf(faultUsage)
+	}
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return err
+	}
+	step := &scenario.Step{Action: action, Member: target}
+	if action == scenario.StepDown {
+		step.Member, step.ReplSet = "", target
+	}
+	if action == scenario.StepDown || action == scenario.Freeze {
+		step.Seconds = 60
+	}
+	if len(args) == 3 {
+		step.Seconds, err = strconv.Atoi(args[2])
+		if err != nil || step.Seconds < 0 {
+			return fmt.Errorf("bad number of seconds '%s'", args[2])
+		}
+	}
+
+	events, err := scenario.ReadEvents(d.FaultLog())
+	if err != nil {
+		return err
+	}
+	releases := eventReleases(events)
+	e := &scenario.Event{Time: time.Now(), Action: action, Member: step.Member, ReplSet: step.ReplSet, Seconds: step.Seconds}
+	if m := d.Member(step.Member); m != nil && (action == scenario.Kill || action == scenario.Pause) {
+		if r, err := memberRelease(d, m); err == nil {
+			e.Release = r.String()
+		}
+	}
+	member, _, err := runStep(d, step, releases, v, isWindows, 0)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if rerr := scenario.RecordEvent(d.FaultLog(), e); rerr != nil {
+		fmt.Printf("Error: %v\n", rerr)
+	}
+	if err != nil {
+		return err
+	}
+	desc := step.String()
+	if member != "" && member != step.Member {
+		desc += " (" + member + ")"
+	}
+	fmt.Printf("%s: done\n", desc)
+	return nil
+}
+
+// replay the faults recorded for a deployment as a scenario, waiting between them as long as they were apart
+func faultReplay(args []string, v *version.Version, isWindows bool) error {
+	fs := flag.NewFlagSet("fault replay", flag.ContinueOnError)
+	reportFile := fs.String("report", "", "File to write the report to (default <deployment>-faults-<time>.report)")
+	keepGoing := fs.Bool("keep-going", false, "Inject every fault, even after one fails")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf(faultUsage)
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	events, err := scenario.ReadEvents(d.FaultLog())
+	if err != nil {
+		return err
+	}
+	sc := scenario.Replay(events)
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("no faults recorded for %s", d.Name)
+	}
+	if *reportFile == "" {
+		*reportFile = fmt.Sprintf("%s-faults-%s.report", d.Name, time.Now().Format("20060102-150405"))
+	}
+	releases := eventReleases(events)
+	for m, r := range runningReleases(d) {
+		releases[m] = r
+	}
+	// a replay is not recorded: the faults are already in the log
+	return runScenario(d, sc, d.FaultLog(), releases, *reportFile, *keepGoing, 0, v, isWindows)
+}
+
+// the release each member was last seen running when a fault was injected
+func eventReleases(events []*scenario.Event) map[string]string {
+	releases := make(map[string]string)
+	for _, e := range events {
+		if e.Release != "" {
+			releases[e.Member] = e.Release
+		}
+	}
+	return releases
+}
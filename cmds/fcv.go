@@ -0,0 +1,155 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+// Show or set the featureCompatibilityVersion of a running deployment
+// args are "get <deployment>" or "set <deployment> <x.y>"
+func fcvCmd(args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "get":
+		return fcvGet(args[1])
+	case len(args) == 3 && args[0] == "set":
+		return fcvSet(args[1], args[2])
+	}
+	return fmt.Errorf("usage: fcv get <deployment> | fcv set <deployment> <x.y>")
+}
+
+// Show the featureCompatibilityVersion of each replica set and standalone in a deployment, along with the release it
+// runs; in a sharded cluster that is the config servers and each shard, which should all agree
+func fcvGet(name string) error {
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, g := range memberGroups(d) {
+		label, m := g.name, g.members[0]
+		switch g.kind {
+		case routerGroup:
+			continue // mongos has no featureCompatibilityVersion of its own
+		case standaloneGroup:
+			label = m.Name
+		case configGroup:
+			label += " (config servers)"
+		default:
+			if m.Config.Sharding.ClusterRole == "shardsvr" {
+				label += " (shard)"
+			}
+		}
+		if g.name != "" {
+			m, err = findPrimary(d, g)
+			if err != nil {
+				fmt.Printf("%-28s %v\n", label, err)
+				continue
+			}
+		}
+		r, err := memberRelease(d, m)
+		if err != nil {
+			fmt.Printf("%-28s not running (%v)\n", label, err)
+			continue
+		}
+		if !r.HasFCV() {
+			fmt.Printf("%-28s running %s, which has no featureCompatibilityVersion\n", label, r)
+			continue
+		}
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			fmt.Printf("%-28s error connecting to %s: %v\n", label, m.Name, err)
+			continue
+		}
+		fcv, err := getFCV(client)
+		_ = client.Disconnect(context.Background())
+		if err != nil {
+			fmt.Printf("%-28s %v\n", label, err)
+			continue
+		}
+		seen[fcv] = true
+		fmt.Printf("%-28s featureCompatibilityVersion %s, running %s\n", label, fcv, r)
+	}
+	if len(seen) > 1 {
+		fmt.Printf("Warning: the featureCompatibilityVersion differs across deployment %s\n", d.Name)
+	}
+	return nil
+}
+
+// Set a deployment's featureCompatibilityVersion, after checking every member's binaries support it
+func fcvSet(name string, fcv string) error {
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		r, err := memberRelease(d, m)
+		if err != nil {
+			return fmt.Errorf("member %s must be running: %v", m.Name, err)
+		}
+		valid := r.FCVs()
+		if len(valid) == 0 {
+			return fmt.Errorf("member %s runs %s, which has no featureCompatibilityVersion", m.Name, r)
+		}
+		found := false
+		for _, f := range valid {
+			found = found || f == fcv
+		}
+		if !found {
+			return fmt.Errorf("member %s runs %s, which only supports featureCompatibilityVersion %s", m.Name, r, strings.Join(valid, " or "))
+		}
+	}
+	err = setDeploymentFCV(d, memberGroups(d), fcv)
+	if err != nil {
+		return err
+	}
+	return fcvGet(name)
+}
+
+// the featureCompatibilityVersion of a running node, e.g. "4.2"
+// While it is being changed the target follows, e.g. "4.2 (upgrading to 4.4)".
+func getFCV(client *mongo.Client) (string, error) {
+	var result struct {
+		FCV bson.RawValue `bson:"featureCompatibilityVersion"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := client.Database("admin").RunCommand(ctx, bson.D{{"getParameter", 1}, {"featureCompatibilityVersion", 1}}).Decode(&result)
+	if err != nil {
+		return "", fmt.Errorf("error getting featureCompatibilityVersion: %v", err)
+	}
+	if s, ok := result.FCV.StringValueOK(); ok { // 3.4 and 3.6.0
+		return s, nil
+	}
+	doc, ok := result.FCV.DocumentOK()
+	if !ok {
+		return "", fmt.Errorf("unexpected featureCompatibilityVersion %v", result.FCV)
+	}
+	fcv, ok := doc.Lookup("version").StringValueOK()
+	if !ok {
+		return "", fmt.Errorf("unexpected featureCompatibilityVersion %v", doc)
+	}
+	if target, ok := doc.Lookup("targetVersion").StringValueOK(); ok {
+		if target > fcv {
+			fcv += " (upgrading to " + target + ")"
+		} else {
+			fcv += " (downgrading to " + target + ")"
+		}
+	}
+	return fcv, nil
+}
+
+// set the featureCompatibilityVersion of a replica set or standalone through its primary, or of a cluster through a mongos
+func setFCV(client *mongo.Client, fcv string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	err := client.Database("admin").RunCommand(ctx, bson.D{{"setFeatureCompatibilityVersion", fcv}}).Err()
+	if err != nil {
+		return fmt.Errorf("error setting featureCompatibilityVersion to %s: %v", fcv, err)
+	}
+	return nil
+}
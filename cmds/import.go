@@ -0,0 +1,105 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Import a customer's mongod.conf, the output of getCmdLineOpts or a mongod command line as a member of a deployment
+// args are the file, the deployment name and optionally the member name (default "mongod")
+func importConfig(args []string, v *version.Version, isWindows bool) error {
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: config import <file> <deployment> [member]")
+	}
+	fn, name, member := args[0], args[1], "mongod"
+	if len(args) == 3 {
+		member = args[2]
+	}
+	in, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", fn, err)
+	}
+	var cfg *config.Type
+	var unknown []string
+	trimmed := bytes.TrimSpace(in)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		cfg, unknown, err = config.FromCmdLineOpts(in)
+	} else if isCommandLine(trimmed, isWindows) {
+		var argv []string
+		argv, err = config.SplitCommandLine(string(trimmed), isWindows)
+		if err == nil {
+			cfg, unknown, err = config.FromArgs(argv)
+		}
+	} else {
+		cfg, unknown, err = config.FromYaml(in)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", fn, err)
+	}
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		// only a new deployment starts empty: one that can't be read would be overwritten
+		d = deploy.New(runtimePath, name)
+		if _, serr := os.Stat(d.Path); !os.IsNotExist(serr) {
+			return err
+		}
+	}
+	changes := config.Localize(cfg, unknown, d.MemberPath(member))
+	if cfg.Security.KeyFile != "" && cfg.Security.KeyFile != d.KeyFile() {
+		// the customer's key isn't available, and members must share one to authenticate to each other
+		changes = append(changes, config.Change{Key: "security.keyFile", Old: cfg.Security.KeyFile, New: d.KeyFile(), Reason: "shared by the deployment's members"})
+		cfg.Security.KeyFile = d.KeyFile()
+	}
+	if ca := cfg.TLS().CAFile; *ca != "" && *ca != filepath.Join(d.Path, caFileName) {
+		changes = append(changes, config.Change{Key: "net.tls.CAFile", Old: *ca, New: filepath.Join(d.Path, caFileName), Reason: "shared by the deployment's members"})
+		*ca = filepath.Join(d.Path, caFileName)
+	}
+	if ca := &cfg.Security.Kmip.ServerCAFile; cfg.KMIPEnabled() && *ca != filepath.Join(d.Path, caFileName) {
+		// the local KMIP server's certificate is issued by the deployment's CA
+		changes = append(changes, config.Change{Key: "security.kmip.serverCAFile", Old: *ca, New: filepath.Join(d.Path, caFileName), Reason: "shared by the deployment's members"})
+		*ca = filepath.Join(d.Path, caFileName)
+	}
+	renamed, err := cfg.Validate(v)
+	if err != nil {
+		return err
+	}
+	changes = append(changes, renamed...)
+	d.Add(member, cfg)
+	err = ensureCerts(d)
+	if err != nil {
+		return err
+	}
+	err = d.Write(isWindows)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s as member %s of deployment %s\n", fn, member, name)
+	if len(changes) == 0 {
+		fmt.Printf("No changes were needed\n")
+	}
+	for _, c := range changes {
+		fmt.Printf("  %v\n", c)
+	}
+	return nil
+}
+
+// whether a file holds a mongod or mongos command line rather than YAML
+func isCommandLine(in []byte, isWindows bool) bool {
+	argv, err := config.SplitCommandLine(string(in), isWindows)
+	if err != nil || len(argv) == 0 {
+		return false
+	}
+	if strings.HasPrefix(argv[0], "--") {
+		return true
+	}
+	prog := argv[0][strings.LastIndexAny(argv[0], `/\`)+1:] // a Windows path, even on other systems
+	prog = strings.TrimSuffix(prog, ".exe")
+	return prog == "mongod" || prog == "mongos"
+}
@@ -0,0 +1,81 @@
+package cmds
+
+import (
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Before deployments, config wrote a single standalone straight into the runtime directory:
+//
+//	<runtime>/sa.yaml	its config file, with a side file next to it
+//	<runtime>/data/	its data directory
+//	<runtime>/sa.log	its log file
+//
+// It is moved into the deployment sa, where config now puts it, so run and stop find it again.
+func migrateOldLayout(isWindows bool) error {
+	oldConfig := filepath.Join(runtimePath, "sa.yaml")
+	cfgbytes, err := ioutil.ReadFile(oldConfig)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", oldConfig, err)
+	}
+	cfg, _, err := config.FromYaml(cfgbytes)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", oldConfig, err)
+	}
+	d := deploy.New(runtimePath, "sa")
+	if _, err := os.Stat(d.Path); err == nil {
+		return fmt.Errorf("%s was written by an older version, but deployment sa exists too; remove one of them", oldConfig)
+	}
+	m := d.Add("sa", cfg)
+	if conn, err := net.DialTimeout("tcp", m.HostPort(), time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("the standalone in %s is running; shut it down so it can be moved into deployment sa", runtimePath)
+	}
+	err = os.MkdirAll(d.MemberPath(m.Name), 0777)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", d.MemberPath(m.Name), err)
+	}
+	// files the older version put in the runtime directory move with it; any the config points elsewhere stay put
+	moves := []struct {
+		path *string
+		to   string
+	}{
+		{&cfg.Storage.DbPath, filepath.Join(d.MemberPath(m.Name), "data")},
+		{&cfg.SystemLog.Path, filepath.Join(d.MemberPath(m.Name), "mongod.log")},
+	}
+	for _, mv := range moves {
+		if *mv.path == "" || filepath.Dir(*mv.path) != runtimePath {
+			continue
+		}
+		err = os.Rename(*mv.path, mv.to)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error moving %s: %v", *mv.path, err)
+		}
+		*mv.path = mv.to
+	}
+	if cfg.ProcessManagement.PidFilePath == "" {
+		cfg.ProcessManagement.PidFilePath = filepath.Join(d.MemberPath(m.Name), "mongod.pid")
+	}
+	err = d.Write(isWindows)
+	if err != nil {
+		return err
+	}
+	old, _ := filepath.Glob(oldConfig + ".*")
+	for _, fn := range append(old, oldConfig) {
+		err = os.Remove(fn)
+		if err != nil {
+			return fmt.Errorf("error removing %s: %v", fn, err)
+		}
+	}
+	fmt.Printf("Moved the standalone in %s into deployment sa\n", runtimePath)
+	return nil
+}
@@ -0,0 +1,141 @@
+package cmds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/ldap"
+	"github.com/SpencerBrown/mongodb-repro/staticContent"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// The built-in directory (staticContent/ldap_directory.ldif) and how mongod is set up to use it
+const ldapQueryUser = "cn=query,dc=repro,dc=test"
+const ldapQueryPassword = "query-password"
+const ldapUserToDNMapping = `[{match: "(.+)", substitution: "uid={0},ou=users,dc=repro,dc=test"}]`
+const ldapQueryTemplate = "ou=groups,dc=repro,dc=test??one?(member={USER})"
+
+// roles granted to the built-in directory's groups, named by group DN as LDAP authorization requires
+var ldapGroupRoles = map[string]string{
+	"cn=dba,ou=groups,dc=repro,dc=test":     "root",
+	"cn=readers,ou=groups,dc=repro,dc=test": "readAnyDatabase",
+}
+
+// Run the local LDAP server, or set up a deployment to use it
+// args are "serve [-addr a] [-ldif file] [-anonymous]" or "setup [-addr a] <deployment>"
+func ldapCmd(args []string, v *version.Version, isWindows bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: ldap serve|setup ...")
+	}
+	switch args[0] {
+	case "serve":
+		return ldapServe(args[1:])
+	case "setup":
+		return ldapSetup(args[1:], v, isWindows)
+	}
+	return fmt.Errorf("unknown ldap command '%s', must be serve or setup", args[0])
+}
+
+// Serve a directory over LDAP in the foreground, logging every request, until interrupted
+func ldapServe(args []string) error {
+	fs := flag.NewFlagSet("ldap serve", flag.ContinueOnError)
+	addr := fs.String("addr", config.LocalLDAPServer, "Address to listen on")
+	ldif := fs.String("ldif", "", "LDIF file to serve instead of the built-in directory")
+	anonymous := fs.Bool("anonymous", false, "Allow searches without binding first")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	content := []byte(staticContent.Ldap_directory_ldif)
+	if *ldif != "" {
+		content, err = ioutil.ReadFile(*ldif)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", *ldif, err)
+		}
+	}
+	dir, err := ldap.ParseLDIF(content)
+	if err != nil {
+		return fmt.Errorf("error parsing LDIF: %v", err)
+	}
+	s := &ldap.Server{Dir: dir, Anonymous: *anonymous, Log: log.New(os.Stdout, "ldap: ", log.LstdFlags)}
+	fmt.Printf("Serving %d entries on %s, press Ctrl-C to stop\n", len(dir.Entries), *addr)
+	return s.ListenAndServe(*addr)
+}
+
+// Point every member of a deployment at the local LDAP server with the built-in directory's users and groups
+// Members that are running get the roles for the directory's groups now; the new settings apply once they restart.
+// Members not running are taken to run release v.
+func ldapSetup(args []string, v *version.Version, isWindows bool) error {
+	fs := flag.NewFlagSet("ldap setup", flag.ContinueOnError)
+	addr := fs.String("addr", config.LocalLDAPServer, "Address of the LDAP server")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ldap setup [-addr a] <deployment>")
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		ldapSettings := &m.Config.Security.Ldap
+		ldapSettings.Servers = *addr
+		ldapSettings.TransportSecurity = "none"
+		ldapSettings.Bind.Method = "simple"
+		ldapSettings.Bind.QueryUser = ldapQueryUser
+		ldapSettings.Bind.QueryPassword = ldapQueryPassword
+		ldapSettings.UserToDNMapping = ldapUserToDNMapping
+		ldapSettings.Authz.QueryTemplate = ldapQueryTemplate
+		if m.Config.SetParameter == nil {
+			m.Config.SetParameter = config.Parameters{}
+		}
+		r := v.Release
+		if mr, err := memberRelease(d, m); err == nil {
+			r = mr
+		}
+		m.Config.SetParameter["authenticationMechanisms"] = strings.Join(m.Config.LDAPMechanisms(r), ",")
+	}
+	err = d.Write(isWindows)
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		err = setupLDAPRoles(d, m)
+		if err != nil {
+			fmt.Printf("%s: roles not created (%v), they will be when it is next run\n", m.Name, err)
+		}
+	}
+	fmt.Printf("Deployment %s now uses the LDAP server on %s; start it with \"ldap serve\" and restart the deployment\n", d.Name, *addr)
+	fmt.Printf("Log in as alice or bob on the $external database with mechanism PLAIN, e.g. password alice-password\n")
+	return nil
+}
+
+// create a role for each of the built-in directory's groups on a running member, skipping those that exist
+func setupLDAPRoles(d *deploy.Deployment, m *deploy.Member) error {
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	db := client.Database("admin")
+	for group, role := range ldapGroupRoles {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cmd := bson.D{{"createRole", group}, {"privileges", bson.A{}}, {"roles", bson.A{bson.D{{"role", role}, {"db", "admin"}}}}}
+		err = db.RunCommand(ctx, cmd).Err()
+		cancel()
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("error creating role %s: %v", group, err)
+		}
+	}
+	return nil
+}
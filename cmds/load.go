@@ -0,0 +1,106 @@
+package cmds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/dump"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Load a mongodump archive, a mongodump directory or .bson file, or a mongoexport JSON file into a running deployment
+// Indexes are restored from the dump's metadata after the documents are inserted.
+// args are [-drop] [-batch n] [-ns db.collection] <deployment[/member]> <file or directory>
+func loadCmd(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	drop := fs.Bool("drop", false, "Drop each collection before loading it")
+	batch := fs.Int("batch", 1000, "Documents per insert")
+	ns := fs.String("ns", "", "Collection to load a JSON file into (default test.<file name>)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *batch < 1 {
+		return fmt.Errorf("usage: load [-drop] [-batch n] [-ns db.collection] <deployment[/member]> <archive, dump directory, .bson or .json file>")
+	}
+	path := fs.Arg(1)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	d, m, err := dataMember(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	start := time.Now()
+	loader := dump.NewLoader(client, *drop, *batch)
+	base := filepath.Base(path)
+	switch {
+	case fi.IsDir() || strings.HasSuffix(base, ".bson") || strings.HasSuffix(base, ".bson.gz"):
+		err = dump.ReadDir(path, loader)
+	case strings.HasSuffix(base, ".json"):
+		c, err2 := jsonCollection(*ns, base)
+		if err2 != nil {
+			return err2
+		}
+		err = readFile(path, func(f *os.File) error { return dump.ReadJSON(f, c, loader) })
+	default:
+		err = readFile(path, func(f *os.File) error { return dump.ReadArchive(f, loader) })
+	}
+	if err != nil {
+		return fmt.Errorf("error loading %s: %v", path, err)
+	}
+	loaded, err := loader.Finish()
+	if err != nil {
+		return err
+	}
+	total := int64(0)
+	for _, ld := range loaded {
+		switch {
+		case ld.Skipped != "":
+			fmt.Printf("%s: skipped, %s\n", ld.Namespace(), ld.Skipped)
+		case ld.IsView():
+			fmt.Printf("%s: view created\n", ld.Namespace())
+		default:
+			fmt.Printf("%s: %d documents, %d indexes", ld.Namespace(), ld.Documents, len(ld.Indexes))
+			if ld.Duplicates > 0 {
+				fmt.Printf(", %d duplicates skipped", ld.Duplicates)
+			}
+			fmt.Println()
+			total += ld.Documents
+		}
+	}
+	fmt.Printf("Loaded %d documents into %d collections through %s in %v\n", total, len(loaded), m.Name,
+		time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// the collection a mongoexport file is loaded into: ns if given, else test.<file name without .json>
+func jsonCollection(ns string, base string) (*dump.Collection, error) {
+	if ns == "" {
+		return &dump.Collection{Database: "test", Name: strings.TrimSuffix(base, ".json")}, nil
+	}
+	i := strings.Index(ns, ".")
+	if i <= 0 || i == len(ns)-1 {
+		return nil, fmt.Errorf("-ns must be database.collection, not %q", ns)
+	}
+	return &dump.Collection{Database: ns[:i], Name: ns[i+1:]}, nil
+}
+
+func readFile(path string, read func(f *os.File) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return read(f)
+}
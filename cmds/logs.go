@@ -0,0 +1,207 @@
+package cmds
+
+import (
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/logs"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Print the entries in the logs of a deployment, one of its members, or a customer's log file that pass the filters
+// Entries from several members are merged in time order.
+func logsCmd(args []string, isWindows bool) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	severity := fs.String("severity", "", "Only entries at this severity or worse: F, E, W, I or D1 to D5")
+	components := fs.String("component", "", "Only entries from these components, comma separated, e.g. NETWORK,REPL")
+	ids := fs.String("id", "", "Only entries with these message ids, comma separated")
+	conns := fs.String("conn", "", "Only entries from these connections or threads, comma separated, e.g. 12,conn13,initandlisten")
+	since := fs.String("since", "", "Only entries at or after this time (e.g. 2021-03-04T10:00:00Z, or 15m for 15 minutes ago)")
+	until := fs.String("until", "", "Only entries before this time")
+	slow := fs.Int("slow", 0, "Only operations that took at least this many milliseconds")
+	short := fs.Bool("short", false, "One line per entry, without its attributes")
+	follow := fs.Bool("f", false, "Follow the logs as they grow, until interrupted")
+	color := fs.Bool("color", !isWindows, "Color each member's entries differently when following logs")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: logs [-severity s] [-component c] [-id n] [-conn c] [-since t] [-until t] [-slow ms] [-short] [-f] <deployment[/member]|file>")
+	}
+	filter, err := logFilter(*severity, *components, *ids, *conns, *since, *until, *slow)
+	if err != nil {
+		return err
+	}
+	files, err := resolveMemberFiles(fs.Arg(0), "log", memberLogFile)
+	if err != nil {
+		return err
+	}
+	if *follow {
+		return followLogs(files, filter, *short, *color)
+	}
+
+	type memberEntry struct {
+		member string
+		entry  *logs.Entry
+	}
+	var selected []memberEntry
+	for _, f := range files {
+		in, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		err = logs.Parse(in, func(e *logs.Entry) error {
+			if filter.Match(e) {
+				selected = append(selected, memberEntry{f.Member, e})
+			}
+			return nil
+		})
+		_ = in.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Path, err)
+		}
+	}
+	if len(files) > 1 {
+		sort.SliceStable(selected, func(i, j int) bool { return selected[i].entry.Time.Before(selected[j].entry.Time) })
+	}
+	width := memberWidth(files)
+	for _, me := range selected {
+		prefix := ""
+		if width > 0 {
+			prefix = fmt.Sprintf("%-*s ", width, me.member)
+		}
+		fmt.Printf("%s%s\n", prefix, me.entry.Header())
+		if attr := me.entry.PrettyAttr(prefix + "    "); attr != "" && !*short {
+			fmt.Println(attr)
+		}
+	}
+	fmt.Printf("%d entries\n", len(selected))
+	return nil
+}
+
+// the log filter described by the logs command's flags
+func logFilter(severity, components, ids, conns, since, until string, slow int) (*logs.Filter, error) {
+	if severity != "" && !logs.ValidSeverity(severity) {
+		return nil, fmt.Errorf("bad severity '%s', must be F, E, W, I or D1 to D5", severity)
+	}
+	filter := &logs.Filter{Severity: severity, Components: splitList(components), Contexts: splitList(conns), SlowMS: slow}
+	for _, s := range splitList(ids) {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad log id '%s'", s)
+		}
+		filter.IDs = append(filter.IDs, id)
+	}
+	var err error
+	filter.Since, err = parseTimeArg(since)
+	if err != nil {
+		return nil, err
+	}
+	filter.Until, err = parseTimeArg(until)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// the file a member logs to, "" if it logs to syslog or the console
+func memberLogFile(m *deploy.Member) string {
+	if m.Config.SystemLog.Destination != "file" {
+		return ""
+	}
+	return m.Config.SystemLog.Path
+}
+
+// the width of the longest member name among files, 0 if none are named
+func memberWidth(files []memberFile) int {
+	width := 0
+	for _, f := range files {
+		if len(f.Member) > width {
+			width = len(f.Member)
+		}
+	}
+	return width
+}
+
+// ANSI colors for members' entries when following logs: red, green, yellow, blue, magenta, cyan
+var memberColors = []string{"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m"}
+
+const colorReset = "\x1b[0m"
+
+// how long entries are held so that those from different members come out in time order
+const followDelay = 300 * time.Millisecond
+
+// Print entries as they are added to the logs, interleaved in time order, until interrupted
+// Entries already in the logs are skipped unless filter has a start time.
+func followLogs(files []memberFile, filter *logs.Filter, short bool, color bool) error {
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	merger := &logs.Merger{Delay: followDelay}
+	errs := make(chan error, len(files))
+	var wg sync.WaitGroup
+	for _, f := range files {
+		wg.Add(1)
+		go func(f memberFile) {
+			defer wg.Done()
+			g := new(logs.Grouper)
+			err := logs.Tail(f.Path, !filter.Since.IsZero(), 100*time.Millisecond, stop, func(lines []string) {
+				var selected []*logs.Entry
+				for _, e := range g.Add(lines) {
+					if filter.Match(e) {
+						selected = append(selected, e)
+					}
+				}
+				merger.Add(f.Member, selected)
+			})
+			if err != nil {
+				errs <- fmt.Errorf("%s: %v", f.Path, err)
+			}
+		}(f)
+	}
+
+	colors := make(map[string]string)
+	for i, f := range files {
+		colors[f.Member] = memberColors[i%len(memberColors)]
+	}
+	width := memberWidth(files)
+	fmt.Printf("Following %d logs, press Ctrl-C to stop\n", len(files))
+	ticker := time.NewTicker(followDelay / 3)
+	defer ticker.Stop()
+	var err error
+	for err == nil {
+		select {
+		case <-interrupt:
+			close(stop)
+			wg.Wait()
+			return nil
+		case err = <-errs:
+			close(stop)
+			wg.Wait()
+		case now := <-ticker.C:
+			for _, s := range merger.Ready(now) {
+				prefix, start, end := "", "", ""
+				if width > 0 {
+					prefix = fmt.Sprintf("%-*s ", width, s.Source)
+				}
+				if color {
+					start, end = colors[s.Source], colorReset
+				}
+				fmt.Printf("%s%s%s%s\n", start, prefix, s.Header(), end)
+				if attr := s.PrettyAttr(strings.Repeat(" ", len(prefix)+4)); attr != "" && !short {
+					fmt.Printf("%s%s%s\n", start, attr, end)
+				}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package cmds
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// the command line a process runs, "" if there is no such process
+func processArgs(pid int) (string, error) {
+	out, err := exec.Command("ps", "-o", "args=", "-p", strconv.Itoa(pid)).Output()
+	if _, ok := err.(*exec.ExitError); ok && len(out) == 0 { // ps exits 1 if it lists no processes
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error running ps: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// stop a process with SIGSTOP, so it hangs holding its connections open
+func pauseProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGSTOP)
+}
+
+// continue a stopped process with SIGCONT
+func resumeProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGCONT)
+}
@@ -0,0 +1,18 @@
+package cmds
+
+import (
+	"fmt"
+)
+
+// the command line a process runs; Windows doesn't tell, so pid files aren't trusted there
+func processArgs(pid int) (string, error) {
+	return "", fmt.Errorf("reading process %d's command line is not supported on Windows", pid)
+}
+
+func pauseProcess(pid int) error {
+	return fmt.Errorf("pausing a process is not supported on Windows")
+}
+
+func resumeProcess(pid int) error {
+	return fmt.Errorf("resuming a process is not supported on Windows")
+}
@@ -0,0 +1,155 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+	"time"
+)
+
+// kinds of member group, in the order they are started and upgraded
+const (
+	configGroup = iota
+	replSetGroup
+	standaloneGroup
+	routerGroup
+)
+
+// A replica set, or a standalone or mongos on its own
+type memberGroup struct {
+	name    string // replica set name, "" for a standalone or mongos
+	kind    int
+	members []*deploy.Member
+}
+
+// a deployment's members grouped by replica set, in the order they are started and upgraded
+func memberGroups(d *deploy.Deployment) []*memberGroup {
+	var groups []*memberGroup
+	replSets := make(map[string]*memberGroup)
+	for _, m := range d.Members {
+		c := m.Config
+		g := &memberGroup{kind: standaloneGroup}
+		switch {
+		case c.Sharding.ConfigDB != "":
+			g.kind = routerGroup
+		case c.Replication.ReplSetName != "":
+			if rs := replSets[c.Replication.ReplSetName]; rs != nil {
+				rs.members = append(rs.members, m)
+				continue
+			}
+			g.name, g.kind = c.Replication.ReplSetName, replSetGroup
+			if c.Sharding.ClusterRole == "configsvr" {
+				g.kind = configGroup
+			}
+			replSets[g.name] = g
+		}
+		g.members = append(g.members, m)
+		groups = append(groups, g)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].kind < groups[j].kind })
+	return groups
+}
+
+// A running member's replica set state, from isMaster
+type memberState struct {
+	SetName     string `bson:"setName"` // "" until the replica set is initiated
+	IsMaster    bool   `bson:"ismaster"`
+	Secondary   bool   `bson:"secondary"`
+	ArbiterOnly bool   `bson:"arbiterOnly"`
+}
+
+func getMemberState(d *deploy.Deployment, m *deploy.Member) (*memberState, error) {
+	client, err := connectMember(d, m, false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	state := new(memberState)
+	err = client.Database("admin").RunCommand(ctx, bson.D{{"isMaster", 1}}).Decode(state)
+	if err != nil {
+		return nil, fmt.Errorf("error running isMaster: %v", err)
+	}
+	return state, nil
+}
+
+// wait up to a minute for a restarted member to become a primary, secondary or arbiter
+func waitForMember(d *deploy.Deployment, m *deploy.Member) error {
+	var err error
+	for i := 0; i < 60; i++ {
+		var state *memberState
+		state, err = getMemberState(d, m)
+		if err == nil && (state.IsMaster || state.Secondary || state.ArbiterOnly) {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s did not rejoin its replica set", m.Name)
+}
+
+// the current primary of a replica set
+func findPrimary(d *deploy.Deployment, g *memberGroup) (*deploy.Member, error) {
+	for _, m := range g.members {
+		state, err := getMemberState(d, m)
+		if err == nil && state.IsMaster {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("replica set %s has no primary", g.name)
+}
+
+// Initiate a replica set with all of its members, unless it already is
+// It reports whether it initiated the set, in which case the set has no users yet.
+func initiateReplSet(d *deploy.Deployment, g *memberGroup) (bool, error) {
+	m := g.members[0]
+	state, err := getMemberState(d, m)
+	if err != nil {
+		return false, fmt.Errorf("error connecting to %s: %v", m.Name, err)
+	}
+	if state.SetName != "" {
+		return false, nil
+	}
+	var members bson.A
+	for i, m := range g.members {
+		members = append(members, bson.D{{"_id", i}, {"host", m.HostPort()}})
+	}
+	rsConfig := bson.D{{"_id", g.name}}
+	if g.kind == configGroup {
+		rsConfig = append(rsConfig, bson.E{Key: "configsvr", Value: true})
+	}
+	rsConfig = append(rsConfig, bson.E{Key: "members", Value: members})
+	// the set has no users yet, so the localhost exception lets us in
+	client, err := connectMember(d, m, false)
+	if err != nil {
+		return false, fmt.Errorf("error connecting to %s: %v", m.Name, err)
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err = client.Database("admin").RunCommand(ctx, bson.D{{"replSetInitiate", rsConfig}}).Err()
+	if err != nil {
+		return false, fmt.Errorf("error running replSetInitiate: %v", err)
+	}
+	fmt.Printf("Initiated replica set %s\n", g.name)
+	return true, nil
+}
+
+// wait up to a minute for a replica set to elect a primary
+func waitForPrimary(d *deploy.Deployment, g *memberGroup) (*deploy.Member, error) {
+	var err error
+	for i := 0; i < 60; i++ {
+		var primary *deploy.Member
+		primary, err = findPrimary(d, g)
+		if err == nil {
+			return primary, nil
+		}
+		time.Sleep(time.Second)
+	}
+	return nil, err
+}
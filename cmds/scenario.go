@@ -0,0 +1,259 @@
+package cmds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/scenario"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Run the steps of a scenario, from a deployment spec, on a running deployment, reporting each step's result
+// A run stops at the first step that fails, unless it may fail or -keep-going is given. The report is printed and
+// written to a file.
+// args are run [-report file] [-keep-going] [-seed n] <deployment> <spec file>
+func scenarioCmd(args []string, v *version.Version, isWindows bool) error {
+	usage := fmt.Errorf("usage: scenario run [-report file] [-keep-going] [-seed n] <deployment> <spec file>")
+	if len(args) == 0 || args[0] != "run" {
+		return usage
+	}
+	fs := flag.NewFlagSet("scenario run", flag.ContinueOnError)
+	reportFile := fs.String("report", "", "File to write the report to (default <spec name>-<time>.report)")
+	keepGoing := fs.Bool("keep-going", false, "Run every step, even after one fails")
+	randSeed := fs.Int64("seed", 1, "Random seed for the documents insert steps generate")
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usage
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	specFile := fs.Arg(1)
+	in, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return fmt.Errorf("error reading spec: %v", err)
+	}
+	sc, err := scenario.Parse(in)
+	if err != nil {
+		return err
+	}
+	if *reportFile == "" {
+		base := strings.TrimSuffix(filepath.Base(specFile), filepath.Ext(specFile))
+		*reportFile = fmt.Sprintf("%s-%s.report", base, time.Now().Format("20060102-150405"))
+	}
+	// the releases members run now, to start them with again after they are killed
+	return runScenario(d, sc, specFile, runningReleases(d), *reportFile, *keepGoing, *randSeed, v, isWindows)
+}
+
+// run a scenario's steps on a deployment, printing a report and writing it to reportFile
+func runScenario(d *deploy.Deployment, sc *scenario.Scenario, name string, releases map[string]string, reportFile string,
+	keepGoing bool, randSeed int64, v *version.Version, isWindows bool) error {
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return fmt.Errorf("error creating report: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	report := scenario.NewReport(io.MultiWriter(os.Stdout, f), name, d.Name)
+	var prev *scenario.Result
+	for i, step := range sc.Steps {
+		res := &scenario.Result{Step: step, Start: time.Now()}
+		if step.Action == scenario.Assert {
+			if prev == nil {
+				res.Err = fmt.Errorf("nothing to assert about before the first step")
+			} else {
+				res.Err = scenario.Check(step.Expect, prev.Output, prev.Err)
+			}
+		} else {
+			res.Member, res.Output, res.Err = runStep(d, step, releases, v, isWindows, randSeed)
+			prev = res
+		}
+		res.Duration = time.Since(res.Start)
+		report.Add(i+1, res)
+		if res.Failed() && !keepGoing {
+			break
+		}
+	}
+	err = report.Finish()
+	fmt.Printf("Report written to %s\n", reportFile)
+	return err
+}
+
+// run a step other than an assert, returning the member it ran on and its output
+func runStep(d *deploy.Deployment, step *scenario.Step, releases map[string]string, v *version.Version, isWindows bool,
+	randSeed int64) (string, bson.Raw, error) {
+	switch step.Action {
+	case scenario.Command:
+		m, err := stepMember(d, step)
+		if err != nil {
+			return "", nil, err
+		}
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			return m.Name, nil, fmt.Errorf("error connecting to %s: %v", m.Name, err)
+		}
+		defer func() { _ = client.Disconnect(context.Background()) }()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		out, err := client.Database(step.DB).RunCommand(ctx, step.Command).DecodeBytes()
+		return m.Name, out, err
+	case scenario.Insert:
+		m, err := defaultDataMember(d)
+		if err != nil {
+			return "", nil, err
+		}
+		if step.Seed.ShardKey != nil && m.Config.Sharding.ConfigDB == "" {
+			return m.Name, nil, fmt.Errorf("sharding %s.%s needs a mongos", step.Seed.Database, step.Seed.Collection)
+		}
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			return m.Name, nil, fmt.Errorf("error connecting to %s: %v", m.Name, err)
+		}
+		defer func() { _ = client.Disconnect(context.Background()) }()
+		err = seedCollection(client, step.Seed, randSeed, nil)
+		if err != nil {
+			return m.Name, nil, err
+		}
+		out, _ := bson.Marshal(bson.D{{"inserted", step.Seed.Count}})
+		return m.Name, out, nil
+	case scenario.StepDown:
+		g, err := replSet(d, step.ReplSet)
+		if err != nil {
+			return "", nil, err
+		}
+		m, err := findPrimary(d, g)
+		if err != nil {
+			return "", nil, err
+		}
+		return m.Name, nil, stepDown(d, m, step.Seconds)
+	case scenario.Freeze, scenario.Kill, scenario.Pause, scenario.Resume, scenario.Restart, scenario.Start:
+		m := d.Member(step.Member)
+		if m == nil {
+			return "", nil, fmt.Errorf("deployment %s has no member %s", d.Name, step.Member)
+		}
+		return m.Name, nil, memberFault(d, m, step, releases, v, isWindows)
+	case scenario.Wait:
+		time.Sleep(step.Wait)
+		return "", nil, nil
+	}
+	return "", nil, fmt.Errorf("can't run step %s", step.Action)
+}
+
+// run a step acting on one member
+func memberFault(d *deploy.Deployment, m *deploy.Member, step *scenario.Step, releases map[string]string, v *version.Version,
+	isWindows bool) error {
+	switch step.Action {
+	case scenario.Freeze:
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+		}
+		defer func() { _ = client.Disconnect(context.Background()) }()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return client.Database("admin").RunCommand(ctx, bson.D{{"replSetFreeze", step.Seconds}}).Err()
+	case scenario.Kill:
+		return killMember(d, m)
+	case scenario.Pause, scenario.Resume:
+		pid, err := memberPid(d, m)
+		if err != nil {
+			return fmt.Errorf("%s is not running: %v", m.Name, err)
+		}
+		if step.Action == scenario.Pause {
+			return pauseProcess(pid)
+		}
+		return resumeProcess(pid)
+	case scenario.Restart:
+		if r, err := memberRelease(d, m); err == nil {
+			releases[m.Name] = r.String()
+		}
+		if _, err := memberPid(d, m); err == nil {
+			err = stopMember(d, m)
+			if err != nil {
+				fmt.Printf("%v, killing it\n", err) // e.g. it is paused
+				err = killMember(d, m)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// start it, with the release it was running if that's known, else the one it was last started with
+	var mv *version.Version
+	var err error
+	if releases[m.Name] != "" {
+		mv, err = installedVersion(v, releases[m.Name])
+	} else {
+		mv, err = memberVersion(v, d, m)
+	}
+	if err != nil {
+		return err
+	}
+	err = startMember(mv, d, m, isWindows)
+	if err == nil {
+		err = waitForPort(m.HostPort(), true)
+	}
+	if err == nil && m.Config.Replication.ReplSetName != "" {
+		err = waitForMember(d, m)
+	}
+	return err
+}
+
+// the member a command step runs on
+func stepMember(d *deploy.Deployment, step *scenario.Step) (*deploy.Member, error) {
+	switch step.On {
+	case "":
+		return defaultDataMember(d)
+	case "mongos":
+		for _, m := range d.Members {
+			if m.Config.Sharding.ConfigDB != "" {
+				return m, nil
+			}
+		}
+		return nil, fmt.Errorf("deployment %s has no mongos", d.Name)
+	case "primary", "secondary":
+		g, err := replSet(d, step.ReplSet)
+		if err != nil {
+			return nil, err
+		}
+		if step.On == "primary" {
+			return findPrimary(d, g)
+		}
+		for _, m := range g.members {
+			state, err := getMemberState(d, m)
+			if err == nil && state.Secondary {
+				return m, nil
+			}
+		}
+		return nil, fmt.Errorf("replica set %s has no secondary", g.name)
+	}
+	m := d.Member(step.On)
+	if m == nil {
+		return nil, fmt.Errorf("deployment %s has no member %s", d.Name, step.On)
+	}
+	return m, nil
+}
+
+// the named replica set of a deployment, or its first other than the config servers if name is ""
+func replSet(d *deploy.Deployment, name string) (*memberGroup, error) {
+	for _, g := range memberGroups(d) {
+		if (name == "" && g.kind == replSetGroup) || (name != "" && g.name == name) {
+			return g, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("deployment %s has no replica set", d.Name)
+	}
+	return nil, fmt.Errorf("deployment %s has no replica set %s", d.Name, name)
+}
@@ -0,0 +1,16 @@
+package cmds
+
+import (
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"os"
+)
+
+// Write the JSON Schema for config files to stdout
+func printSchema() error {
+	buf, err := config.Schema()
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(os.Stdout)
+	return err
+}
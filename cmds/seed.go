@@ -0,0 +1,103 @@
+package cmds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/seed"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Fill a collection of a running deployment with synthetic documents described by a seed spec
+// The collection is sharded first if the spec has a shard key, which needs a mongos in the deployment.
+// args are [-batch n] [-concurrency n] [-drop] [-seed n] <deployment[/member]> <spec file>
+func seedCmd(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	batch := fs.Int("batch", 0, "Documents per bulk write, instead of the spec's batchSize (default 1000)")
+	concurrency := fs.Int("concurrency", 0, "Bulk writes in flight at once, instead of the spec's concurrency (default 4)")
+	drop := fs.Bool("drop", false, "Drop the collection first")
+	randSeed := fs.Int64("seed", 1, "Random seed; the same seed and batch size give the same documents")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: seed [-batch n] [-concurrency n] [-drop] [-seed n] <deployment[/member]> <spec file>")
+	}
+	in, err := ioutil.ReadFile(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("error reading seed spec: %v", err)
+	}
+	spec, err := seed.ParseSpec(in)
+	if err != nil {
+		return err
+	}
+	if *batch > 0 {
+		spec.BatchSize = *batch
+	}
+	if *concurrency > 0 {
+		spec.Concurrency = *concurrency
+	}
+	if *drop {
+		spec.Drop = true
+	}
+	d, m, err := dataMember(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if spec.ShardKey != nil && m.Config.Sharding.ConfigDB == "" {
+		return fmt.Errorf("sharding %s.%s needs a mongos, and %s is not one", spec.Database, spec.Collection, m.Name)
+	}
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	start := time.Now()
+	var mu sync.Mutex
+	reported := start
+	err = seedCollection(client, spec, *randSeed, func(n int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(reported) >= 2*time.Second {
+			fmt.Printf("%d of %d documents inserted\n", n, spec.Count)
+			reported = time.Now()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("Inserted %d documents into %s.%s through %s in %v (%.0f/s)\n", spec.Count, spec.Database, spec.Collection,
+		m.Name, elapsed.Round(time.Millisecond), float64(spec.Count)/elapsed.Seconds())
+	return nil
+}
+
+// drop, shard and index a spec's collection as the spec says, then fill it
+func seedCollection(client *mongo.Client, spec *seed.Spec, randSeed int64, progress func(inserted int64)) error {
+	ctx := context.Background()
+	db := client.Database(spec.Database)
+	coll := db.Collection(spec.Collection)
+	if spec.Drop {
+		err := coll.Drop(ctx)
+		if err != nil {
+			return fmt.Errorf("error dropping %s.%s: %v", spec.Database, spec.Collection, err)
+		}
+	}
+	if spec.ShardKey != nil {
+		err := spec.Shard(ctx, client)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Sharded %s.%s on %v\n", spec.Database, spec.Collection, spec.ShardKey)
+	}
+	err := spec.CreateIndexes(ctx, db)
+	if err != nil {
+		return err
+	}
+	return spec.Insert(ctx, coll, randSeed, progress)
+}
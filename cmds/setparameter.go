@@ -0,0 +1,60 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// Set server parameters on every running member of a deployment
+// args are the deployment name followed by one or more name=value parameters
+func setParameterCmd(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: setparameter <deployment> <name=value>...")
+	}
+	params := make(config.Parameters)
+	for _, arg := range args[1:] {
+		name, value, err := config.ParseParameter(arg)
+		if err != nil {
+			return err
+		}
+		params[name] = value
+	}
+	d, err := deploy.Open(runtimePath, args[0])
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+		}
+		err = setParameters(client, params)
+		_ = client.Disconnect(context.Background())
+		if err != nil {
+			return fmt.Errorf("member %s: %v", m.Name, err)
+		}
+		fmt.Printf("Set parameters on %s\n", m.Name)
+	}
+	return nil
+}
+
+// Run setParameter on a running node, keeping each value's type
+func setParameters(client *mongo.Client, params config.Parameters) error {
+	cmd := bson.D{{"setParameter", 1}}
+	for name, value := range params {
+		cmd = append(cmd, bson.E{Key: name, Value: value})
+	}
+	db := client.Database("admin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res := db.RunCommand(ctx, cmd)
+	if res.Err() != nil {
+		return fmt.Errorf("error running setParameter command: %v", res.Err())
+	}
+	return nil
+}
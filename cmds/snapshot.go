@@ -0,0 +1,287 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/snapshot"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Save, restore, list and delete snapshots of deployments
+// Members running when a snapshot is saved are shut down cleanly while their files are copied and started again
+// afterwards; restoring stops them, puts the files back and starts them with the releases they ran when saved.
+// Members' logs and the faults recorded for the deployment are neither saved nor rolled back.
+// args are save <deployment> <name>, restore <name>, list, or delete <name>
+func snapshotCmd(args []string, v *version.Version, isWindows bool) error {
+	usage := fmt.Errorf("usage: snapshot save <deployment> <name> | snapshot restore <name> | snapshot list | snapshot delete <name>")
+	if len(args) == 0 {
+		return usage
+	}
+	switch {
+	case args[0] == "save" && len(args) == 3:
+		return snapshotSave(args[1], args[2], v, isWindows)
+	case args[0] == "restore" && len(args) == 2:
+		return snapshotRestore(args[1], v, isWindows)
+	case args[0] == "list" && len(args) == 1:
+		return snapshotList()
+	case args[0] == "delete" && len(args) == 2:
+		s, err := snapshot.Open(snapshotPath, args[1])
+		if err != nil {
+			return err
+		}
+		err = s.Delete()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted snapshot %s\n", s.Name)
+		return nil
+	}
+	return usage
+}
+
+func snapshotSave(name string, snapshotName string, v *version.Version, isWindows bool) error {
+	d, err := deploy.Open(runtimePath, name)
+	if err != nil {
+		return err
+	}
+	if _, err = snapshot.Open(snapshotPath, snapshotName); err == nil {
+		return fmt.Errorf("snapshot %s already exists", snapshotName)
+	}
+	releases := runningReleases(d)
+	groups := memberGroups(d)
+	start := time.Now()
+	err = stopDeployment(v, d, groups, releases, isWindows)
+	if err != nil {
+		return err
+	}
+	s, err := snapshot.Save(snapshotPath, snapshotName, d.Name, snapshotDirs(d), memberLogs(d), releases)
+	if err != nil {
+		fmt.Printf("Snapshot failed, starting %s again\n", d.Name)
+		if err2 := startDeployment(v, d, groups, releases, isWindows); err2 != nil {
+			fmt.Printf("Error: %v\n", err2)
+		}
+		return err
+	}
+	fmt.Printf("Saved snapshot %s of %s: %s", s.Name, d.Name, formatSize(s.Size))
+	if s.Cloned > 0 {
+		fmt.Printf(", %s of it cloned copy-on-write", formatSize(s.Cloned))
+	}
+	fmt.Println()
+	err = startDeployment(v, d, groups, releases, isWindows)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done in %v\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func snapshotRestore(snapshotName string, v *version.Version, isWindows bool) error {
+	s, err := snapshot.Open(snapshotPath, snapshotName)
+	if err != nil {
+		return err
+	}
+	var exclude []string
+	if d, err := deploy.Open(runtimePath, s.Deployment); err == nil {
+		err = stopDeployment(v, d, memberGroups(d), runningReleases(d), isWindows)
+		if err != nil {
+			return err
+		}
+		exclude = memberLogs(d)
+	}
+	err = s.Restore(exclude)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from snapshot %s of %s\n", s.Deployment, s.Name, s.Created.Format("2006-01-02 15:04:05"))
+	d, err := deploy.Open(runtimePath, s.Deployment) // with the configs saved
+	if err != nil {
+		return err
+	}
+	return startDeployment(v, d, memberGroups(d), s.Releases, isWindows)
+}
+
+func snapshotList() error {
+	snapshots, err := snapshot.List(snapshotPath)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("No snapshots in %s\n", snapshotPath)
+		return nil
+	}
+	for _, s := range snapshots {
+		fmt.Printf("%-20s %-12s %s %10s", s.Name, s.Deployment, s.Created.Format("2006-01-02 15:04:05"), formatSize(s.Size))
+		if s.Cloned > 0 {
+			fmt.Printf(" (%s cloned)", formatSize(s.Cloned))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// the directories a snapshot of a deployment saves: the deployment's own, with its configs and members' directories,
+// and any member's dbPath outside it
+func snapshotDirs(d *deploy.Deployment) []string {
+	dirs := []string{d.Path}
+	for _, m := range d.Members {
+		dbPath := m.Config.Storage.DbPath
+		if dbPath != "" && !strings.HasPrefix(dbPath, d.Path+string(filepath.Separator)) {
+			dirs = append(dirs, dbPath)
+		}
+	}
+	return dirs
+}
+
+// members' log files and the faults injected, which snapshots leave alone so that they cover every run
+func memberLogs(d *deploy.Deployment) []string {
+	logs := []string{d.FaultLog()}
+	for _, m := range d.Members {
+		if m.Config.SystemLog.Path != "" {
+			logs = append(logs, m.Config.SystemLog.Path)
+		}
+	}
+	return logs
+}
+
+// the release each running member of a deployment runs, by member
+func runningReleases(d *deploy.Deployment) map[string]string {
+	releases := make(map[string]string)
+	for _, m := range d.Members {
+		r, err := memberRelease(d, m)
+		if err == nil {
+			releases[m.Name] = r.String()
+		}
+	}
+	return releases
+}
+
+// shut down a deployment's running members, routers first and config servers last, each replica set's primary after
+// its secondaries so there are no elections
+// Members that are up but not among those running, e.g. paused or refusing to authenticate, would have their files
+// changed under them, so nothing is stopped if there are any. If a member can't be stopped, those that were are
+// started again.
+func stopDeployment(v *version.Version, d *deploy.Deployment, groups []*memberGroup, running map[string]string, isWindows bool) error {
+	for _, m := range d.Members {
+		if running[m.Name] == "" && memberUp(d, m) {
+			return fmt.Errorf("member %s is up but not answering, stop it first", m.Name)
+		}
+	}
+	stopped := make(map[string]string)
+	err := stopMembers(d, groups, running, stopped)
+	if err != nil && len(stopped) > 0 {
+		fmt.Printf("Starting the members stopped again\n")
+		if err2 := startDeployment(v, d, groups, stopped, isWindows); err2 != nil {
+			fmt.Printf("Error: %v\n", err2)
+		}
+	}
+	return err
+}
+
+// shut down the running members in reverse upgrade order, adding each to stopped
+func stopMembers(d *deploy.Deployment, groups []*memberGroup, running map[string]string, stopped map[string]string) error {
+	for i := len(groups) - 1; i >= 0; i-- {
+		g := groups[i]
+		var primary *deploy.Member
+		for _, m := range g.members {
+			if running[m.Name] == "" {
+				continue
+			}
+			if g.name != "" && primary == nil {
+				if state, err := getMemberState(d, m); err == nil && state.IsMaster {
+					primary = m
+					continue
+				}
+			}
+			err := stopMember(d, m)
+			if err != nil {
+				return err
+			}
+			stopped[m.Name] = running[m.Name]
+		}
+		if primary != nil {
+			err := stopMember(d, primary)
+			if err != nil {
+				return err
+			}
+			stopped[primary.Name] = running[primary.Name]
+		}
+	}
+	return nil
+}
+
+// shut a member down cleanly, without waiting for secondaries to catch up since they are going down too
+func stopMember(d *deploy.Deployment, m *deploy.Member) error {
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err = client.Database("admin").RunCommand(ctx, bson.D{{"shutdown", 1}, {"force", true}}).Err()
+	_ = client.Disconnect(context.Background())
+	if err != nil && !mongo.IsNetworkError(err) { // the member closes the connection as it shuts down
+		return fmt.Errorf("error shutting down %s: %v", m.Name, err)
+	}
+	err = waitForPort(m.HostPort(), false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Shut down %s\n", m.Name)
+	return nil
+}
+
+// start the members of a deployment that have a release, with the installed binaries for it, in upgrade order
+// Each replica set's members are all started before waiting for them to become primary or secondary.
+func startDeployment(v *version.Version, d *deploy.Deployment, groups []*memberGroup, releases map[string]string, isWindows bool) error {
+	for _, g := range groups {
+		var started []*deploy.Member
+		for _, m := range g.members {
+			if releases[m.Name] == "" {
+				continue
+			}
+			mv, err := installedVersion(v, releases[m.Name])
+			if err != nil {
+				return fmt.Errorf("member %s: %v", m.Name, err)
+			}
+			err = startMember(mv, d, m, isWindows)
+			if err == nil {
+				err = waitForPort(m.HostPort(), true)
+			}
+			if err != nil {
+				return fmt.Errorf("error starting %s: %v", m.Name, err)
+			}
+			started = append(started, m)
+			fmt.Printf("Started %s with %s\n", m.Name, mv.Release)
+		}
+		if g.name == "" {
+			continue
+		}
+		for _, m := range started {
+			err := waitForMember(d, m)
+			if err != nil {
+				return fmt.Errorf("member %s: %v", m.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// a size in bytes, in the largest unit it is at least one of
+func formatSize(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	f, i := float64(n), 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
@@ -0,0 +1,43 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+// Show whether each member of a deployment is running, and its version and uptime if it is
+func statusCmd(args []string) error {
+	d, err := deploy.Open(runtimePath, deploymentName(args, 0))
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		authBy := "password"
+		if useX509(d, m) {
+			authBy = "x.509 certificate"
+		}
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			fmt.Printf("%-12s %-16s not running (%v)\n", m.Name, m.HostPort(), err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var result struct {
+			Version string  `bson:"version"`
+			Uptime  float64 `bson:"uptime"`
+		}
+		err = client.Database("admin").RunCommand(ctx, bson.D{{"serverStatus", 1}}).Decode(&result)
+		cancel()
+		_ = client.Disconnect(context.Background())
+		if err != nil {
+			fmt.Printf("%-12s %-16s running, serverStatus failed: %v\n", m.Name, m.HostPort(), err)
+			continue
+		}
+		fmt.Printf("%-12s %-16s running %s, up %v (authenticated by %s)\n", m.Name, m.HostPort(), result.Version,
+			time.Duration(result.Uptime)*time.Second, authBy)
+	}
+	return nil
+}
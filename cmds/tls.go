@@ -0,0 +1,234 @@
+package cmds
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/certs"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+TLS files for a deployment, unless its configs name other paths:
+	<runtime>/<deployment>/ca.pem, ca.key	local test CA
+	<runtime>/<deployment>/client.pem	client certificate for the admin user
+	<runtime>/<deployment>/<member>/server.pem	member's certificate key file
+	<runtime>/<deployment>/<member>/member.pem	member's x509 cluster certificate, if issued separately
+*/
+
+const caFileName = "ca.pem"
+const clientFileName = "client.pem"
+const serverFileName = "server.pem"
+const memberFileName = "member.pem"
+
+// Set up or change TLS certificates for a deployment
+// args are "setup <deployment>" or "issue [-expired] [-wrongsan] [-selfsigned] <deployment> <member[/cluster]|client>"
+func tlsCmd(args []string, isWindows bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: tls setup|issue ...")
+	}
+	switch args[0] {
+	case "setup":
+		return tlsSetup(args[1:], isWindows)
+	case "issue":
+		return tlsIssue(args[1:], isWindows)
+	}
+	return fmt.Errorf("unknown tls command '%s', must be setup or issue", args[0])
+}
+
+// Turn on TLS for every member of a deployment, creating the CA and certificates
+func tlsSetup(args []string, isWindows bool) error {
+	fs := flag.NewFlagSet("tls setup", flag.ContinueOnError)
+	mode := fs.String("mode", "requireTLS", "net.tls.mode for members that don't have one")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: tls setup [-mode m] <deployment>")
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, m := range d.Members {
+		settings := m.Config.TLS()
+		setIfEmpty(settings.Mode, *mode)
+		setIfEmpty(settings.CertificateKeyFile, filepath.Join(d.MemberPath(m.Name), serverFileName))
+		setIfEmpty(settings.CAFile, filepath.Join(d.Path, caFileName))
+	}
+	err = ensureCerts(d)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("TLS set up for deployment %s, client certificate is %s\n", d.Name, clientCertFile(d))
+	return d.Write(isWindows)
+}
+
+// Replace a member's or the client's certificate, optionally with one that's broken in a particular way
+func tlsIssue(args []string, isWindows bool) error {
+	fs := flag.NewFlagSet("tls issue", flag.ContinueOnError)
+	var opts certs.Options
+	fs.BoolVar(&opts.Expired, "expired", false, "Issue a certificate that has expired")
+	fs.BoolVar(&opts.WrongSAN, "wrongsan", false, "Issue a certificate for a different host")
+	fs.BoolVar(&opts.SelfSigned, "selfsigned", false, "Issue a self-signed certificate instead of one signed by the CA")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: tls issue [-expired] [-wrongsan] [-selfsigned] <deployment> <member[/cluster]|client>")
+	}
+	d, err := deploy.Open(runtimePath, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ca, err := loadDeploymentCA(d)
+	if err != nil {
+		return err
+	}
+	target := fs.Arg(1)
+	if target == "client" {
+		fn := clientCertFile(d)
+		err = ca.IssueFile(fn, certs.Client, "admin", opts)
+		if err == nil {
+			fmt.Printf("Issued client certificate %s\n", fn)
+		}
+		return err
+	}
+	name := strings.TrimSuffix(target, "/cluster")
+	m := d.Member(name)
+	if m == nil {
+		return fmt.Errorf("deployment %s has no member %s", d.Name, name)
+	}
+	opts.Hosts = certs.LocalHosts()
+	opts.Unit = d.Name
+	settings := m.Config.TLS()
+	fn, kind := *settings.CertificateKeyFile, certs.Server
+	if name != target {
+		// a separate x509 member certificate for connections to other members
+		setIfEmpty(settings.ClusterFile, filepath.Join(d.MemberPath(m.Name), memberFileName))
+		fn, kind = *settings.ClusterFile, certs.Member
+	}
+	if fn == "" {
+		return fmt.Errorf("member %s has no certificate key file, run tls setup first", m.Name)
+	}
+	err = ca.IssueFile(fn, kind, m.Name, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Issued %s certificate %s, restart %s to use it\n", kind, fn, m.Name)
+	return d.Write(isWindows)
+}
+
+// create the CA and certificates named in the members' configs that don't exist yet, and a client certificate
+// Members using TLS without a CA file are given the deployment's CA, as are their KMIP client certificates.
+// Only files inside the deployment's directory are created; the others belong to someone else.
+func ensureCerts(d *deploy.Deployment) error {
+	err := ensureKMIPCerts(d)
+	if err != nil {
+		return err
+	}
+	cas := make(map[string]*certs.CA)
+	anyTLS := false
+	for _, m := range d.Members {
+		if !m.Config.TLSEnabled() {
+			continue
+		}
+		anyTLS = true
+		settings := m.Config.TLS()
+		setIfEmpty(settings.CAFile, filepath.Join(d.Path, caFileName))
+		caFile := *settings.CAFile
+		ca, ok := cas[caFile]
+		if !ok {
+			var err error
+			ca, err = ensureCA(d, caFile)
+			if err != nil {
+				return err
+			}
+			cas[caFile] = ca
+		}
+		opts := certs.Options{Hosts: certs.LocalHosts(), Unit: d.Name}
+		for _, f := range []struct {
+			fn   string
+			kind certs.Kind
+		}{{*settings.CertificateKeyFile, certs.Server}, {*settings.ClusterFile, certs.Member}} {
+			if !missingInside(d, f.fn) {
+				continue
+			}
+			err := ca.IssueFile(f.fn, f.kind, m.Name, opts)
+			if err != nil {
+				return fmt.Errorf("member %s: %v", m.Name, err)
+			}
+		}
+	}
+	if anyTLS && missingInside(d, clientCertFile(d)) {
+		ca, err := loadDeploymentCA(d)
+		if err != nil {
+			return err
+		}
+		return ca.IssueFile(clientCertFile(d), certs.Client, "admin", certs.Options{})
+	}
+	return nil
+}
+
+// load the CA at fn, creating it if it's in the deployment's directory and doesn't exist
+func ensureCA(d *deploy.Deployment, fn string) (*certs.CA, error) {
+	if !missingInside(d, fn) {
+		return certs.LoadCA(fn)
+	}
+	ca, err := certs.NewCA("mongodb-repro CA for " + d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return ca, ca.Save(fn)
+}
+
+// the CA of the deployment's first member using TLS
+func loadDeploymentCA(d *deploy.Deployment) (*certs.CA, error) {
+	for _, m := range d.Members {
+		if fn := *m.Config.TLS().CAFile; fn != "" {
+			return certs.LoadCA(fn)
+		}
+	}
+	return nil, fmt.Errorf("deployment %s has no CA, run tls setup first", d.Name)
+}
+
+// whether fn names a file in the deployment's directory that doesn't exist
+func missingInside(d *deploy.Deployment, fn string) bool {
+	if fn == "" {
+		return false
+	}
+	rel, err := filepath.Rel(d.Path, fn)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	_, err = os.Stat(fn)
+	return os.IsNotExist(err)
+}
+
+// the client certificate for connecting to a deployment's members
+func clientCertFile(d *deploy.Deployment) string {
+	return filepath.Join(d.Path, clientFileName)
+}
+
+// TLS config for connecting to a member, nil if the member doesn't use TLS
+func memberTLS(d *deploy.Deployment, m *deploy.Member) (*tls.Config, error) {
+	if !m.Config.TLSPreferred() {
+		return nil, nil
+	}
+	certFile := clientCertFile(d)
+	if _, err := os.Stat(certFile); err != nil {
+		certFile = ""
+	}
+	return certs.ClientTLS(*m.Config.TLS().CAFile, certFile)
+}
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
@@ -0,0 +1,368 @@
+package cmds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Move a running deployment to another release of MongoDB, one release series at a time
+// Upgrades restart config servers, then shards and other replica sets, then standalones and finally mongos, each
+// replica set's secondaries before its primary, which steps down first; the featureCompatibilityVersion is raised once
+// every member runs the new series. Downgrades lower the featureCompatibilityVersion first and restart in reverse.
+// The balancer is stopped while a sharded cluster moves. Members already running the release they are moving to are
+// left alone, so a move that failed part way can be run again.
+func upgradeCmd(args []string, v *version.Version, isWindows bool, downgrade bool) error {
+	name := "upgrade"
+	if downgrade {
+		name = "downgrade"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	to := fs.String("to", "", "Release to move to, e.g. 4.4.1, or 4.4 for the newest 4.4 release installed")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	rest := fs.Args()
+	if len(rest) > 1 { // flags may follow the deployment name
+		err = fs.Parse(rest[1:])
+		if err != nil {
+			return err
+		}
+		rest = append(rest[:1], fs.Args()...)
+	}
+	if len(rest) != 1 || *to == "" {
+		return fmt.Errorf("usage: %s <deployment> -to <x.y.z|x.y>", name)
+	}
+	d, err := deploy.Open(runtimePath, rest[0])
+	if err != nil {
+		return err
+	}
+
+	// the release the deployment is moving from: the oldest running for an upgrade, the newest for a downgrade
+	running := make(map[string]version.ReleaseType)
+	var from version.ReleaseType
+	for i, m := range d.Members {
+		r, err := memberRelease(d, m)
+		if err != nil {
+			return fmt.Errorf("member %s must be running: %v", m.Name, err)
+		}
+		running[m.Name] = r
+		if i == 0 || (r.Compare(from) < 0) != downgrade {
+			from = r
+		}
+	}
+	target, err := installedVersion(v, *to)
+	if err != nil {
+		return err
+	}
+	if !downgrade && target.Release.Compare(from) < 0 {
+		return fmt.Errorf("%s is older than %s, use downgrade", target.Release, from)
+	}
+	if downgrade && target.Release.Compare(from) > 0 {
+		return fmt.Errorf("%s is newer than %s, use upgrade", target.Release, from)
+	}
+	path, err := version.UpgradePath(from, target.Release)
+	if err != nil {
+		return err
+	}
+	steps := make([]*version.Version, len(path))
+	for i, series := range path {
+		if i == len(path)-1 {
+			steps[i] = target
+			break
+		}
+		steps[i], err = installedVersion(v, series)
+		if err != nil {
+			return fmt.Errorf("moving from %s to %s goes through %s: %v", from, target.Release, series, err)
+		}
+	}
+
+	// check every member can run every release along the way before changing anything
+	for _, step := range steps {
+		for _, m := range d.Members {
+			_, err = m.Config.Copy().Validate(step)
+			if err != nil {
+				return fmt.Errorf("member %s can't run %s: %v", m.Name, step.Release, err)
+			}
+		}
+	}
+	groups := memberGroups(d)
+	if !downgrade && from.HasFCV() {
+		err = forFCVTargets(d, groups, func(m *deploy.Member, client *mongo.Client) error {
+			fcv, err := getFCV(client)
+			if err == nil && fcv != from.Series() {
+				err = fmt.Errorf("featureCompatibilityVersion is %s, it must be %s before upgrading", fcv, from.Series())
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	prev := from
+	for _, step := range steps {
+		fmt.Printf("Moving %s from %s to %s\n", d.Name, prev, step.Release)
+		err = changeRelease(d, groups, running, prev, step, downgrade, isWindows)
+		if err != nil {
+			return err
+		}
+		prev = step.Release
+	}
+	fmt.Printf("Deployment %s is running %s\n", d.Name, target.Release)
+	return nil
+}
+
+// the installed binaries for a release such as 4.4.1, or the newest installed release in a series such as 4.4, on
+// the platform and edition of v
+func installedVersion(v *version.Version, release string) (*version.Version, error) {
+	if strings.Count(release, ".") == 1 {
+		files, err := ioutil.ReadDir(binaryPath)
+		if err != nil {
+			return nil, err
+		}
+		var newest *version.Version
+		for _, f := range files {
+			iv, err := version.ToVersion(f.Name())
+			if err != nil || iv.Arch != v.Arch || iv.OS != v.OS || iv.Distro != v.Distro ||
+				iv.Release.Enterprise != v.Release.Enterprise || iv.Release.Series() != release {
+				continue
+			}
+			if newest == nil || iv.Release.Compare(newest.Release) > 0 {
+				newest = iv
+			}
+		}
+		if newest == nil {
+			return nil, fmt.Errorf("no %s release is installed, download one with get -version %s.<n>", release, release)
+		}
+		return newest, nil
+	}
+	r, err := version.ToRelease(release)
+	if err != nil {
+		return nil, err
+	}
+	iv := *v
+	iv.Release = r
+	iv.Release.Enterprise = v.Release.Enterprise
+	loc, err := iv.ToLocation()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(binaryPath, loc.Filename)); err != nil {
+		return nil, fmt.Errorf("%s is not installed, download it with get -version %s", r, r)
+	}
+	return &iv, nil
+}
+
+// move every member of a deployment to the binaries of step, the next release along the path from prev
+func changeRelease(d *deploy.Deployment, groups []*memberGroup, running map[string]version.ReleaseType, prev version.ReleaseType,
+	step *version.Version, downgrade bool, isWindows bool) error {
+	newSeries := prev.Series() != step.Release.Series()
+	var router *deploy.Member
+	if last := groups[len(groups)-1]; last.kind == routerGroup && prev.AtLeast(3, 4) {
+		router = last.members[0]
+		err := setBalancer(d, router, false)
+		if err != nil {
+			return err
+		}
+	}
+	if downgrade && newSeries && prev.HasFCV() {
+		err := setDeploymentFCV(d, groups, step.Release.Series())
+		if err != nil {
+			return err
+		}
+	}
+	err := validateDeployment(d, step, isWindows)
+	if err != nil {
+		return err
+	}
+	for i := range groups {
+		g := groups[i]
+		if downgrade {
+			g = groups[len(groups)-1-i]
+		}
+		err = rollGroup(d, g, running, step, isWindows)
+		if err != nil {
+			return err
+		}
+	}
+	if !downgrade && newSeries && step.Release.HasFCV() {
+		err = setDeploymentFCV(d, groups, step.Release.Series())
+		if err != nil {
+			return err
+		}
+	}
+	if router != nil {
+		return setBalancer(d, router, true)
+	}
+	return nil
+}
+
+// restart a group's members with the binaries of step, one at a time; a replica set's primary steps down and goes last
+func rollGroup(d *deploy.Deployment, g *memberGroup, running map[string]version.ReleaseType, step *version.Version, isWindows bool) error {
+	var primary *deploy.Member
+	for _, m := range g.members {
+		if running[m.Name].Compare(step.Release) == 0 {
+			fmt.Printf("%s is already running %s\n", m.Name, step.Release)
+			continue
+		}
+		if g.name != "" {
+			state, err := getMemberState(d, m)
+			if err != nil {
+				return fmt.Errorf("member %s: %v", m.Name, err)
+			}
+			if state.IsMaster {
+				primary = m
+				continue
+			}
+		}
+		err := restartWithRelease(d, m, running, step, isWindows)
+		if err != nil {
+			return err
+		}
+	}
+	if primary == nil {
+		return nil
+	}
+	err := stepDown(d, primary, 60)
+	if err != nil {
+		return err
+	}
+	return restartWithRelease(d, primary, running, step, isWindows)
+}
+
+// restart a member with the binaries of step, waiting until it is a primary, secondary or arbiter again
+func restartWithRelease(d *deploy.Deployment, m *deploy.Member, running map[string]version.ReleaseType, step *version.Version, isWindows bool) error {
+	err := restartMember(step, d, m, isWindows)
+	if err == nil {
+		err = waitForMember(d, m)
+	}
+	if err != nil {
+		return fmt.Errorf("error restarting %s with %s: %v", m.Name, step.Release, err)
+	}
+	running[m.Name] = step.Release
+	fmt.Printf("%s is now running %s\n", m.Name, step.Release)
+	return nil
+}
+
+// the release a running member reports in buildInfo
+func memberRelease(d *deploy.Deployment, m *deploy.Member) (version.ReleaseType, error) {
+	client, err := connectMember(d, m, false)
+	if err != nil {
+		return version.ReleaseType{}, err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var result struct {
+		Version string `bson:"version"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{"buildInfo", 1}}).Decode(&result)
+	if err != nil {
+		return version.ReleaseType{}, fmt.Errorf("error running buildInfo: %v", err)
+	}
+	return version.ToRelease(result.Version)
+}
+
+// make a replica set's primary step down, not to seek election again for seconds, waiting until it is no longer primary
+func stepDown(d *deploy.Deployment, m *deploy.Member, seconds int) error {
+	client, err := connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	// before 4.2 stepping down closes every connection, so an error here doesn't mean it failed
+	err = client.Database("admin").RunCommand(ctx, bson.D{{"replSetStepDown", seconds}}).Err()
+	cancel()
+	_ = client.Disconnect(context.Background())
+	for i := 0; i < 60; i++ {
+		state, serr := getMemberState(d, m)
+		if serr == nil && !state.IsMaster {
+			fmt.Printf("%s stepped down\n", m.Name)
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("%s did not step down: %v", m.Name, err)
+}
+
+// the members a deployment's featureCompatibilityVersion is read and set on: a mongos in a sharded cluster, otherwise
+// each replica set's primary and each standalone
+func fcvTargets(d *deploy.Deployment, groups []*memberGroup) ([]*deploy.Member, error) {
+	if last := groups[len(groups)-1]; last.kind == routerGroup {
+		return last.members[:1], nil
+	}
+	var targets []*deploy.Member
+	for _, g := range groups {
+		if g.name == "" {
+			targets = append(targets, g.members[0])
+			continue
+		}
+		primary, err := findPrimary(d, g)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, primary)
+	}
+	return targets, nil
+}
+
+// call fn with an authenticated client for each of the members the featureCompatibilityVersion is read and set on
+func forFCVTargets(d *deploy.Deployment, groups []*memberGroup, fn func(m *deploy.Member, client *mongo.Client) error) error {
+	targets, err := fcvTargets(d, groups)
+	if err != nil {
+		return err
+	}
+	for _, m := range targets {
+		client, err := connectMember(d, m, true)
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %v", m.Name, err)
+		}
+		err = fn(m, client)
+		_ = client.Disconnect(context.Background())
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+func setDeploymentFCV(d *deploy.Deployment, groups []*memberGroup, fcv string) error {
+	return forFCVTargets(d, groups, func(m *deploy.Member, client *mongo.Client) error {
+		err := setFCV(client, fcv)
+		if err == nil {
+			fmt.Printf("Set featureCompatibilityVersion to %s on %s\n", fcv, m.Name)
+		}
+		return err
+	})
+}
+
+// stop or start a sharded cluster's balancer through a mongos
+func setBalancer(d *deploy.Deployment, router *deploy.Member, on bool) error {
+	cmd := "balancerStop"
+	if on {
+		cmd = "balancerStart"
+	}
+	client, err := connectMember(d, router, true)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", router.Name, err)
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	err = client.Database("admin").RunCommand(ctx, bson.D{{cmd, 1}}).Err()
+	if err != nil {
+		return fmt.Errorf("error running %s: %v", cmd, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/certs"
+	"github.com/SpencerBrown/mongodb-repro/deploy"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"os"
+	"time"
+)
+
+// whether admin connections to a member authenticate with the deployment's client certificate
+func useX509(d *deploy.Deployment, m *deploy.Member) bool {
+	if !m.Config.X509Auth() || !m.Config.TLSEnabled() {
+		return false
+	}
+	_, err := os.Stat(clientCertFile(d))
+	return err == nil
+}
+
+// Create the admin users on a member that has just started for the first time
+// The first user is created through the localhost exception: the x.509 user for the client certificate if the member
+// allows x.509 authentication, otherwise the password user. The password user is then added if passwords are allowed.
+func setupUsers(d *deploy.Deployment, m *deploy.Member) error {
+	client, err := connectMember(d, m, false)
+	if err != nil {
+		return fmt.Errorf("error connecting to server: %v", err)
+	}
+	if !useX509(d, m) {
+		err = setupAdminUser(client)
+		_ = client.Disconnect(context.Background())
+		return err
+	}
+	err = setupX509User(client, clientCertFile(d))
+	_ = client.Disconnect(context.Background())
+	if err != nil || !m.Config.PasswordAuth() {
+		return err
+	}
+	client, err = connectMember(d, m, true)
+	if err != nil {
+		return fmt.Errorf("error connecting with the client certificate: %v", err)
+	}
+	err = setupAdminUser(client)
+	_ = client.Disconnect(context.Background())
+	return err
+}
+
+// create a root user named by the subject of the client certificate in certFile
+func setupX509User(client *mongo.Client, certFile string) error {
+	subject, err := certs.Subject(certFile)
+	if err != nil {
+		return err
+	}
+	cmd := bson.D{{"createUser", subject}, {"roles", bson.A{bson.D{{"role", "root"}, {"db", "admin"}}}}}
+	db := client.Database("$external")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res := db.RunCommand(ctx, cmd)
+	if res.Err() != nil {
+		return fmt.Errorf("error running createUser command: %v", res.Err())
+	}
+	fmt.Printf("Created x.509 user %s\n", subject)
+	return nil
+}
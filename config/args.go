@@ -0,0 +1,416 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// How a setting is written on the mongod/mongos command line
+type argKind int
+
+const (
+	argValue    argKind = iota // --flag <value>
+	argSwitch                  // --flag if true
+	argNoSwitch                // --flag if false
+	argMapped                  // a different flag for each value, e.g. --auth or --noauth
+	argVerbose                 // -v repeated once per verbosity level
+)
+
+type argOption struct {
+	key    string // dotted YAML key
+	flag   string // command-line option, without the leading dashes
+	kind   argKind
+	values map[string]string // argValue: YAML value -> command-line value; argMapped: YAML value -> flag ("" for none)
+}
+
+var argOptions = []argOption{
+	{key: "storage.dbPath", flag: "dbpath"},
+	{key: "storage.engine", flag: "storageEngine"},
+	{key: "storage.directoryPerDB", flag: "directoryperdb", kind: argSwitch},
+	{key: "storage.journal.enabled", kind: argMapped, values: map[string]string{"true": "journal", "false": "nojournal"}},
+	{key: "storage.wiredTiger.engineConfig.cacheSizeGB", flag: "wiredTigerCacheSizeGB"},
+	{key: "storage.wiredTiger.collectionConfig.blockCompressor", flag: "wiredTigerCollectionBlockCompressor"},
+	{key: "storage.wiredTiger.indexConfig.prefixCompression", flag: "wiredTigerIndexPrefixCompression"},
+	{key: "systemLog.destination", kind: argMapped, values: map[string]string{"syslog": "syslog", "file": ""}},
+	{key: "systemLog.path", flag: "logpath"},
+	{key: "systemLog.timeStampFormat", flag: "timeStampFormat"},
+	{key: "systemLog.logAppend", flag: "logappend", kind: argSwitch},
+	{key: "systemLog.verbosity", flag: "v", kind: argVerbose},
+	{key: "security.authorization", kind: argMapped, values: map[string]string{"enabled": "auth", "disabled": "noauth"}},
+	{key: "security.javascriptEnabled", flag: "noscripting", kind: argNoSwitch},
+	{key: "security.keyFile", flag: "keyFile"},
+	{key: "security.clusterAuthMode", flag: "clusterAuthMode"},
+	{key: "security.ldap.servers", flag: "ldapServers"},
+	{key: "security.ldap.bind.method", flag: "ldapBindMethod"},
+	{key: "security.ldap.bind.saslMechanisms", flag: "ldapBindSaslMechanisms"},
+	{key: "security.ldap.bind.queryUser", flag: "ldapQueryUser"},
+	{key: "security.ldap.bind.queryPassword", flag: "ldapQueryPassword"},
+	{key: "security.ldap.transportSecurity", flag: "ldapTransportSecurity"},
+	{key: "security.ldap.timeoutMS", flag: "ldapTimeoutMS"},
+	{key: "security.ldap.userToDNMapping", flag: "ldapUserToDNMapping"},
+	{key: "security.ldap.authz.queryTemplate", flag: "ldapAuthzQueryTemplate"},
+	{key: "security.ldap.validateLDAPServerConfig", flag: "ldapValidateLDAPServerConfig"},
+	{key: "security.enableEncryption", flag: "enableEncryption", kind: argSwitch},
+	{key: "security.encryptionCipherMode", flag: "encryptionCipherMode"},
+	{key: "security.encryptionKeyFile", flag: "encryptionKeyFile"},
+	{key: "security.kmip.keyIdentifier", flag: "kmipKeyIdentifier"},
+	{key: "security.kmip.rotateMasterKey", flag: "kmipRotateMasterKey", kind: argSwitch},
+	{key: "security.kmip.serverName", flag: "kmipServerName"},
+	{key: "security.kmip.port", flag: "kmipPort"},
+	{key: "security.kmip.clientCertificateFile", flag: "kmipClientCertificateFile"},
+	{key: "security.kmip.clientCertificatePassword", flag: "kmipClientCertificatePassword"},
+	{key: "security.kmip.serverCAFile", flag: "kmipServerCAFile"},
+	{key: "net.port", flag: "port"},
+	{key: "net.bindIp", flag: "bind_ip"},
+	{key: "net.ipv6", flag: "ipv6", kind: argSwitch},
+	{key: "net.unixDomainSocket.enabled", flag: "nounixsocket", kind: argNoSwitch},
+	{key: "net.tls.mode", flag: "tlsMode"},
+	{key: "net.tls.certificateKeyFile", flag: "tlsCertificateKeyFile"},
+	{key: "net.tls.certificateKeyFilePassword", flag: "tlsCertificateKeyFilePassword"},
+	{key: "net.tls.CAFile", flag: "tlsCAFile"},
+	{key: "net.tls.CRLFile", flag: "tlsCRLFile"},
+	{key: "net.tls.clusterFile", flag: "tlsClusterFile"},
+	{key: "net.tls.allowConnectionsWithoutCertificates", flag: "tlsAllowConnectionsWithoutCertificates", kind: argSwitch},
+	{key: "net.tls.allowInvalidCertificates", flag: "tlsAllowInvalidCertificates", kind: argSwitch},
+	{key: "net.tls.allowInvalidHostnames", flag: "tlsAllowInvalidHostnames", kind: argSwitch},
+	{key: "net.tls.disabledProtocols", flag: "tlsDisabledProtocols"},
+	{key: "net.ssl.mode", flag: "sslMode"},
+	{key: "net.ssl.PEMKeyFile", flag: "sslPEMKeyFile"},
+	{key: "net.ssl.PEMKeyPassword", flag: "sslPEMKeyPassword"},
+	{key: "net.ssl.CAFile", flag: "sslCAFile"},
+	{key: "net.ssl.CRLFile", flag: "sslCRLFile"},
+	{key: "net.ssl.clusterFile", flag: "sslClusterFile"},
+	{key: "net.ssl.allowConnectionsWithoutCertificates", flag: "sslAllowConnectionsWithoutCertificates", kind: argSwitch},
+	{key: "net.ssl.allowInvalidCertificates", flag: "sslAllowInvalidCertificates", kind: argSwitch},
+	{key: "net.ssl.allowInvalidHostnames", flag: "sslAllowInvalidHostnames", kind: argSwitch},
+	{key: "net.ssl.disabledProtocols", flag: "sslDisabledProtocols"},
+	{key: "net.compression.compressors", flag: "networkMessageCompressors"},
+	{key: "processManagement.fork", flag: "fork", kind: argSwitch},
+	{key: "processManagement.pidFilePath", flag: "pidfilepath"},
+	{key: "replication.replSetName", flag: "replSet"},
+	{key: "replication.oplogSizeMB", flag: "oplogSize"},
+	{key: "sharding.clusterRole", kind: argMapped, values: map[string]string{"configsvr": "configsvr", "shardsvr": "shardsvr"}},
+	{key: "sharding.configDB", flag: "configdb"},
+	{key: "operationProfiling.mode", flag: "profile", values: map[string]string{"off": "0", "slowOp": "1", "all": "2"}},
+	{key: "operationProfiling.slowOpThresholdMs", flag: "slowms"},
+	{key: "operationProfiling.slowOpSampleRate", flag: "slowOpSampleRate"},
+	{key: "auditLog.destination", flag: "auditDestination"},
+	{key: "auditLog.format", flag: "auditFormat"},
+	{key: "auditLog.path", flag: "auditPath"},
+	{key: "auditLog.filter", flag: "auditFilter"},
+}
+
+const setParameterFlag = "setParameter"
+
+func findArgOption(key string) *argOption {
+	for i := range argOptions {
+		if argOptions[i].key == key {
+			return &argOptions[i]
+		}
+	}
+	return nil
+}
+
+// ToArgs renders a config as the equivalent mongod/mongos command-line arguments, without the program name
+// Settings tagged omitwindows are left out if isWindows is true.
+func (c *Type) ToArgs(isWindows bool) ([]string, error) {
+	var args []string
+	var unmapped []string
+	for _, s := range c.settings(isWindows) {
+		if strings.HasPrefix(s.key, setParameterFlag+".") {
+			args = append(args, "--"+setParameterFlag, strings.TrimPrefix(s.key, setParameterFlag+".")+"="+s.value)
+			continue
+		}
+		opt := findArgOption(s.key)
+		if opt == nil {
+			unmapped = append(unmapped, s.key)
+			continue
+		}
+		switch opt.kind {
+		case argValue:
+			value := s.value
+			if mapped, ok := opt.values[value]; ok {
+				value = mapped
+			}
+			args = append(args, "--"+opt.flag, value)
+		case argSwitch:
+			if s.value == "true" {
+				args = append(args, "--"+opt.flag)
+			}
+		case argNoSwitch:
+			if s.value == "false" {
+				args = append(args, "--"+opt.flag)
+			}
+		case argMapped:
+			flag, ok := opt.values[s.value]
+			if !ok {
+				return nil, fmt.Errorf("%s: value '%s' has no command-line equivalent", s.key, s.value)
+			}
+			if flag != "" {
+				args = append(args, "--"+flag)
+			}
+		case argVerbose:
+			var n int
+			_, _ = fmt.Sscanf(s.value, "%d", &n)
+			args = append(args, "-"+strings.Repeat("v", n))
+		}
+	}
+	if len(unmapped) > 0 {
+		return nil, fmt.Errorf("no command-line equivalent for %s", strings.Join(unmapped, ", "))
+	}
+	return args, nil
+}
+
+// FromArgs reads mongod/mongos command-line arguments into a config
+// A leading program name is skipped. Options that have no corresponding field are returned, as for FromYaml;
+// --config/-f is an error since the options would come from a file.
+func FromArgs(args []string) (*Type, []string, error) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = args[1:]
+	}
+	root := &yamlNode{kind: yamlMap}
+	var unknown []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return nil, nil, fmt.Errorf("unexpected argument '%s'", arg)
+		}
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		// take the next argument as the value, for options that have one
+		nextValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 == len(args) || strings.HasPrefix(args[i+1], "--") {
+				return "", fmt.Errorf("option %s requires a value", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		if name == "config" || name == "f" {
+			return nil, nil, fmt.Errorf("options are read from a config file (%s), import that file instead", arg)
+		}
+		if name == "verbose" || strings.Trim(name, "v") == "" {
+			level := len(name)
+			if name == "verbose" {
+				level = 1
+			}
+			setArgNode(root, "systemLog.verbosity", &yamlNode{kind: yamlScalar, value: fmt.Sprint(level)})
+			continue
+		}
+		if name == setParameterFlag {
+			p, err := nextValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			j := strings.Index(p, "=")
+			if j <= 0 {
+				return nil, nil, fmt.Errorf("--setParameter '%s' is not in the form name=value", p)
+			}
+			node, err := parseInline(p[j+1:], 0)
+			if err != nil {
+				return nil, nil, fmt.Errorf("--setParameter %s: %v", p[:j], err)
+			}
+			params := childNode(root, setParameterFlag)
+			params.pairs = append(params.pairs, yamlPair{key: p[:j], value: node})
+			continue
+		}
+
+		found := false
+		for _, opt := range argOptions {
+			switch {
+			case opt.kind == argMapped:
+				for v, flag := range opt.values {
+					if flag == name && flag != "" {
+						setArgNode(root, opt.key, &yamlNode{kind: yamlScalar, value: v})
+						found = true
+					}
+				}
+			case opt.flag == name:
+				found = true
+				switch opt.kind {
+				case argSwitch, argNoSwitch:
+					v := opt.kind == argSwitch
+					if hasValue && (value == "false" || value == "0") {
+						v = !v
+					}
+					setArgNode(root, opt.key, &yamlNode{kind: yamlScalar, value: fmt.Sprint(v)})
+				default:
+					v, err := nextValue()
+					if err != nil {
+						return nil, nil, err
+					}
+					for yamlValue, argValue := range opt.values {
+						if argValue == v {
+							v = yamlValue
+						}
+					}
+					setArgNode(root, opt.key, &yamlNode{kind: yamlScalar, value: v, quoted: true})
+				}
+			}
+		}
+		if !found {
+			unknown = append(unknown, arg)
+			// assume an unknown option takes a value if the next argument isn't an option
+			if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+			}
+		}
+	}
+	// --logpath implies logging to a file
+	if cfg := childNode(root, "systemLog"); hasPair(cfg, "path") && !hasPair(cfg, "destination") {
+		setArgNode(root, "systemLog.destination", &yamlNode{kind: yamlScalar, value: "file"})
+	}
+	cfg := new(Type)
+	cv := reflect.ValueOf(cfg).Elem()
+	setDefaults(&cv)
+	err := decodeStruct("", root, &cv, &unknown)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, unknown, nil
+}
+
+// set the node at a dotted key, creating mappings as needed; a later value replaces an earlier one
+func setArgNode(root *yamlNode, key string, value *yamlNode) {
+	parts := strings.Split(key, ".")
+	n := root
+	for _, part := range parts[:len(parts)-1] {
+		n = childNode(n, part)
+	}
+	last := parts[len(parts)-1]
+	for i := range n.pairs {
+		if n.pairs[i].key == last {
+			n.pairs[i].value = value
+			return
+		}
+	}
+	n.pairs = append(n.pairs, yamlPair{key: last, value: value})
+}
+
+func hasPair(n *yamlNode, key string) bool {
+	for _, pair := range n.pairs {
+		if pair.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// the mapping under key in n, created if it isn't there
+func childNode(n *yamlNode, key string) *yamlNode {
+	for _, pair := range n.pairs {
+		if pair.key == key {
+			return pair.value
+		}
+	}
+	child := &yamlNode{kind: yamlMap}
+	n.pairs = append(n.pairs, yamlPair{key: key, value: child})
+	return child
+}
+
+// SplitCommandLine splits a command line into arguments: as a POSIX shell does, handling single and double quotes
+// and backslashes, or if isWindows as Windows programs do, where backslashes are literal except before a double quote
+func SplitCommandLine(s string, isWindows bool) ([]string, error) {
+	if isWindows {
+		return splitWindowsCommandLine(s), nil
+	}
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command line")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// split a command line by the rules of CommandLineToArgvW: double quotes group, 2n backslashes before a double quote
+// are n backslashes and the quote groups, 2n+1 are n backslashes and a literal quote, and "" within quotes is a quote
+func splitWindowsCommandLine(s string) []string {
+	var args []string
+	var cur strings.Builder
+	inArg, quoted := false, false
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			n := 0
+			for ; i < len(runes) && runes[i] == '\\'; i++ {
+				n++
+			}
+			if i < len(runes) && runes[i] == '"' {
+				cur.WriteString(strings.Repeat(`\`, n/2))
+				if n%2 == 1 {
+					cur.WriteRune('"')
+				} else {
+					quoted = !quoted
+				}
+			} else {
+				cur.WriteString(strings.Repeat(`\`, n))
+				i-- // not a backslash, look at it again
+			}
+			inArg = true
+		case r == '"':
+			if quoted && i+1 < len(runes) && runes[i+1] == '"' {
+				cur.WriteRune('"')
+				i++
+			} else {
+				quoted = !quoted
+			}
+			inArg = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n' || r == '\r'):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToArgs(t *testing.T) {
+	c := OurDefaults.Copy()
+	c.Storage.DbPath = "/data/db"
+	c.SystemLog.Path = "/var/log/mongod.log"
+	c.Net.Port = 27018
+	c.Net.Tls.Mode = "requireTLS"
+	c.Replication.ReplSetName = "rs0"
+	c.OperationProfiling.Mode = "slowOp"
+	c.SystemLog.Verbosity = 2
+	c.SetParameter = Parameters{"enableTestCommands": true}
+
+	got, err := c.ToArgs(false)
+	if err != nil {
+		t.Fatalf("ToArgs(): %v", err)
+	}
+	for _, want := range [][]string{
+		{"--dbpath", "/data/db"},
+		{"--port", "27018"},
+		{"--tlsMode", "requireTLS"},
+		{"--replSet", "rs0"},
+		{"--profile", "1"},
+		{"-vv"},
+		{"--auth"},
+		{"--noscripting"},
+		{"--nounixsocket"},
+		{"--setParameter", "enableTestCommands=true"},
+	} {
+		if !containsArgs(got, want) {
+			t.Errorf("ToArgs(): %v does not contain %v", got, want)
+		}
+	}
+	got, err = c.ToArgs(true)
+	if err != nil {
+		t.Fatalf("ToArgs(windows): %v", err)
+	}
+	if containsArgs(got, []string{"--nounixsocket"}) {
+		t.Errorf("ToArgs(windows): %v contains --nounixsocket", got)
+	}
+
+	got, _ = c.ToArgs(false)
+	back, unknown, err := FromArgs(append([]string{"mongod"}, got...))
+	if err != nil {
+		t.Fatalf("FromArgs(): %v", err)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("FromArgs(): unknown %v", unknown)
+	}
+	if diffs := Diff(c, back); len(diffs) != 0 {
+		t.Errorf("FromArgs(ToArgs()): differences %v", diffs)
+	}
+}
+
+func TestFromArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		check   func(c *Type) bool
+		unknown []string
+		wantErr bool
+	}{
+		{"values", "mongod --port 27019 --dbpath=/tmp/db --bind_ip localhost", func(c *Type) bool {
+			return c.Net.Port == 27019 && c.Storage.DbPath == "/tmp/db" && c.Net.BindIp == "localhost"
+		}, nil, false},
+		{"switches", "--auth --nojournal --fork --noscripting", func(c *Type) bool {
+			return c.Security.Authorization == "enabled" && !c.Storage.Journal.Enabled && c.ProcessManagement.Fork && !c.Security.JavascriptEnabled
+		}, nil, false},
+		{"quoted", `--logpath '/var/log/my mongod.log' -vvv`, func(c *Type) bool {
+			return c.SystemLog.Path == "/var/log/my mongod.log" && c.SystemLog.Verbosity == 3
+		}, nil, false},
+		{"setParameter", `--setParameter enableTestCommands=1 --setParameter "logComponentVerbosity={storage: 2}"`, func(c *Type) bool {
+			doc, ok := c.SetParameter["logComponentVerbosity"].(map[string]interface{})
+			return c.SetParameter["enableTestCommands"] == 1 && ok && doc["storage"] == 2
+		}, nil, false},
+		{"unknown", "--port 27017 --sslFIPSMode --enableMajorityReadConcern false", func(c *Type) bool {
+			return c.Net.Port == 27017
+		}, []string{"--sslFIPSMode", "--enableMajorityReadConcern"}, false},
+		{"config file", "mongod -f /etc/mongod.conf", nil, nil, true},
+		{"missing value", "--port", nil, nil, true},
+		{"unterminated", "--logpath '/var/log", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := SplitCommandLine(tt.line, false)
+			var c *Type
+			var unknown []string
+			if err == nil {
+				c, unknown, err = FromArgs(args)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !tt.check(c) {
+				t.Errorf("FromArgs(): got %v", c)
+			}
+			if !reflect.DeepEqual(unknown, tt.unknown) {
+				t.Errorf("FromArgs(): unknown got %v, wanted %v", unknown, tt.unknown)
+			}
+		})
+	}
+}
+
+func containsArgs(args []string, want []string) bool {
+	for i := 0; i+len(want) <= len(args); i++ {
+		if reflect.DeepEqual(args[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSplitCommandLine_Windows(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`"C:\Program Files\MongoDB\Server\4.4\bin\mongod.exe" --dbpath "C:\Program Files\MongoDB\data" --logpath C:\mongo\mongod.log`,
+			[]string{`C:\Program Files\MongoDB\Server\4.4\bin\mongod.exe`, "--dbpath", `C:\Program Files\MongoDB\data`, "--logpath", `C:\mongo\mongod.log`}},
+		{`--dbpath "C:\data\\" --port 27018`, []string{"--dbpath", `C:\data\`, "--port", "27018"}},
+		{`--setParameter "a={\"b\": 1}" 'x y'`, []string{"--setParameter", `a={"b": 1}`, "'x", "y'"}},
+		{`"say ""hi"""`, []string{`say "hi"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := SplitCommandLine(tt.line, true)
+			if err != nil {
+				t.Fatalf("SplitCommandLine(): %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitCommandLine(): got %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
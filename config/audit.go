@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+)
+
+// AuditFile is the file the member writes audit events to, "" if it doesn't audit to a file
+func (c *Type) AuditFile() string {
+	if c.AuditLog.Destination != "file" {
+		return ""
+	}
+	return c.AuditLog.Path
+}
+
+// problems with the auditLog settings
+func (c *Type) auditProblems() []string {
+	a := c.AuditLog
+	var problems []string
+	if a.Destination == "file" {
+		if a.Format == "" {
+			problems = append(problems, "auditLog.format: JSON or BSON is required with destination file")
+		}
+		if a.Path == "" {
+			problems = append(problems, "auditLog.path: required with destination file")
+		}
+	} else if a.Format != "" || a.Path != "" {
+		problems = append(problems, "auditLog: format and path have no effect unless destination is file")
+	}
+	if a.Destination == "" && a.Filter != "" {
+		problems = append(problems, "auditLog.filter: has no effect without a destination")
+	}
+	if f := strings.TrimSpace(a.Filter); f != "" && (!strings.HasPrefix(f, "{") || !strings.HasSuffix(f, "}")) {
+		problems = append(problems, "auditLog.filter: must be a query document, e.g. { atype: \"authenticate\" }")
+	}
+	return problems
+}
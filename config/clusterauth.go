@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Cluster authentication modes in the order a cluster moves through them to go from keyfiles to x.509
+// Members can only talk to each other if their modes are adjacent in this list, so a cluster changes mode one
+// step at a time, every member moving to the next mode before any member moves on.
+var ClusterAuthModes = []string{"keyFile", "sendKeyFile", "sendX509", "x509"}
+
+// ClusterAuthMode is the effective security.clusterAuthMode, "keyFile" if it is not set
+func (c *Type) ClusterAuthMode() string {
+	if c.Security.ClusterAuthMode == "" {
+		return "keyFile"
+	}
+	return c.Security.ClusterAuthMode
+}
+
+// ClusterAuthSteps lists the modes a cluster passes through, in order, to change from one cluster auth mode to another
+func ClusterAuthSteps(from string, to string) ([]string, error) {
+	i, j := clusterAuthIndex(from), clusterAuthIndex(to)
+	if i < 0 {
+		return nil, fmt.Errorf("unknown clusterAuthMode '%s'", from)
+	}
+	if j < 0 {
+		return nil, fmt.Errorf("unknown clusterAuthMode '%s', must be one of %v", to, ClusterAuthModes)
+	}
+	var steps []string
+	for i != j {
+		if i < j {
+			i++
+		} else {
+			i--
+		}
+		steps = append(steps, ClusterAuthModes[i])
+	}
+	return steps, nil
+}
+
+func clusterAuthIndex(mode string) int {
+	if mode == "" {
+		mode = "keyFile"
+	}
+	for i, m := range ClusterAuthModes {
+		if m == mode {
+			return i
+		}
+	}
+	return -1
+}
+
+// problems with the settings a cluster auth mode relies on
+// Every mode but x509 accepts keyfiles from other members, and every mode but keyFile uses the member's certificate.
+func (c *Type) clusterAuthProblems() []string {
+	var problems []string
+	mode := c.ClusterAuthMode()
+	if c.Security.ClusterAuthMode != "" && mode != "x509" && c.Security.KeyFile == "" {
+		problems = append(problems, fmt.Sprintf("security.clusterAuthMode: %s requires security.keyFile", mode))
+	}
+	if mode == "keyFile" {
+		return problems
+	}
+	tls := c.TLS()
+	if !c.TLSEnabled() {
+		problems = append(problems, fmt.Sprintf("security.clusterAuthMode: %s requires TLS to be enabled", mode))
+	} else if *tls.CertificateKeyFile == "" && *tls.ClusterFile == "" {
+		problems = append(problems, fmt.Sprintf("security.clusterAuthMode: %s requires a certificate", mode))
+	}
+	return problems
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterAuthSteps(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		want    []string
+		wantErr bool
+	}{
+		{"keyFile", "x509", []string{"sendKeyFile", "sendX509", "x509"}, false},
+		{"", "sendKeyFile", []string{"sendKeyFile"}, false},
+		{"x509", "keyFile", []string{"sendX509", "sendKeyFile", "keyFile"}, false},
+		{"sendX509", "sendX509", nil, false},
+		{"keyFile", "x.509", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			got, err := ClusterAuthSteps(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ClusterAuthSteps() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClusterAuthSteps() got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,30 +5,71 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 )
 
 type Type struct {
 	Storage struct {
-		DbPath     string
-		Engine     string // default is "wiredTiger" vs "inMemory"
+		DbPath         string
+		Engine         string `enum:"wiredTiger,inMemory,mmapv1"` // default is "wiredTiger" vs "inMemory"
+		DirectoryPerDB bool   // default is false
+		Journal        struct {
+			Enabled bool `default:"true"` // default is true
+		}
 		WiredTiger struct {
 			EngineConfig struct {
 				CacheSizeGB float32
 			}
+			CollectionConfig struct {
+				BlockCompressor string `enum:"none,snappy,zlib,zstd"` // default is "snappy" vs "none", "zlib", "zstd"
+			}
+			IndexConfig struct {
+				PrefixCompression bool `default:"true"` // default is true
+			}
 		}
 	}
 
 	SystemLog struct {
-		Destination     string // default is syslog if not specified, use "file"
+		Destination     string `enum:"file,syslog"` // default is syslog if not specified, use "file"
 		Path            string // required if Destination = "file"
-		TimeStampFormat string // default is "iso8601-local" vs "iso8601-utc"
+		TimeStampFormat string `enum:"iso8601-local,iso8601-utc"` // default is "iso8601-local" vs "iso8601-utc"
 		LogAppend       bool   // default is false
 		Verbosity       uint   // default is zero
 	}
 
 	Security struct {
-		Authorization     string // default is "disabled" vs. "enabled"
-		JavascriptEnabled bool   `default:"true"` // default is true
+		Authorization     string   `enum:"disabled,enabled"` // default is "disabled" vs. "enabled"
+		JavascriptEnabled bool     `default:"true"`          // default is true
+		KeyFile           string   // shared key for internal authentication
+		ClusterAuthMode   string   `enum:"keyFile,sendKeyFile,sendX509,x509"` // default is "keyFile" vs "sendKeyFile", "sendX509", "x509"
+		Ldap              struct { // Enterprise only
+			Servers string // comma-separated host:port list
+			Bind    struct {
+				Method         string `enum:"simple,sasl"` // default is "simple"
+				SaslMechanisms string // default is "DIGEST-MD5"
+				QueryUser      string // DN to bind as for queries
+				QueryPassword  string
+			}
+			TransportSecurity string `enum:"tls,none"` // default is "tls"
+			TimeoutMS         int    // default is 10000
+			UserToDNMapping   string // JSON array of {match, substitution} or {match, ldapQuery} documents
+			Authz             struct {
+				QueryTemplate string // RFC 4516 URL finding a user's groups, e.g. "ou=groups,dc=example,dc=com??sub?(member={USER})"
+			}
+			ValidateLDAPServerConfig bool `default:"true"` // default is true
+		}
+		EnableEncryption     bool   // Enterprise only, encryption at rest for WiredTiger
+		EncryptionCipherMode string `enum:"AES256-CBC,AES256-GCM"` // default is "AES256-CBC"
+		EncryptionKeyFile    string // local key file, base64 of a 32-byte key; alternative to kmip
+		Kmip                 struct {
+			KeyIdentifier             string // master key on the KMIP server; "" creates one on first startup
+			RotateMasterKey           bool   // rotate the master key and exit
+			ServerName                string
+			Port                      uint // default is 5696
+			ClientCertificateFile     string
+			ClientCertificatePassword string
+			ServerCAFile              string
+		}
 	}
 
 	Net struct {
@@ -38,17 +79,70 @@ type Type struct {
 		UnixDomainSocket struct { // not valid on Windows
 			Enabled bool `default:"true" omitwindows:"true"` // default is true
 		}
+		Tls struct {
+			Mode                                string `enum:"disabled,allowTLS,preferTLS,requireTLS"` // default is "disabled" vs "allowTLS", "preferTLS", "requireTLS"
+			CertificateKeyFile                  string
+			CertificateKeyFilePassword          string
+			CAFile                              string `yaml:"CAFile"`
+			CRLFile                             string `yaml:"CRLFile"`
+			ClusterFile                         string
+			AllowConnectionsWithoutCertificates bool
+			AllowInvalidCertificates            bool
+			AllowInvalidHostnames               bool
+			DisabledProtocols                   []string `join:"," enum:"TLS1_0,TLS1_1,TLS1_2,TLS1_3"` // e.g. "TLS1_0,TLS1_1"
+		}
+		Ssl struct { // renamed to Tls in 4.2
+			Mode                                string `enum:"disabled,allowSSL,preferSSL,requireSSL"` // default is "disabled" vs "allowSSL", "preferSSL", "requireSSL"
+			PEMKeyFile                          string `yaml:"PEMKeyFile"`
+			PEMKeyPassword                      string `yaml:"PEMKeyPassword"`
+			CAFile                              string `yaml:"CAFile"`
+			CRLFile                             string `yaml:"CRLFile"`
+			ClusterFile                         string
+			AllowConnectionsWithoutCertificates bool
+			AllowInvalidCertificates            bool
+			AllowInvalidHostnames               bool
+			DisabledProtocols                   []string `join:"," enum:"TLS1_0,TLS1_1,TLS1_2,TLS1_3"`
+		}
+		Compression struct {
+			Compressors []string `join:"," enum:"snappy,zstd,zlib,disabled"` // default is "snappy,zstd,zlib"
+		}
 	}
 
 	ProcessManagement struct {
-		Fork bool `omitwindows:"true"`
+		Fork        bool `omitwindows:"true"`
+		PidFilePath string
+	}
+
+	Replication struct {
+		ReplSetName string
+		OplogSizeMB int // default is 5% of free disk space
+	}
+
+	Sharding struct {
+		ClusterRole string `enum:"configsvr,shardsvr"` // "configsvr" or "shardsvr"
+		ConfigDB    string // mongos only: "<configReplSetName>/host1:port,host2:port"
+	}
+
+	OperationProfiling struct {
+		Mode              string  `default:"off" enum:"off,slowOp,all"` // default is "off" vs "slowOp", "all"
+		SlowOpThresholdMs int     `default:"100"`                       // default is 100
+		SlowOpSampleRate  float64 `default:"1.0"`                       // default is 1.0
 	}
 
-	SetParameter struct {
-		AuthenticationMechanisms string // default ?
+	AuditLog struct { // Enterprise only
+		Destination string `enum:"syslog,console,file"` // "syslog", "console" or "file"
+		Format      string `enum:"JSON,BSON"`           // "JSON" or "BSON", required if Destination = "file"
+		Path        string // required if Destination = "file"
+		Filter      string // JSON document selecting the events to audit
 	}
+
+	SetParameter Parameters
 }
 
+// Server parameters for setParameter, keyed by parameter name, e.g. "authenticationMechanisms"
+// Values are bool, int, float64, string, or nested documents as map[string]interface{}
+type Parameters map[string]interface{}
+
 // Config struct with MongoDB defaults applied
 var MongoDBDefaults *Type
 
@@ -59,13 +153,15 @@ func init() {
 
 	// set defaults in config as MongoDB would have them
 	t1 := &Type{}
-	t1.Security.JavascriptEnabled = true
-	t1.Net.UnixDomainSocket.Enabled = true
+	v1 := reflect.ValueOf(t1).Elem()
+	setDefaults(&v1)
 	MongoDBDefaults = t1
 
 	// set opinionated config
-	t2 := &Type{}
+	t2 := MongoDBDefaults.Copy()
 	// leave javascript and unix domain socket disabled, as well as logAppend
+	t2.Security.JavascriptEnabled = false
+	t2.Net.UnixDomainSocket.Enabled = false
 	t2.Storage.Engine = "wiredTiger"
 	t2.Storage.WiredTiger.EngineConfig.CacheSizeGB = 0.5
 	t2.SystemLog.Destination = "file"
@@ -74,7 +170,57 @@ func init() {
 	OurDefaults = t2
 }
 
-// Write config file fname to fpath, also writes fname.gob with the Go Binary representation of the config
+// Copy returns a deep copy of a config, so that changes to the copy never affect the original
+func (c *Type) Copy() *Type {
+	x := new(Type)
+	dst := reflect.ValueOf(x).Elem()
+	copyValue(dst, reflect.ValueOf(c).Elem())
+	return x
+}
+
+func copyValue(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			copyValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			copyValue(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			copyValue(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		copyValue(v, src.Elem())
+		dst.Set(v)
+	default:
+		dst.Set(src)
+	}
+}
+
+// Write config file fname to fpath, also writes fname.json with the versioned JSON representation of the config
 // Creates directories for config, dbPath and log destination
 func WriteConfig(x *Type, fpath string, fname string, isWindows bool) error {
 	res2 := x.ToYaml(isWindows)
@@ -102,29 +248,32 @@ func WriteConfig(x *Type, fpath string, fname string, isWindows bool) error {
 	if err != nil {
 		return fmt.Errorf("Chmod error: %v", err)
 	}
-	// Create GoB file containing the Go struct for the config in binary format
-	fnameGoB := fname + ".gob"
-	fnGoB := filepath.Join(fpath, fnameGoB)
-	fdGoB, err := os.Create(fnGoB)
+	// Create JSON side file containing every field of the config, replacing any GoB file an older version wrote
+	fnJSON := filepath.Join(fpath, fname+".json")
+	fdJSON, err := os.Create(fnJSON)
 	if err != nil {
-		return fmt.Errorf("GoB file create error: %v", err)
+		return fmt.Errorf("JSON file create error: %v", err)
 	}
-	res3, err := x.ToGoB()
+	res3, err := x.ToJSON()
 	if err != nil {
-		return fmt.Errorf("GoB encode error: %v", err)
+		return fmt.Errorf("JSON encode error: %v", err)
 	}
-	_, err = io.Copy(fdGoB, res3)
+	_, err = io.Copy(fdJSON, res3)
 	if err != nil {
-		_ = fdGoB.Close()
-		return fmt.Errorf("GoB file write error: %v", err)
+		_ = fdJSON.Close()
+		return fmt.Errorf("JSON file write error: %v", err)
 	}
-	err = fdGoB.Close()
+	err = fdJSON.Close()
 	if err != nil {
-		return fmt.Errorf("GoB file close error: %v", err)
+		return fmt.Errorf("JSON file close error: %v", err)
 	}
-	err = os.Chmod(fnGoB, 0644)
+	err = os.Chmod(fnJSON, 0644)
 	if err != nil {
-		return fmt.Errorf("GoB Chmod error: %v", err)
+		return fmt.Errorf("JSON Chmod error: %v", err)
+	}
+	err = os.Remove(filepath.Join(fpath, fname+".gob"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("GoB file remove error: %v", err)
 	}
 	// Create directories for dbPath
 	err = os.MkdirAll(x.Storage.DbPath, 0777)
@@ -136,5 +285,12 @@ func WriteConfig(x *Type, fpath string, fname string, isWindows bool) error {
 	if err != nil {
 		return fmt.Errorf("logpath MkDirAll error: %v", err)
 	}
+	// Create directories for processManagement.pidFilePath
+	if x.ProcessManagement.PidFilePath != "" {
+		err = os.MkdirAll(filepath.Dir(x.ProcessManagement.PidFilePath), 0777)
+		if err != nil {
+			return fmt.Errorf("pidFilePath MkDirAll error: %v", err)
+		}
+	}
 	return nil
 }
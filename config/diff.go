@@ -0,0 +1,51 @@
+package config
+
+import "fmt"
+
+// A Difference is one setting that differs between two configs
+// Settings at their default value count as absent, following the default tags used when writing YAML.
+type Difference struct {
+	Key  string `json:"key"`
+	Kind string `json:"kind"`          // "added", "removed" or "changed"
+	Old  string `json:"old,omitempty"` // value in the first config, "" if added
+	New  string `json:"new,omitempty"` // value in the second config, "" if removed
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case "added":
+		return fmt.Sprintf("+ %s: %s", d.Key, d.New)
+	case "removed":
+		return fmt.Sprintf("- %s: %s", d.Key, d.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", d.Key, d.Old, d.New)
+	}
+}
+
+// Diff lists the settings that differ from a to b, in struct order
+func Diff(a *Type, b *Type) []Difference {
+	as := a.settings(false)
+	bs := b.settings(false)
+	bValues := make(map[string]string, len(bs))
+	for _, s := range bs {
+		bValues[s.key] = s.value
+	}
+	var diffs []Difference
+	inA := make(map[string]bool, len(as))
+	for _, s := range as {
+		inA[s.key] = true
+		v, ok := bValues[s.key]
+		switch {
+		case !ok:
+			diffs = append(diffs, Difference{Key: s.key, Kind: "removed", Old: s.value})
+		case v != s.value:
+			diffs = append(diffs, Difference{Key: s.key, Kind: "changed", Old: s.value, New: v})
+		}
+	}
+	for _, s := range bs {
+		if !inA[s.key] {
+			diffs = append(diffs, Difference{Key: s.key, Kind: "added", New: s.value})
+		}
+	}
+	return diffs
+}
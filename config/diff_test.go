@@ -0,0 +1,35 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := OurDefaults.Copy()
+	a.Net.Port = 27018
+	a.SetParameter = Parameters{"enableTestCommands": 1}
+	b := OurDefaults.Copy()
+	b.Storage.WiredTiger.EngineConfig.CacheSizeGB = 2
+	b.Security.JavascriptEnabled = true // default for MongoDB, but not in OurDefaults
+	b.OperationProfiling.SlowOpThresholdMs = 100
+	b.SetParameter = Parameters{"enableTestCommands": 0}
+
+	want := []Difference{
+		{Key: "storage.wiredTiger.engineConfig.cacheSizeGB", Kind: "changed", Old: "0.50", New: "2.00"},
+		{Key: "security.javascriptEnabled", Kind: "removed", Old: "false"},
+		{Key: "net.port", Kind: "removed", Old: "27018"},
+		{Key: "setParameter.enableTestCommands", Kind: "changed", Old: "1", New: "0"},
+	}
+	got := Diff(a, b)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff(): got %v, wanted %v", got, want)
+	}
+	if got := Diff(a, a); len(got) != 0 {
+		t.Errorf("Diff(): same config got %v, wanted none", got)
+	}
+	got = Diff(MongoDBDefaults, OurDefaults)
+	if len(got) == 0 || got[0].Kind != "added" {
+		t.Errorf("Diff(): defaults to ours got %v", got)
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+// LocalKMIPServer is where "encryption kmip" listens, standing in for a customer's KMIP server
+const LocalKMIPServer = "localhost:5696"
+
+// EncryptionEnabled reports whether the member encrypts its data files
+func (c *Type) EncryptionEnabled() bool {
+	return c.Security.EnableEncryption
+}
+
+// KMIPEnabled reports whether the member's master key is kept on a KMIP server rather than in a local key file
+func (c *Type) KMIPEnabled() bool {
+	return c.Security.Kmip.ServerName != ""
+}
+
+// problems with the settings encryption at rest relies on
+func (c *Type) encryptionProblems() []string {
+	s := c.Security
+	if !s.EnableEncryption {
+		if s.EncryptionKeyFile != "" || c.KMIPEnabled() || s.EncryptionCipherMode != "" {
+			return []string{"security: encryption settings have no effect without security.enableEncryption"}
+		}
+		return nil
+	}
+	var problems []string
+	switch {
+	case s.EncryptionKeyFile != "" && c.KMIPEnabled():
+		problems = append(problems, "security: encryptionKeyFile and kmip.serverName can't both be set")
+	case s.EncryptionKeyFile == "" && !c.KMIPEnabled():
+		problems = append(problems, "security.enableEncryption: needs encryptionKeyFile or kmip.serverName for the master key")
+	}
+	if c.KMIPEnabled() && s.Kmip.ClientCertificateFile == "" {
+		problems = append(problems, "security.kmip: clientCertificateFile is needed to authenticate to the KMIP server")
+	}
+	if s.Kmip.RotateMasterKey && !c.KMIPEnabled() {
+		problems = append(problems, "security.kmip.rotateMasterKey: only KMIP master keys can be rotated")
+	}
+	if c.Storage.Engine != "" && c.Storage.Engine != "wiredTiger" {
+		problems = append(problems, "security.enableEncryption: needs the wiredTiger storage engine")
+	}
+	return problems
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+)
+
+// The config struct as older versions wrote it to GoB side files, frozen so those files can still be read
+// Don't change it: GoB matches fields by name and kind, and SetParameter has since become Parameters.
+type gobType struct {
+	Storage struct {
+		DbPath     string
+		Engine     string
+		WiredTiger struct {
+			EngineConfig struct {
+				CacheSizeGB float32
+			}
+		}
+	}
+
+	SystemLog struct {
+		Destination     string
+		Path            string
+		TimeStampFormat string
+		LogAppend       bool
+		Verbosity       uint
+	}
+
+	Security struct {
+		Authorization     string
+		JavascriptEnabled bool
+	}
+
+	Net struct {
+		Port             uint
+		BindIp           string
+		Ipv6             bool
+		UnixDomainSocket struct {
+			Enabled bool
+		}
+	}
+
+	ProcessManagement struct {
+		Fork bool
+	}
+
+	SetParameter struct {
+		AuthenticationMechanisms string
+	}
+}
+
+// Read a GoB config in, as written by older versions
+// Settings the old struct didn't have get MongoDB's defaults.
+func FromGoB(in []byte) (*Type, error) {
+	old := new(gobType)
+	err := gob.NewDecoder(bytes.NewReader(in)).Decode(old)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding config: %v", err)
+	}
+	cfg := new(Type)
+	cv := reflect.ValueOf(cfg).Elem()
+	setDefaults(&cv)
+	ov := reflect.ValueOf(old).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		name := ov.Type().Field(i).Name
+		if name == "SetParameter" {
+			continue
+		}
+		copyFields(cv.FieldByName(name), ov.Field(i))
+	}
+	if mechs := old.SetParameter.AuthenticationMechanisms; mechs != "" {
+		cfg.SetParameter = Parameters{"authenticationMechanisms": mechs}
+	}
+	return cfg, nil
+}
+
+// copy the fields of struct src to the fields of the same names in struct dst
+func copyFields(dst reflect.Value, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		sv := src.Field(i)
+		dv := dst.FieldByName(src.Type().Field(i).Name)
+		if sv.Kind() == reflect.Struct {
+			copyFields(dv, sv)
+		} else {
+			dv.Set(sv)
+		}
+	}
+}
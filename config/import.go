@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LocalLDAPServer is where "ldap serve" listens, standing in for a customer's LDAP servers
+const LocalLDAPServer = "localhost:3389"
+
+// A Change records one modification made to a configuration so it can run locally
+type Change struct {
+	Key    string // dotted setting name, e.g. "storage.dbPath"
+	Old    string // previous value, "" if the setting was not set
+	New    string // new value, "" if the setting was removed
+	Reason string
+}
+
+func (c Change) String() string {
+	switch {
+	case c.New == "" && c.Old == "":
+		return fmt.Sprintf("%s: removed (%s)", c.Key, c.Reason)
+	case c.New == "":
+		return fmt.Sprintf("%s: removed '%s' (%s)", c.Key, c.Old, c.Reason)
+	case c.Old == "":
+		return fmt.Sprintf("%s: set to '%s' (%s)", c.Key, c.New, c.Reason)
+	default:
+		return fmt.Sprintf("%s: '%s' -> '%s' (%s)", c.Key, c.Old, c.New, c.Reason)
+	}
+}
+
+// Localize rewrites a customer's configuration so it can run on this machine with its files under dir
+// Paths are moved into dir, and settings that refer to the customer's environment are neutralized.
+// security.keyFile and the TLS and KMIP CA files are left for the caller to point at files shared by the deployment.
+// unknown is the list of settings FromYaml or FromCmdLineOpts could not represent; they are reported as dropped.
+// Every modification is returned, in the order it was made.
+func Localize(x *Type, unknown []string, dir string) []Change {
+	var changes []Change
+	setString := func(key string, field *string, value string, reason string) {
+		if *field != value {
+			changes = append(changes, Change{Key: key, Old: *field, New: value, Reason: reason})
+			*field = value
+		}
+	}
+
+	setString("storage.dbPath", &x.Storage.DbPath, filepath.Join(dir, "data"), "moved into runtime directory")
+	if x.SystemLog.Destination != "file" {
+		setString("systemLog.destination", &x.SystemLog.Destination, "file", "log to a file in the runtime directory")
+	}
+	setString("systemLog.path", &x.SystemLog.Path, filepath.Join(dir, "mongod.log"), "moved into runtime directory")
+	setString("net.bindIp", &x.Net.BindIp, localBindIp(x.Net.BindIp), "customer's addresses are not available locally")
+	moveFile := func(key string, field *string) {
+		if *field != "" {
+			setString(key, field, filepath.Join(dir, filepath.Base(*field)), "moved into runtime directory")
+		}
+	}
+	moveFile("processManagement.pidFilePath", &x.ProcessManagement.PidFilePath)
+	moveFile("net.tls.certificateKeyFile", &x.Net.Tls.CertificateKeyFile)
+	moveFile("net.tls.CRLFile", &x.Net.Tls.CRLFile)
+	moveFile("net.tls.clusterFile", &x.Net.Tls.ClusterFile)
+	moveFile("net.ssl.PEMKeyFile", &x.Net.Ssl.PEMKeyFile)
+	moveFile("net.ssl.CRLFile", &x.Net.Ssl.CRLFile)
+	moveFile("net.ssl.clusterFile", &x.Net.Ssl.ClusterFile)
+	moveFile("security.encryptionKeyFile", &x.Security.EncryptionKeyFile)
+	moveFile("security.kmip.clientCertificateFile", &x.Security.Kmip.ClientCertificateFile)
+	if x.AuditLog.Destination == "file" {
+		moveFile("auditLog.path", &x.AuditLog.Path)
+	}
+	if x.Security.Ldap.Servers != "" {
+		setString("security.ldap.servers", &x.Security.Ldap.Servers, LocalLDAPServer, "customer's directory is not available locally")
+		if x.Security.Ldap.TransportSecurity != "none" {
+			setString("security.ldap.transportSecurity", &x.Security.Ldap.TransportSecurity, "none", "the local LDAP server does not use TLS")
+		}
+	}
+	if x.KMIPEnabled() {
+		kmip := &x.Security.Kmip
+		setString("security.kmip.serverName", &kmip.ServerName, "localhost", "customer's KMIP server is not available locally")
+		if kmip.Port != 0 && kmip.Port != 5696 {
+			changes = append(changes, Change{Key: "security.kmip.port", Old: fmt.Sprint(kmip.Port), Reason: "the local KMIP server uses the default port"})
+			kmip.Port = 0
+		}
+		setString("security.kmip.keyIdentifier", &kmip.KeyIdentifier, "", "customer's master key is not available, one is created on first startup")
+		setString("security.kmip.clientCertificatePassword", &kmip.ClientCertificatePassword, "", "local certificates have no password")
+	}
+
+	for _, key := range unknown {
+		changes = append(changes, Change{Key: key, Reason: "not supported, dropped"})
+	}
+	return changes
+}
+
+// keep only the wildcard and loopback addresses from a comma-separated bindIp list
+func localBindIp(bindIp string) string {
+	if bindIp == "" {
+		return ""
+	}
+	var keep []string
+	for _, ip := range strings.Split(bindIp, ",") {
+		ip = strings.TrimSpace(ip)
+		switch ip {
+		case "localhost", "127.0.0.1", "::1", "0.0.0.0", "::", "*":
+			keep = append(keep, ip)
+		}
+	}
+	if len(keep) == 0 {
+		return "localhost"
+	}
+	return strings.Join(keep, ",")
+}
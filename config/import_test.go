@@ -0,0 +1,38 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalize(t *testing.T) {
+	cfg := *MongoDBDefaults
+	cfg.Storage.DbPath = "/var/lib/mongo"
+	cfg.SystemLog.Destination = "syslog"
+	cfg.Net.BindIp = "127.0.0.1,10.1.2.3"
+	cfg.Security.Ldap.Servers = "ldap1.example.com,ldap2.example.com"
+	dir := filepath.Join("runtime", "cust", "mongod")
+	changes := Localize(&cfg, []string{"snmp.disabled"}, dir)
+
+	wantKeys := []string{"storage.dbPath", "systemLog.destination", "systemLog.path", "net.bindIp", "security.ldap.servers", "security.ldap.transportSecurity", "snmp.disabled"}
+	if len(changes) != len(wantKeys) {
+		t.Fatalf("Localize(): got changes %v, wanted keys %v", changes, wantKeys)
+	}
+	for i, c := range changes {
+		if c.Key != wantKeys[i] {
+			t.Errorf("Localize(): change %d: got key %s, wanted %s", i, c.Key, wantKeys[i])
+		}
+	}
+	if cfg.Storage.DbPath != filepath.Join(dir, "data") || cfg.SystemLog.Path != filepath.Join(dir, "mongod.log") {
+		t.Errorf("Localize(): paths not moved into %s: %v", dir, cfg)
+	}
+	if cfg.Net.BindIp != "127.0.0.1" {
+		t.Errorf("Localize(): got bindIp %s, wanted 127.0.0.1", cfg.Net.BindIp)
+	}
+	if cfg.Security.Ldap.Servers != LocalLDAPServer || cfg.Security.Ldap.TransportSecurity != "none" {
+		t.Errorf("Localize(): got LDAP servers %s with transport security %s", cfg.Security.Ldap.Servers, cfg.Security.Ldap.TransportSecurity)
+	}
+	if changes := Localize(&cfg, nil, dir); len(changes) != 0 {
+		t.Errorf("Localize(): second pass got unwanted changes %v", changes)
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+)
+
+// JSONSchemaVersion is the version of the JSON representation written by ToJSON
+// Bump it whenever a change to Type would make older files decode differently, and add a migration for the old version.
+const JSONSchemaVersion = 1
+
+// Migrations for older JSON files: jsonMigrations[v] rewrites a version v document, in place, into version v+1
+// Documents are the decoded "config" object, keyed by YAML names as written by ToJSON.
+var jsonMigrations = map[int]func(doc map[string]interface{}) error{}
+
+// The JSON side file: the schema version and every field of the config, keyed by YAML names
+type jsonFile struct {
+	SchemaVersion int                    `json:"schemaVersion"`
+	Config        map[string]interface{} `json:"config"`
+}
+
+// write out a config in versioned JSON
+// Every field is written, including defaults and Windows omissions, so the file is a complete record of the struct.
+func (c *Type) ToJSON() (*bytes.Buffer, error) {
+	cv := reflect.ValueOf(*c)
+	f := jsonFile{SchemaVersion: JSONSchemaVersion, Config: jsonStruct(&cv)}
+	out, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding config: %v", err)
+	}
+	buf := bytes.NewBuffer(out)
+	buf.WriteByte('\n')
+	return buf, nil
+}
+
+// Read a JSON config in, migrating it first if it was written by an older version
+func FromJSON(in []byte) (*Type, error) {
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+	var f jsonFile
+	err := dec.Decode(&f)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding config: %v", err)
+	}
+	if f.Config == nil {
+		return nil, fmt.Errorf("error decoding config: not a versioned config file")
+	}
+	if f.SchemaVersion > JSONSchemaVersion {
+		return nil, fmt.Errorf("error decoding config: schema version %d is newer than this program supports (%d)", f.SchemaVersion, JSONSchemaVersion)
+	}
+	for v := f.SchemaVersion; v < JSONSchemaVersion; v++ {
+		migrate, ok := jsonMigrations[v]
+		if !ok {
+			return nil, fmt.Errorf("error decoding config: no migration from schema version %d", v)
+		}
+		err = migrate(f.Config)
+		if err != nil {
+			return nil, fmt.Errorf("error migrating config from schema version %d: %v", v, err)
+		}
+	}
+	cfg := new(Type)
+	cv := reflect.ValueOf(cfg).Elem()
+	setDefaults(&cv)
+	var unknown []string
+	err = decodeStruct("", jsonToNode(f.Config), &cv, &unknown)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding config: %v", err)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("error decoding config: unknown settings %v", unknown)
+	}
+	return cfg, nil
+}
+
+// ReadSideFile reads the side file written next to config file fn: fn.json, or fn.gob as written by older versions
+func ReadSideFile(fn string) (*Type, error) {
+	in, err := ioutil.ReadFile(fn + ".json")
+	if err == nil {
+		return FromJSON(in)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	in, err = ioutil.ReadFile(fn + ".gob")
+	if err != nil {
+		return nil, err
+	}
+	return FromGoB(in)
+}
+
+// convert a struct to a map keyed by YAML names
+func jsonStruct(val *reflect.Value) map[string]interface{} {
+	m := make(map[string]interface{}, val.NumField())
+	for i := 0; i < val.NumField(); i++ {
+		sv := val.Field(i)
+		sf := val.Type().Field(i)
+		if sv.Kind() == reflect.Struct {
+			m[yamlName(&sf)] = jsonStruct(&sv)
+		} else {
+			m[yamlName(&sf)] = sv.Interface()
+		}
+	}
+	return m
+}
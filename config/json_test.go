@@ -0,0 +1,125 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestType_ToJSONRoundTrip(t *testing.T) {
+	c := OurDefaults.Copy()
+	c.Storage.DbPath = "/data/db"
+	c.Storage.Journal.Enabled = false
+	c.Net.Compression.Compressors = []string{"snappy", "zstd"}
+	c.OperationProfiling.SlowOpSampleRate = 0.25
+	c.SetParameter = Parameters{"enableTestCommands": true, "logComponentVerbosity": map[string]interface{}{"storage": 2}}
+
+	buf, err := c.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON(): %v", err)
+	}
+	if !strings.Contains(buf.String(), `"schemaVersion": 1`) {
+		t.Errorf("ToJSON(): no schema version in %s", buf)
+	}
+	got, err := FromJSON(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromJSON(): %v", err)
+	}
+	if diffs := Diff(c, got); len(diffs) != 0 {
+		t.Errorf("FromJSON(ToJSON()): differences %v", diffs)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		check   func(c *Type) bool
+		wantErr bool
+	}{
+		{"defaults kept", `{"schemaVersion": 1, "config": {"net": {"port": 27019}}}`, func(c *Type) bool {
+			return c.Net.Port == 27019 && c.Storage.Journal.Enabled && c.Security.JavascriptEnabled
+		}, false},
+		{"newer version", `{"schemaVersion": 99, "config": {}}`, nil, true},
+		{"unversioned", `{"net": {"port": 27019}}`, nil, true},
+		{"no migration", `{"schemaVersion": 0, "config": {}}`, nil, true},
+		{"unknown setting", `{"schemaVersion": 1, "config": {"net": {"portt": 1}}}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromJSON([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !tt.check(got) {
+				t.Errorf("FromJSON(): got %v", got)
+			}
+		})
+	}
+}
+
+func TestFromJSON_Migration(t *testing.T) {
+	// a hypothetical version 0 that called the port "portNumber"
+	jsonMigrations[0] = func(doc map[string]interface{}) error {
+		net := doc["net"].(map[string]interface{})
+		net["port"] = net["portNumber"]
+		delete(net, "portNumber")
+		return nil
+	}
+	defer delete(jsonMigrations, 0)
+
+	got, err := FromJSON([]byte(`{"schemaVersion": 0, "config": {"net": {"portNumber": 27020}}}`))
+	if err != nil {
+		t.Fatalf("FromJSON(): %v", err)
+	}
+	if got.Net.Port != 27020 {
+		t.Errorf("FromJSON(): port got %d, wanted 27020", got.Net.Port)
+	}
+}
+
+func TestReadSideFile(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "sa.yaml")
+
+	// a GoB file written by an older version, with SetParameter a struct
+	in, err := ioutil.ReadFile(filepath.Join("testdata", "baseline.yaml.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(fn+".gob", in, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSideFile(fn)
+	if err != nil {
+		t.Fatalf("ReadSideFile(gob): %v", err)
+	}
+	want := OurDefaults.Copy()
+	want.Storage.DbPath = "/home/user/mongodb-runtime/sa/sa/data"
+	want.SystemLog.Path = "/home/user/mongodb-runtime/sa/sa/mongod.log"
+	want.Net.Port = 27021
+	want.SetParameter = Parameters{"authenticationMechanisms": "SCRAM-SHA-256,MONGODB-X509"}
+	if diffs := Diff(want, got); len(diffs) != 0 {
+		t.Errorf("ReadSideFile(gob): differences %v", diffs)
+	}
+
+	// the JSON file takes precedence
+	c := OurDefaults.Copy()
+	c.Net.Port = 27022
+	buf, err := c.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON(): %v", err)
+	}
+	err = ioutil.WriteFile(fn+".json", buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = ReadSideFile(fn)
+	if err != nil {
+		t.Fatalf("ReadSideFile(json): %v", err)
+	}
+	if got.Net.Port != 27022 {
+		t.Errorf("ReadSideFile(json): port got %d, wanted 27022", got.Net.Port)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// A Layer is a partial config, such as a profile or a per-member override, applied on top of a full config
+// Only the settings present in the layer are changed, so later layers take precedence field by field. Maps such as
+// setParameter are merged by key; lists are replaced.
+type Layer struct {
+	Name string
+	root *yamlNode
+}
+
+// ParseLayer reads a layer from YAML in the same format as a mongod config file
+// String values may refer to variables as ${name}; they are expanded when the layer is applied.
+func ParseLayer(name string, in []byte) (*Layer, error) {
+	root, err := parseYaml(in)
+	if err != nil {
+		return nil, fmt.Errorf("layer %s: %v", name, err)
+	}
+	if root.kind != yamlMap {
+		return nil, fmt.Errorf("layer %s: must be a mapping", name)
+	}
+	return &Layer{Name: name, root: root}, nil
+}
+
+// Apply a layer to a config, expanding ${name} references from vars
+// Keys that do not correspond to a field of Type are returned, as for FromYaml.
+func (c *Type) Apply(l *Layer, vars map[string]string) ([]string, error) {
+	cv := reflect.ValueOf(c).Elem()
+	var unknown []string
+	err := decodeStruct("", expandNode(l.root, vars), &cv, &unknown)
+	if err != nil {
+		return nil, fmt.Errorf("layer %s: %v", l.Name, err)
+	}
+	return unknown, nil
+}
+
+// Build a config from a copy of base with each layer applied in order
+func Build(base *Type, vars map[string]string, layers ...*Layer) (*Type, error) {
+	c := base.Copy()
+	for _, l := range layers {
+		unknown, err := c.Apply(l, vars)
+		if err != nil {
+			return nil, err
+		}
+		if len(unknown) > 0 {
+			return nil, fmt.Errorf("layer %s: unknown settings %v", l.Name, unknown)
+		}
+	}
+	return c, nil
+}
+
+var variableRegex = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// copy a node tree, replacing ${name} in scalars with vars[name]; unknown names are left alone
+func expandNode(n *yamlNode, vars map[string]string) *yamlNode {
+	x := *n
+	switch n.kind {
+	case yamlScalar:
+		x.value = variableRegex.ReplaceAllStringFunc(n.value, func(ref string) string {
+			if v, ok := vars[ref[2:len(ref)-1]]; ok {
+				return v
+			}
+			return ref
+		})
+	case yamlMap:
+		x.pairs = make([]yamlPair, len(n.pairs))
+		for i, pair := range n.pairs {
+			x.pairs[i] = yamlPair{key: pair.key, value: expandNode(pair.value, vars)}
+		}
+	case yamlSeq:
+		x.items = make([]*yamlNode, len(n.items))
+		for i, item := range n.items {
+			x.items[i] = expandNode(item, vars)
+		}
+	}
+	return &x
+}
+
+// A Spec describes a deployment: the profiles every member starts from, and each member's own overrides
+type Spec struct {
+	Profiles []string
+	Members  []MemberSpec
+}
+
+type MemberSpec struct {
+	Name     string
+	Override *Layer // nil if the member has no overrides
+}
+
+// ParseSpec reads a deployment spec from YAML, e.g.
+//
+//	profiles: [prod-like, tls-everywhere]
+//	members:
+//	  rs0-0:
+//	    replication: {replSetName: rs0}
+//	  rs0-1:
+//	    replication: {replSetName: rs0}
+//
+// Members with the same replSetName form a replica set, initiated when the deployment is first run.
+// A spec may also have the steps of a scenario to run on the deployment once it is running.
+func ParseSpec(in []byte) (*Spec, error) {
+	root, err := parseYaml(in)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing spec: %v", err)
+	}
+	if root.kind != yamlMap {
+		return nil, fmt.Errorf("spec must be a mapping")
+	}
+	spec := new(Spec)
+	for _, pair := range root.pairs {
+		switch pair.key {
+		case "profiles":
+			items := pair.value.items
+			if pair.value.kind == yamlScalar && !pair.value.isNull() {
+				items = []*yamlNode{pair.value}
+			} else if pair.value.kind != yamlSeq {
+				return nil, pair.value.errorf("profiles must be a list")
+			}
+			for _, item := range items {
+				if item.kind != yamlScalar {
+					return nil, item.errorf("profile names must be strings")
+				}
+				spec.Profiles = append(spec.Profiles, item.value)
+			}
+		case "members":
+			if pair.value.kind != yamlMap {
+				return nil, pair.value.errorf("members must be a mapping of member names to overrides")
+			}
+			for _, member := range pair.value.pairs {
+				ms := MemberSpec{Name: member.key}
+				if !member.value.isNull() {
+					if member.value.kind != yamlMap {
+						return nil, member.value.errorf("overrides for member %s must be a mapping", member.key)
+					}
+					ms.Override = &Layer{Name: "member " + member.key, root: member.value}
+				}
+				spec.Members = append(spec.Members, ms)
+			}
+		case "steps":
+			// the scenario to reproduce on the deployment, read by the scenario package
+		default:
+			return nil, pair.value.errorf("unknown key '%s' in spec", pair.key)
+		}
+	}
+	return spec, nil
+}
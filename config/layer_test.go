@@ -0,0 +1,86 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	base := OurDefaults.Copy()
+	base.SetParameter = Parameters{"enableTestCommands": 1}
+	p1, err := ParseLayer("p1", []byte(`
+storage:
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: 1
+security:
+  javascriptEnabled: true
+net:
+  compression:
+    compressors: snappy,zlib
+setParameter:
+  diagnosticDataCollectionEnabled: false
+`))
+	if err != nil {
+		t.Fatalf("ParseLayer(): got unwanted error %v", err)
+	}
+	p2, err := ParseLayer("p2", []byte(`
+storage:
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: 2
+net:
+  compression:
+    compressors: zstd
+  tls:
+    certificateKeyFile: ${memberPath}/server.pem
+setParameter:
+  diagnosticDataCollectionEnabled: true
+`))
+	if err != nil {
+		t.Fatalf("ParseLayer(): got unwanted error %v", err)
+	}
+	got, err := Build(base, map[string]string{"memberPath": "/rt/d/m"}, p1, p2)
+	if err != nil {
+		t.Fatalf("Build(): got unwanted error %v", err)
+	}
+	want := base.Copy()
+	want.Storage.WiredTiger.EngineConfig.CacheSizeGB = 2
+	want.Security.JavascriptEnabled = true
+	want.Net.Compression.Compressors = []string{"zstd"}
+	want.Net.Tls.CertificateKeyFile = "/rt/d/m/server.pem"
+	want.SetParameter = Parameters{"enableTestCommands": 1, "diagnosticDataCollectionEnabled": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Build(): got %v, wanted %v", got, want)
+	}
+	if len(base.SetParameter) != 1 {
+		t.Errorf("Build(): base was modified: %v", base.SetParameter)
+	}
+
+	bad, _ := ParseLayer("bad", []byte("net:\n  bogus: 1\n"))
+	if _, err = Build(base, nil, bad); err == nil {
+		t.Errorf("Build(): wanted error for unknown setting, got none")
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec([]byte(`
+profiles: [minimal, tls-everywhere]
+members:
+  rs0-0:
+    replication: {replSetName: rs0}
+  rs0-1:
+`))
+	if err != nil {
+		t.Fatalf("ParseSpec(): got unwanted error %v", err)
+	}
+	if !reflect.DeepEqual(spec.Profiles, []string{"minimal", "tls-everywhere"}) {
+		t.Errorf("ParseSpec(): got profiles %v", spec.Profiles)
+	}
+	if len(spec.Members) != 2 || spec.Members[0].Name != "rs0-0" || spec.Members[0].Override == nil || spec.Members[1].Override != nil {
+		t.Errorf("ParseSpec(): got members %v", spec.Members)
+	}
+	if _, err = ParseSpec([]byte("profile: x\n")); err == nil {
+		t.Errorf("ParseSpec(): wanted error for unknown key, got none")
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"strings"
+)
+
+// LDAPEnabled reports whether the member authenticates or authorizes users against LDAP servers
+func (c *Type) LDAPEnabled() bool {
+	return c.Security.Ldap.Servers != ""
+}
+
+// PlainAuth reports whether clients can authenticate with SASL PLAIN, which passes LDAP users' passwords through
+func (c *Type) PlainAuth() bool {
+	for _, m := range c.AuthMechanisms() {
+		if m == "PLAIN" {
+			return true
+		}
+	}
+	return false
+}
+
+// LDAPMechanisms are the member's authentication mechanisms with PLAIN added for LDAP users, for release r
+// A member that doesn't set any starts from the release's default, SCRAM-SHA-1 and from 4.0 SCRAM-SHA-256.
+func (c *Type) LDAPMechanisms(r version.ReleaseType) []string {
+	mechanisms := c.AuthMechanisms()
+	if len(mechanisms) == 0 {
+		mechanisms = []string{"SCRAM-SHA-1"}
+		if r.AtLeast(4, 0) {
+			mechanisms = append(mechanisms, "SCRAM-SHA-256")
+		}
+	}
+	if !c.PlainAuth() {
+		mechanisms = append(mechanisms, "PLAIN")
+	}
+	return mechanisms
+}
+
+// problems with the settings LDAP authentication and authorization rely on
+func (c *Type) ldapProblems() []string {
+	var problems []string
+	ldap := c.Security.Ldap
+	if !c.LDAPEnabled() {
+		if ldap.Authz.QueryTemplate != "" || ldap.UserToDNMapping != "" || ldap.Bind.QueryUser != "" {
+			problems = append(problems, "security.ldap: settings have no effect without security.ldap.servers")
+		}
+		return problems
+	}
+	if ldap.Authz.QueryTemplate != "" && !strings.Contains(ldap.Authz.QueryTemplate, "{USER}") &&
+		!strings.Contains(ldap.Authz.QueryTemplate, "{PROVIDED_USER}") {
+		problems = append(problems, "security.ldap.authz.queryTemplate: must contain {USER} or {PROVIDED_USER}")
+	}
+	if ldap.Authz.QueryTemplate == "" && !c.PlainAuth() {
+		problems = append(problems, "security.ldap: neither authz.queryTemplate nor PLAIN in setParameter.authenticationMechanisms uses the LDAP servers")
+	}
+	return problems
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"reflect"
+	"testing"
+)
+
+func TestType_LDAPMechanisms(t *testing.T) {
+	tests := []struct {
+		name    string
+		release string
+		params  Parameters
+		want    []string
+	}{
+		{"3.6 default", "3.6.23", nil, []string{"SCRAM-SHA-1", "PLAIN"}},
+		{"4.0 default", "4.0.28", nil, []string{"SCRAM-SHA-1", "SCRAM-SHA-256", "PLAIN"}},
+		{"set", "4.4.10", Parameters{"authenticationMechanisms": "SCRAM-SHA-256,MONGODB-X509"}, []string{"SCRAM-SHA-256", "MONGODB-X509", "PLAIN"}},
+		{"plain already", "3.6.23", Parameters{"authenticationMechanisms": "PLAIN,SCRAM-SHA-1"}, []string{"PLAIN", "SCRAM-SHA-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := version.ToRelease(tt.release)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := MongoDBDefaults.Copy()
+			c.SetParameter = tt.params
+			if got := c.LDAPMechanisms(r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LDAPMechanisms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,40 +2,218 @@ package config
 
 import (
 	"bytes"
-	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-// write out a config in GoB (Go Binary)
-func (c *Type) ToGoB() (*bytes.Buffer, error) {
+// write out a config in YAML
+func (c *Type) ToYaml(isWindows bool) *bytes.Buffer {
+	cv := reflect.ValueOf(*c)
 	buf := new(bytes.Buffer)
-	enc := gob.NewEncoder(buf)
-	err := enc.Encode(c)
+	printStruct("", &cv, buf, isWindows)
+	return buf
+}
+
+// Read a YAML config in, such as a mongod.conf file
+// Settings not present in the YAML keep their defaults. Keys that do not correspond to a field of Type are returned
+// as dotted paths (e.g. "net.tls.mode") rather than being silently dropped.
+func FromYaml(in []byte) (*Type, []string, error) {
+	root, err := parseYaml(in)
 	if err != nil {
-		return nil, fmt.Errorf("error encoding config: %v", err)
+		return nil, nil, fmt.Errorf("error parsing YAML: %v", err)
 	}
-	return buf, nil
+	cfg := new(Type)
+	cv := reflect.ValueOf(cfg).Elem()
+	setDefaults(&cv)
+	var unknown []string
+	err = decodeStruct("", root, &cv, &unknown)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, unknown, nil
 }
 
-// Read a GoB config in
-func FromGoB(in []byte) (*Type, error) {
-	buf := bytes.NewBuffer(in)
-	dec := gob.NewDecoder(buf)
-	cfg := new(Type)
-	err := dec.Decode(cfg)
+// YAML key for a struct field: the "yaml" tag if there is one, otherwise the field name with its first letter lowercased
+func yamlName(field *reflect.StructField) string {
+	if name, ok := field.Tag.Lookup("yaml"); ok {
+		return name
+	}
+	sn := field.Name
+	return string(sn[0]+('a'-'A')) + sn[1:] // lowercase first letter
+}
+
+// set every field tagged with a default to that default
+func setDefaults(val *reflect.Value) {
+	tval := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		sv := val.Field(i)
+		sf := tval.Field(i)
+		if sf.Type.Kind() == reflect.Struct {
+			setDefaults(&sv)
+			continue
+		}
+		def, ok := sf.Tag.Lookup("default")
+		if ok {
+			_ = decodeValue("", &yamlNode{kind: yamlScalar, value: def}, &sv, sf.Tag, nil)
+		}
+	}
+}
+
+// decode a YAML mapping into a struct, recording keys with no matching field in unknown
+func decodeStruct(path string, node *yamlNode, val *reflect.Value, unknown *[]string) error {
+	if node.isNull() {
+		return nil
+	}
+	if node.kind != yamlMap {
+		return node.errorf("'%s' must be a mapping", path)
+	}
+	tval := val.Type()
+	for _, pair := range node.pairs {
+		key := pair.key
+		if path != "" {
+			key = path + "." + pair.key
+		}
+		found := false
+		for i := 0; i < val.NumField(); i++ {
+			sf := tval.Field(i)
+			if yamlName(&sf) != pair.key {
+				continue
+			}
+			found = true
+			sv := val.Field(i)
+			err := decodeValue(key, pair.value, &sv, sf.Tag, unknown)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		if !found {
+			*unknown = append(*unknown, key)
+		}
+	}
+	return nil
+}
+
+// decode a YAML node into a value of any kind supported in the config struct
+// tag is the struct tag of the field being decoded, if any
+func decodeValue(key string, node *yamlNode, val *reflect.Value, tag reflect.StructTag, unknown *[]string) error {
+	switch val.Kind() {
+	case reflect.Struct:
+		return decodeStruct(key, node, val, unknown)
+	case reflect.Map:
+		if node.isNull() {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+		if node.kind != yamlMap {
+			return node.errorf("'%s' must be a mapping", key)
+		}
+		if val.IsNil() {
+			val.Set(reflect.MakeMap(val.Type()))
+		}
+		if val.Type() == parametersType {
+			for _, pair := range node.pairs {
+				elem := reflect.New(val.Type().Elem()).Elem()
+				if v := parameterValue(pair.value); v != nil {
+					elem.Set(reflect.ValueOf(v))
+				}
+				val.SetMapIndex(reflect.ValueOf(pair.key), elem)
+			}
+			return nil
+		}
+		for _, pair := range node.pairs {
+			elem := reflect.New(val.Type().Elem()).Elem()
+			err := decodeValue(key+"."+pair.key, pair.value, &elem, "", unknown)
+			if err != nil {
+				return err
+			}
+			val.SetMapIndex(reflect.ValueOf(pair.key).Convert(val.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		if node.isNull() {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+		items := node.items
+		if node.kind == yamlScalar {
+			// a single value, or a list joined with the separator given in the "join" tag
+			items = []*yamlNode{node}
+			if sep, ok := tag.Lookup("join"); ok {
+				items = nil
+				for _, item := range strings.Split(node.value, sep) {
+					items = append(items, &yamlNode{kind: yamlScalar, value: strings.TrimSpace(item), quoted: true, line: node.line})
+				}
+			}
+		} else if node.kind != yamlSeq {
+			return node.errorf("'%s' must be a list", key)
+		}
+		s := reflect.MakeSlice(val.Type(), len(items), len(items))
+		for i, item := range items {
+			elem := s.Index(i)
+			err := decodeValue(key, item, &elem, "", unknown)
+			if err != nil {
+				return err
+			}
+		}
+		val.Set(s)
+		return nil
+	}
+	if node.kind != yamlScalar {
+		return node.errorf("'%s' must be a scalar value", key)
+	}
+	if node.isNull() {
+		val.Set(reflect.Zero(val.Type()))
+		return nil
+	}
+	err := setScalar(val, node.value)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding config: %v", err)
+		return node.errorf("'%s': %v", key, err)
 	}
-	return cfg, nil
+	return nil
 }
 
-// write out a config in YAML
-func (c *Type) ToYaml(isWindows bool) *bytes.Buffer {
-	cv := reflect.ValueOf(*c)
-	buf := new(bytes.Buffer)
-	printStruct("", &cv, buf, isWindows)
-	return buf
+// set a scalar struct field from its YAML string representation
+func setScalar(val *reflect.Value, s string) error {
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(s)
+	case reflect.Bool:
+		switch s {
+		case "true", "True", "TRUE", "yes", "Yes", "YES", "on", "On", "ON":
+			val.SetBool(true)
+		case "false", "False", "FALSE", "no", "No", "NO", "off", "Off", "OFF":
+			val.SetBool(false)
+		default:
+			return fmt.Errorf("'%s' is not a boolean", s)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, val.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("'%s' is not an unsigned integer", s)
+		}
+		val.SetUint(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, val.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("'%s' is not an integer", s)
+		}
+		val.SetInt(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, val.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", s)
+		}
+		val.SetFloat(v)
+	default:
+		panic("setScalar: Unknown type in config struct")
+	}
+	return nil
 }
 
 // write out a struct in YAML
@@ -45,37 +223,166 @@ func printStruct(leader string, val *reflect.Value, buf *bytes.Buffer, isWindows
 	for i := 0; i < nval; i++ {
 		sv := val.Field(i)
 		sf := tval.Field(i)
-		sn := sf.Name
 		// If field is tagged omitwindows and we are on Windows, don't write it at all
 		_, ok := sf.Tag.Lookup("omitwindows")
 		if ok && isWindows {
 			continue
 		}
-		snl := string(sn[0]+('a'-'A')) + sn[1:] // lowercase first letter
+		snl := yamlName(&sf)
 		switch sf.Type.Kind() {
 		case reflect.Struct:
 			if checkStruct(&sv, isWindows) {
 				_, _ = fmt.Fprintf(buf, "%s%s:\n", leader, snl)
 				printStruct(leader+"  ", &sv, buf, isWindows)
 			}
-		case reflect.Bool:
-			if !isDefault(&sv, &sf) {
-				_, _ = fmt.Fprintf(buf, "%s%s: %t\n", leader, snl, sv.Bool())
-			}
-		case reflect.String:
+		case reflect.Slice:
 			if !isDefault(&sv, &sf) {
-				_, _ = fmt.Fprintf(buf, "%s%s: %s\n", leader, snl, sv.String())
+				if sep, ok := sf.Tag.Lookup("join"); ok {
+					items := make([]string, sv.Len())
+					for j := range items {
+						items[j] = fmt.Sprint(sv.Index(j).Interface())
+					}
+					_, _ = fmt.Fprintf(buf, "%s%s: %s\n", leader, snl, yamlString(strings.Join(items, sep)))
+				} else {
+					_, _ = fmt.Fprintf(buf, "%s%s:\n", leader, snl)
+					printSlice(leader+"  ", &sv, buf)
+				}
 			}
-		case reflect.Uint:
+		case reflect.Map:
 			if !isDefault(&sv, &sf) {
-				_, _ = fmt.Fprintf(buf, "%s%s: %d\n", leader, snl, sv.Uint())
+				_, _ = fmt.Fprintf(buf, "%s%s:\n", leader, snl)
+				if params, ok := sv.Interface().(Parameters); ok {
+					printParameters(leader+"  ", params, buf)
+				} else {
+					printMap(leader+"  ", &sv, buf)
+				}
 			}
-		case reflect.Float32:
+		default:
 			if !isDefault(&sv, &sf) {
-				_, _ = fmt.Fprintf(buf, "%s%s: %.2f\n", leader, snl, sv.Float())
+				_, _ = fmt.Fprintf(buf, "%s%s: %s\n", leader, snl, scalarString(&sv))
 			}
+		}
+	}
+}
+
+// write out a map in YAML, in key order
+func printMap(leader string, val *reflect.Value, buf *bytes.Buffer) {
+	keys := val.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, k := range keys {
+		name := yamlString(k.String())
+		v := val.MapIndex(k)
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			_, _ = fmt.Fprintf(buf, "%s%s:\n", leader, name)
+			printMap(leader+"  ", &v, buf)
+		case reflect.Slice:
+			_, _ = fmt.Fprintf(buf, "%s%s:\n", leader, name)
+			printSlice(leader+"  ", &v, buf)
 		default:
-			panic("Unknown type in config struct")
+			_, _ = fmt.Fprintf(buf, "%s%s: %s\n", leader, name, scalarString(&v))
+		}
+	}
+}
+
+// write out a slice of scalars as a YAML block sequence
+func printSlice(leader string, val *reflect.Value, buf *bytes.Buffer) {
+	for i := 0; i < val.Len(); i++ {
+		v := val.Index(i)
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		_, _ = fmt.Fprintf(buf, "%s- %s\n", leader, scalarString(&v))
+	}
+}
+
+// YAML representation of a scalar value
+func scalarString(val *reflect.Value) string {
+	switch val.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("%t", val.Bool())
+	case reflect.String:
+		return yamlString(val.String())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", val.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", val.Int())
+	case reflect.Float32:
+		return fmt.Sprintf("%.2f", val.Float())
+	case reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'f', -1, 64)
+	default:
+		panic("Unknown type in config struct")
+	}
+}
+
+// A setting is one non-default leaf of a config, e.g. {"net.port", "27018"}
+type setting struct {
+	key   string
+	value string // unquoted; lists are joined with commas and documents written as JSON
+}
+
+// list the settings that differ from their defaults, in struct order
+// If isWindows is true, settings tagged omitwindows are left out.
+func (c *Type) settings(isWindows bool) []setting {
+	cv := reflect.ValueOf(*c)
+	var out []setting
+	flattenStruct("", &cv, &out, isWindows)
+	return out
+}
+
+func flattenStruct(prefix string, val *reflect.Value, out *[]setting, isWindows bool) {
+	tval := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		sv := val.Field(i)
+		sf := tval.Field(i)
+		_, ok := sf.Tag.Lookup("omitwindows")
+		if ok && isWindows {
+			continue
+		}
+		flattenValue(prefix+yamlName(&sf), &sv, &sf, out, isWindows)
+	}
+}
+
+// add the settings of one struct field, if it is not the default
+func flattenValue(key string, sv *reflect.Value, sf *reflect.StructField, out *[]setting, isWindows bool) {
+	switch sf.Type.Kind() {
+	case reflect.Struct:
+		flattenStruct(key+".", sv, out, isWindows)
+	case reflect.Slice:
+		if !isDefault(sv, sf) {
+			sep, ok := sf.Tag.Lookup("join")
+			if !ok {
+				sep = ","
+			}
+			items := make([]string, sv.Len())
+			for j := range items {
+				items[j] = fmt.Sprint(sv.Index(j).Interface())
+			}
+			*out = append(*out, setting{key, strings.Join(items, sep)})
+		}
+	case reflect.Map:
+		keys := sv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			var value string
+			if params, ok := sv.Interface().(Parameters); ok {
+				value = parameterRaw(params[k.String()])
+			} else {
+				value = fmt.Sprint(sv.MapIndex(k).Interface())
+			}
+			*out = append(*out, setting{key + "." + k.String(), value})
+		}
+	case reflect.String:
+		if !isDefault(sv, sf) {
+			*out = append(*out, setting{key, sv.String()})
+		}
+	default:
+		if !isDefault(sv, sf) {
+			*out = append(*out, setting{key, scalarString(sv)})
 		}
 	}
 }
@@ -91,15 +398,12 @@ func checkStruct(val *reflect.Value, isWindows bool) bool {
 		sv := val.Field(i)
 		_, ok := sf.Tag.Lookup("omitwindows")
 		if ok && isWindows {
-			return false
+			continue
 		}
-		switch sf.Type.Kind() {
-		case reflect.Struct:
+		if sf.Type.Kind() == reflect.Struct {
 			ret = checkStruct(&sv, isWindows)
-		case reflect.Bool, reflect.String, reflect.Uint, reflect.Float32:
+		} else {
 			ret = !isDefault(&sv, &sf)
-		default:
-			panic("Unknown type in config struct")
 		}
 		if ret {
 			break
@@ -110,38 +414,93 @@ func checkStruct(val *reflect.Value, isWindows bool) bool {
 
 // Check if a struct field is the default value or not, return true if it is
 // the default value is the zero value unless there is a tag on the struct field specifying a different default
+// empty slices and maps are always default
 func isDefault(val *reflect.Value, field *reflect.StructField) (ret bool) {
+	switch field.Type.Kind() {
+	case reflect.Slice, reflect.Map:
+		return val.Len() == 0
+	}
 	def, ok := field.Tag.Lookup("default")
 	if ok {
-		switch field.Type.Kind() {
-		case reflect.String:
-			ret = def == val.String()
-		case reflect.Bool:
-			var v bool
-			_, _ = fmt.Sscanf(def, "%t", &v)
-			ret = v == val.Bool()
-		case reflect.Uint:
-			var v uint64
-			_, _ = fmt.Sscanf(def, "%d", &v)
-			ret = v == val.Uint()
-		case reflect.Float32:
-			var v float64
-			_, _ = fmt.Sscanf(def, "%f", &v)
-			ret = v == val.Float()
-		}
+		v := reflect.New(field.Type).Elem()
+		_ = setScalar(&v, def)
+		ret = v.Interface() == val.Interface()
 	} else { // no default specified, compare check against zero value
-		switch field.Type.Kind() {
-		case reflect.Bool:
-			ret = val.Bool() == false
-		case reflect.String:
-			ret = val.String() == ""
-		case reflect.Uint:
-			ret = val.Uint() == 0
-		case reflect.Float32:
-			ret = val.Float() == 0.0
-		default:
-			panic("isDefault: Unknown type in config struct")
-		}
+		ret = val.IsZero()
 	}
 	return ret
 }
+
+// Read the output of db.adminCommand({getCmdLineOpts: 1}) in, as printed by the mongo shell
+// Either the whole reply or just its "parsed" document is accepted. As with FromYaml, settings that do not
+// correspond to a field of Type are returned as dotted paths.
+func FromCmdLineOpts(in []byte) (*Type, []string, error) {
+	// the legacy shell prints 64-bit and 32-bit integers as NumberLong(n) and NumberInt(n)
+	in = shellNumberRegex.ReplaceAll(in, []byte("$1"))
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing getCmdLineOpts output: %v", err)
+	}
+	if parsed, ok := doc["parsed"].(map[string]interface{}); ok {
+		doc = parsed
+	}
+	delete(doc, "config") // the path of the config file the options were read from, not a setting
+	cfg := new(Type)
+	cv := reflect.ValueOf(cfg).Elem()
+	setDefaults(&cv)
+	var unknown []string
+	err = decodeStruct("", jsonToNode(doc), &cv, &unknown)
+	if err != nil {
+		return nil, nil, err
+	}
+	// mongod reports every setParameter value as a string, so type them as if they were unquoted YAML
+	for name, v := range cfg.SetParameter {
+		if s, ok := v.(string); ok {
+			cfg.SetParameter[name] = parameterValue(&yamlNode{kind: yamlScalar, value: s})
+		}
+	}
+	return cfg, unknown, nil
+}
+
+var shellNumberRegex = regexp.MustCompile(`Number(?:Long|Int)\(\s*"?(-?\d+)"?\s*\)`)
+
+// convert a decoded JSON value into the same tree the YAML parser produces
+// Object keys are sorted, since Go maps don't preserve the document order.
+func jsonToNode(v interface{}) *yamlNode {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		// Extended JSON number wrappers such as {"$numberLong": "5"}
+		if len(x) == 1 {
+			for k, w := range x {
+				if k == "$numberLong" || k == "$numberInt" || k == "$numberDouble" || k == "$numberDecimal" {
+					return jsonToNode(w)
+				}
+			}
+		}
+		node := &yamlNode{kind: yamlMap}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			node.pairs = append(node.pairs, yamlPair{key: k, value: jsonToNode(x[k])})
+		}
+		return node
+	case []interface{}:
+		node := &yamlNode{kind: yamlSeq}
+		for _, item := range x {
+			node.items = append(node.items, jsonToNode(item))
+		}
+		return node
+	case nil:
+		return &yamlNode{kind: yamlScalar}
+	case string:
+		return &yamlNode{kind: yamlScalar, value: x, quoted: true}
+	default: // bool, json.Number
+		return &yamlNode{kind: yamlScalar, value: fmt.Sprint(x)}
+	}
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestType_FromYamlRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "empty",
+			yaml: "",
+		},
+		{
+			name: "ourDefaults",
+			yaml: `storage:
+  engine: wiredTiger
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: 0.50
+systemLog:
+  destination: file
+security:
+  authorization: enabled
+net:
+  bindIp: 0.0.0.0
+`,
+		},
+		{
+			name: "allFields",
+			yaml: `storage:
+  dbPath: /var/lib/mongo
+  engine: inMemory
+systemLog:
+  destination: file
+  path: /var/log/mongodb/mongod.log
+  timeStampFormat: iso8601-utc
+  logAppend: true
+  verbosity: 2
+security:
+  authorization: enabled
+  javascriptEnabled: false
+net:
+  port: 27018
+  bindIp: localhost,192.168.1.10
+  ipv6: true
+  unixDomainSocket:
+    enabled: false
+processManagement:
+  fork: true
+setParameter:
+  authenticationMechanisms: SCRAM-SHA-256,MONGODB-X509
+`,
+		},
+		{
+			name: "broad",
+			yaml: `storage:
+  dbPath: /data/rs0-0
+  directoryPerDB: true
+  journal:
+    enabled: false
+  wiredTiger:
+    collectionConfig:
+      blockCompressor: zstd
+    indexConfig:
+      prefixCompression: false
+security:
+  keyFile: /data/keyfile
+  clusterAuthMode: sendX509
+net:
+  tls:
+    mode: requireTLS
+    certificateKeyFile: /data/server.pem
+    CAFile: /data/ca.pem
+    allowConnectionsWithoutCertificates: true
+    disabledProtocols: TLS1_0,TLS1_1
+  compression:
+    compressors: zstd,snappy
+processManagement:
+  pidFilePath: /data/rs0-0/mongod.pid
+replication:
+  replSetName: rs0
+  oplogSizeMB: 1024
+sharding:
+  clusterRole: shardsvr
+operationProfiling:
+  mode: slowOp
+  slowOpThresholdMs: 50
+  slowOpSampleRate: 0.25
+auditLog:
+  destination: file
+  format: JSON
+  path: /data/rs0-0/audit.json
+  filter: '{ atype: { $in: [ "authenticate", "createUser" ] } }'
+`,
+		},
+		{
+			name: "setParameter",
+			yaml: `setParameter:
+  authenticationMechanisms: SCRAM-SHA-256
+  diagnosticDataCollectionEnabled: false
+  enableTestCommands: true
+  failpoint.rsSyncApplyStop: '{"mode":"alwaysOn"}'
+  ldapUserCacheInvalidationInterval: 30
+  logComponentVerbosity: '{"replication":{"verbosity":2},"verbosity":1}'
+  sampleRate: 0.5
+  someString: '42'
+  wiredTigerConcurrentReadTransactions: 64
+`,
+		},
+		{
+			name: "quoted",
+			yaml: `storage:
+  dbPath: 'C:\data\db #1'
+systemLog:
+  path: 'it''s: here'
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, unknown, err := FromYaml([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("FromYaml(): got unwanted error %v", err)
+			}
+			if len(unknown) != 0 {
+				t.Errorf("FromYaml(): got unwanted unknown keys %v", unknown)
+			}
+			got := cfg.ToYaml(false).String()
+			if got != tt.yaml {
+				t.Errorf("ToYaml(FromYaml()): got\n%s\nwanted\n%s", got, tt.yaml)
+			}
+		})
+	}
+}
+
+func TestType_FromYaml(t *testing.T) {
+	tests := []struct {
+		name        string
+		yaml        string
+		want        func(c *Type)
+		wantUnknown []string
+		wantErr     bool
+	}{
+		{
+			name: "customer",
+			yaml: `# mongod.conf
+
+# for documentation of all options, see:
+#   http://docs.mongodb.org/manual/reference/configuration-options/
+
+# Where and how to store data.
+storage:
+  dbPath: "/data/db"   # data lives here
+  journal:
+    enabled: true
+
+# where to write logging data.
+systemLog:
+    destination: file
+    logAppend: true
+    path: /var/log/mongodb/mongod.log
+
+# network interfaces
+net:
+    port: 27017
+    bindIp: 127.0.0.1  # Enter 0.0.0.0,:: to bind to all IPv4 and IPv6 addresses
+
+security: { authorization: enabled }
+
+processManagement:
+   timeZoneInfo: /usr/share/zoneinfo
+
+setParameter:
+   enableLocalhostAuthBypass: false
+`,
+			want: func(c *Type) {
+				c.Storage.DbPath = "/data/db"
+				c.SystemLog.Destination = "file"
+				c.SystemLog.LogAppend = true
+				c.SystemLog.Path = "/var/log/mongodb/mongod.log"
+				c.Net.Port = 27017
+				c.Net.BindIp = "127.0.0.1"
+				c.Security.Authorization = "enabled"
+				c.SetParameter = Parameters{"enableLocalhostAuthBypass": false}
+			},
+			wantUnknown: []string{"processManagement.timeZoneInfo"},
+		},
+		{
+			name: "defaults",
+			yaml: "net:\n  port: 28000\n",
+			want: func(c *Type) {
+				c.Net.Port = 28000
+			},
+		},
+		{
+			name:    "badBool",
+			yaml:    "processManagement:\n  fork: maybe\n",
+			wantErr: true,
+		},
+		{
+			name:    "badUint",
+			yaml:    "net:\n  port: -1\n",
+			wantErr: true,
+		},
+		{
+			name:    "notMapping",
+			yaml:    "net: 27017\n",
+			wantErr: true,
+		},
+		{
+			name:    "badIndent",
+			yaml:    "net:\n    port: 1\n  bindIp: x\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			yaml:    "net:\n  port: 1\n  port: 2\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated",
+			yaml:    "storage:\n  dbPath: '/data\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unknown, err := FromYaml([]byte(tt.yaml))
+			if err == nil {
+				if tt.wantErr {
+					t.Errorf("FromYaml(): wanted error, got none")
+					return
+				}
+				want := *MongoDBDefaults
+				tt.want(&want)
+				if !reflect.DeepEqual(*got, want) {
+					t.Errorf("FromYaml(): got %v, wanted %v", *got, want)
+				}
+				if !reflect.DeepEqual(unknown, tt.wantUnknown) {
+					t.Errorf("FromYaml(): got unknown keys %v, wanted %v", unknown, tt.wantUnknown)
+				}
+			} else {
+				if !tt.wantErr {
+					t.Errorf("FromYaml(): got unwanted error %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestType_FromCmdLineOpts(t *testing.T) {
+	in := `{
+	"argv" : [ "mongod", "--config", "/etc/mongod.conf" ],
+	"parsed" : {
+		"config" : "/etc/mongod.conf",
+		"net" : { "bindIp" : "10.1.2.3", "port" : NumberLong(27018) },
+		"processManagement" : { "fork" : true, "timeZoneInfo" : "/usr/share/zoneinfo" },
+		"storage" : { "dbPath" : "/var/lib/mongo", "wiredTiger" : { "engineConfig" : { "cacheSizeGB" : { "$numberDouble" : "1.5" } } } }
+	},
+	"ok" : 1
+}`
+	got, unknown, err := FromCmdLineOpts([]byte(in))
+	if err != nil {
+		t.Fatalf("FromCmdLineOpts(): got unwanted error %v", err)
+	}
+	want := *MongoDBDefaults
+	want.Net.BindIp = "10.1.2.3"
+	want.Net.Port = 27018
+	want.ProcessManagement.Fork = true
+	want.Storage.DbPath = "/var/lib/mongo"
+	want.Storage.WiredTiger.EngineConfig.CacheSizeGB = 1.5
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("FromCmdLineOpts(): got %v, wanted %v", *got, want)
+	}
+	wantUnknown := []string{"processManagement.timeZoneInfo"}
+	if !reflect.DeepEqual(unknown, wantUnknown) {
+		t.Errorf("FromCmdLineOpts(): got unknown keys %v, wanted %v", unknown, wantUnknown)
+	}
+}
+
+func TestPrintStruct(t *testing.T) {
+	x := struct {
+		Components map[string]uint
+		Servers    []string
+		Nested     map[string]interface{}
+		Empty      map[string]uint
+	}{
+		Components: map[string]uint{"storage": 2, "accessControl": 1},
+		Servers:    []string{"ldap1.example.com", "ldap2.example.com"},
+		Nested:     map[string]interface{}{"b": true, "a": map[string]interface{}{"x": -1, "y": "z: w"}},
+	}
+	want := `components:
+  accessControl: 1
+  storage: 2
+servers:
+  - ldap1.example.com
+  - ldap2.example.com
+nested:
+  a:
+    x: -1
+    y: 'z: w'
+  b: true
+`
+	xv := reflect.ValueOf(x)
+	buf := new(bytes.Buffer)
+	printStruct("", &xv, buf, false)
+	if buf.String() != want {
+		t.Errorf("printStruct(): got\n%s\nwanted\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var parametersType = reflect.TypeOf(Parameters{})
+
+// ParseParameter parses "name=value" as given to --setParameter, typing the value as it would be in YAML
+// e.g. "diagnosticDataCollectionEnabled=false" is a bool and "failpoint.x={mode: alwaysOn}" a document
+func ParseParameter(s string) (string, interface{}, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", nil, fmt.Errorf("parameter '%s' is not in the form name=value", s)
+	}
+	name, text := s[:i], strings.TrimSpace(s[i+1:])
+	if text == "" {
+		return name, "", nil
+	}
+	node, err := parseInline(text, 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("parameter %s: %v", name, err)
+	}
+	return name, parameterValue(node), nil
+}
+
+// convert a YAML node to a parameter value
+// Strings holding a JSON document are converted to documents, since that is how mongod reads them from a config file.
+func parameterValue(node *yamlNode) interface{} {
+	switch node.kind {
+	case yamlMap:
+		m := make(map[string]interface{}, len(node.pairs))
+		for _, pair := range node.pairs {
+			m[pair.key] = parameterValue(pair.value)
+		}
+		return m
+	case yamlSeq:
+		a := make([]interface{}, len(node.items))
+		for i, item := range node.items {
+			a[i] = parameterValue(item)
+		}
+		return a
+	}
+	if strings.HasPrefix(node.value, "{") {
+		var m map[string]interface{}
+		dec := json.NewDecoder(strings.NewReader(node.value))
+		dec.UseNumber()
+		if dec.Decode(&m) == nil {
+			return normalizeJSON(m)
+		}
+	}
+	return scalarValue(node)
+}
+
+// the value of a YAML scalar: a string if it was quoted, otherwise nil, a bool, an int or a float64 if it reads as one
+func scalarValue(node *yamlNode) interface{} {
+	if node.quoted {
+		return node.value
+	}
+	if node.isNull() {
+		return nil
+	}
+	switch node.value {
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if i, err := strconv.Atoi(node.value); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(node.value, 64); err == nil {
+		return f
+	}
+	return node.value
+}
+
+// replace json.Number in a decoded JSON document with int or float64
+func normalizeJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, w := range x {
+			x[k] = normalizeJSON(w)
+		}
+	case []interface{}:
+		for i, w := range x {
+			x[i] = normalizeJSON(w)
+		}
+	case json.Number:
+		if i, err := strconv.Atoi(string(x)); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
+
+// write out setParameter entries in YAML, in name order
+// mongod expects document-valued parameters as a JSON string, so nested documents are written that way.
+func printParameters(leader string, params Parameters, buf *bytes.Buffer) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(buf, "%s%s: %s\n", leader, yamlString(name), ParameterString(params[name]))
+	}
+}
+
+// ParameterString is the YAML representation of a parameter value
+func ParameterString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		js, _ := json.Marshal(x)
+		return yamlString(string(js))
+	case string:
+		s := yamlString(x)
+		if s == x && looksTyped(x) {
+			s = "'" + x + "'" // keep strings like "true" or "42" from being read back as another type
+		}
+		return s
+	case float64:
+		s := strconv.FormatFloat(x, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			s += ".0" // keep whole numbers from being read back as int
+		}
+		return s
+	default:
+		xv := reflect.ValueOf(x)
+		return scalarString(&xv)
+	}
+}
+
+// the value of a parameter as given to --setParameter: strings as is and documents as JSON
+func parameterRaw(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case map[string]interface{}, []interface{}:
+		js, _ := json.Marshal(x)
+		return string(js)
+	}
+	return ParameterString(v)
+}
+
+// would an unquoted string be read back as something other than a string
+func looksTyped(s string) bool {
+	_, isInt := strconv.Atoi(s)
+	_, isFloat := strconv.ParseFloat(s, 64)
+	switch s {
+	case "true", "True", "TRUE", "false", "False", "FALSE", "~", "null", "Null", "NULL":
+		return true
+	}
+	return isInt == nil || isFloat == nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParameter(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantName  string
+		wantValue interface{}
+		wantErr   bool
+	}{
+		{"enableTestCommands=1", "enableTestCommands", 1, false},
+		{"diagnosticDataCollectionEnabled=false", "diagnosticDataCollectionEnabled", false, false},
+		{"authenticationMechanisms=SCRAM-SHA-1,SCRAM-SHA-256", "authenticationMechanisms", "SCRAM-SHA-1,SCRAM-SHA-256", false},
+		{"sampleRate=0.25", "sampleRate", 0.25, false},
+		{"quoted='true'", "quoted", "true", false},
+		{"failpoint.x={mode: alwaysOn, data: {ms: 100}}", "failpoint.x", map[string]interface{}{"mode": "alwaysOn", "data": map[string]interface{}{"ms": 100}}, false},
+		{`logComponentVerbosity={"verbosity": 1}`, "logComponentVerbosity", map[string]interface{}{"verbosity": 1}, false},
+		{"noValue", "", nil, true},
+		{"=value", "", nil, true},
+		{"bad={mode: ", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			name, value, err := ParseParameter(tt.in)
+			if err == nil {
+				if tt.wantErr {
+					t.Errorf("ParseParameter(): wanted error, got none")
+				} else if name != tt.wantName || !reflect.DeepEqual(value, tt.wantValue) {
+					t.Errorf("ParseParameter(): got %s=%#v, wanted %s=%#v", name, value, tt.wantName, tt.wantValue)
+				}
+			} else {
+				if !tt.wantErr {
+					t.Errorf("ParseParameter(): got unwanted error %v", err)
+				}
+			}
+		})
+	}
+}
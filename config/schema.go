@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema returns a JSON Schema (draft-07) for configs, generated from Type
+// Defaults come from the "default" tags and allowed values from the "enum" tags. Settings tagged "omitwindows" are
+// marked with "x-omitWindows" and noted in their description, since mongod on Windows rejects them. Every setting
+// may be null, which layers use to clear it.
+func Schema() (*bytes.Buffer, error) {
+	val := reflect.ValueOf(Type{})
+	schema := schemaStruct(&val)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "mongod configuration"
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding schema: %v", err)
+	}
+	buf := bytes.NewBuffer(out)
+	buf.WriteByte('\n')
+	return buf, nil
+}
+
+// schema for a struct: an object with a property per field, and nothing else
+func schemaStruct(val *reflect.Value) map[string]interface{} {
+	props := make(map[string]interface{}, val.NumField())
+	for i := 0; i < val.NumField(); i++ {
+		sv := val.Field(i)
+		sf := val.Type().Field(i)
+		var prop map[string]interface{}
+		if sf.Type.Kind() == reflect.Struct {
+			prop = schemaStruct(&sv)
+		} else {
+			prop = schemaField(&sv, &sf)
+		}
+		if _, ok := sf.Tag.Lookup("omitwindows"); ok {
+			prop["x-omitWindows"] = true
+			prop["description"] = "not supported on Windows"
+		}
+		props[yamlName(&sf)] = nullable(prop)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// schema for a non-struct field
+func schemaField(val *reflect.Value, field *reflect.StructField) map[string]interface{} {
+	var enum []string
+	if e, ok := field.Tag.Lookup("enum"); ok {
+		enum = strings.Split(e, ",")
+	}
+	switch val.Kind() {
+	case reflect.Map: // setParameter: any parameter name, any value
+		return map[string]interface{}{"type": "object", "additionalProperties": true}
+	case reflect.Slice:
+		items := schemaScalar(val.Type().Elem().Kind(), enum)
+		list := map[string]interface{}{"type": "array", "items": items}
+		sep, ok := field.Tag.Lookup("join")
+		if !ok {
+			return list
+		}
+		// a list, or its items joined into one string
+		joined := map[string]interface{}{"type": "string"}
+		if enum != nil {
+			joined["pattern"] = fmt.Sprintf("^(%s)(%s(%s))*$", strings.Join(enum, "|"), sep, strings.Join(enum, "|"))
+		}
+		return map[string]interface{}{"anyOf": []interface{}{joined, list}}
+	}
+	prop := schemaScalar(val.Kind(), enum)
+	if def, ok := field.Tag.Lookup("default"); ok {
+		dv := reflect.New(field.Type).Elem()
+		if setScalar(&dv, def) == nil {
+			prop["default"] = dv.Interface()
+		}
+	}
+	return prop
+}
+
+// allow null as well as whatever prop allows
+func nullable(prop map[string]interface{}) map[string]interface{} {
+	if anyOf, ok := prop["anyOf"].([]interface{}); ok {
+		prop["anyOf"] = append(anyOf, map[string]interface{}{"type": "null"})
+		return prop
+	}
+	prop["type"] = []interface{}{prop["type"], "null"}
+	if enum, ok := prop["enum"].([]string); ok {
+		var values []interface{}
+		for _, e := range enum {
+			values = append(values, e)
+		}
+		prop["enum"] = append(values, nil)
+	}
+	return prop
+}
+
+func schemaScalar(kind reflect.Kind, enum []string) map[string]interface{} {
+	prop := make(map[string]interface{})
+	switch kind {
+	case reflect.String:
+		prop["type"] = "string"
+	case reflect.Bool:
+		prop["type"] = "boolean"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		prop["type"] = "integer"
+		prop["minimum"] = 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		prop["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		prop["type"] = "number"
+	default:
+		panic("schemaScalar: Unknown type in config struct")
+	}
+	if enum != nil {
+		prop["enum"] = enum
+	}
+	return prop
+}
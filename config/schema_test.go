@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	buf, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema(): %v", err)
+	}
+	var schema map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &schema)
+	if err != nil {
+		t.Fatalf("Schema(): invalid JSON: %v", err)
+	}
+	tests := []struct {
+		name string
+		path []string
+		want interface{}
+	}{
+		{"default bool", []string{"storage", "journal", "enabled", "default"}, true},
+		{"default int", []string{"operationProfiling", "slowOpThresholdMs", "default"}, float64(100)},
+		{"enum", []string{"storage", "engine", "enum"}, []interface{}{"wiredTiger", "inMemory", "mmapv1", nil}},
+		{"integer", []string{"net", "port", "type"}, []interface{}{"integer", "null"}},
+		{"yaml tag", []string{"net", "tls", "CAFile", "type"}, []interface{}{"string", "null"}},
+		{"omitwindows", []string{"processManagement", "fork", "x-omitWindows"}, true},
+		{"setParameter", []string{"setParameter", "additionalProperties"}, true},
+		{"closed", []string{"net", "additionalProperties"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{} = schema
+			for i, key := range tt.path {
+				m, ok := got.(map[string]interface{})
+				if !ok {
+					t.Fatalf("Schema(): %v is not an object", tt.path[:i])
+				}
+				if i < len(tt.path)-1 {
+					m = m["properties"].(map[string]interface{})
+				}
+				got = m[key]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Schema(): %v got %v, wanted %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// every built-in profile must be valid against the schema, nulls clearing settings included
+func TestSchema_Profiles(t *testing.T) {
+	buf, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema(): %v", err)
+	}
+	var schema map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &schema)
+	if err != nil {
+		t.Fatalf("Schema(): invalid JSON: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join("..", "staticContent", "profile_*.yaml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no profiles found: %v", err)
+	}
+	for _, fn := range files {
+		t.Run(filepath.Base(fn), func(t *testing.T) {
+			in, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := parseYaml(in)
+			if err != nil {
+				t.Fatalf("%s: %v", fn, err)
+			}
+			for _, problem := range checkSchema("", schema, yamlValue(root)) {
+				t.Errorf("%s: %s", fn, problem)
+			}
+		})
+	}
+	if problems := checkSchema("", schema, map[string]interface{}{"net": map[string]interface{}{"port": "x"}}); len(problems) == 0 {
+		t.Errorf("checkSchema(): a string port is valid")
+	}
+}
+
+// a YAML node as the value JSON would decode to
+func yamlValue(n *yamlNode) interface{} {
+	switch n.kind {
+	case yamlMap:
+		m := make(map[string]interface{})
+		for _, pair := range n.pairs {
+			m[pair.key] = yamlValue(pair.value)
+		}
+		return m
+	case yamlSeq:
+		var items []interface{}
+		for _, item := range n.items {
+			items = append(items, yamlValue(item))
+		}
+		return items
+	}
+	v := parameterValue(n)
+	if i, ok := v.(int); ok {
+		return float64(i)
+	}
+	return v
+}
+
+// the ways value breaks the parts of JSON Schema that Schema uses
+func checkSchema(path string, schema map[string]interface{}, value interface{}) []string {
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, s := range anyOf {
+			if len(checkSchema(path, s.(map[string]interface{}), value)) == 0 {
+				return nil
+			}
+		}
+		return []string{fmt.Sprintf("%s: %v matches none of anyOf", path, value)}
+	}
+	types := []interface{}{schema["type"]}
+	if list, ok := schema["type"].([]interface{}); ok {
+		types = list
+	}
+	matched := false
+	for _, typ := range types {
+		switch typ {
+		case "null":
+			matched = matched || value == nil
+		case "object":
+			_, ok := value.(map[string]interface{})
+			matched = matched || ok
+		case "array":
+			_, ok := value.([]interface{})
+			matched = matched || ok
+		case "string":
+			_, ok := value.(string)
+			matched = matched || ok
+		case "boolean":
+			_, ok := value.(bool)
+			matched = matched || ok
+		case "number":
+			_, ok := value.(float64)
+			matched = matched || ok
+		case "integer":
+			f, ok := value.(float64)
+			matched = matched || (ok && f == math.Trunc(f))
+		}
+	}
+	if !matched {
+		return []string{fmt.Sprintf("%s: %v is not of type %v", path, value, schema["type"])}
+	}
+	var problems []string
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == value
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, ok := value.(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			problems = append(problems, fmt.Sprintf("%s: %s doesn't match %s", path, s, pattern))
+		}
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		props, _ := schema["properties"].(map[string]interface{})
+		for k, v := range m {
+			if prop, ok := props[k].(map[string]interface{}); ok {
+				problems = append(problems, checkSchema(path+"."+k, prop, v)...)
+			} else if schema["additionalProperties"] == false {
+				problems = append(problems, fmt.Sprintf("%s: unknown setting %s", path, k))
+			}
+		}
+	}
+	if items, ok := value.([]interface{}); ok {
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				problems = append(problems, checkSchema(fmt.Sprintf("%s[%d]", path, i), itemSchema, item)...)
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The TLS settings a config uses: net.tls, or net.ssl if only that is set, as in configs for releases before 4.2
+type TLSFields struct {
+	Mode               *string
+	CertificateKeyFile *string
+	CAFile             *string
+	ClusterFile        *string
+}
+
+// TLS points at the TLS settings in use, so they can be read or changed without caring whether they are net.tls or net.ssl
+func (c *Type) TLS() TLSFields {
+	if c.Net.Tls.Mode == "" && c.Net.Ssl.Mode != "" {
+		return TLSFields{&c.Net.Ssl.Mode, &c.Net.Ssl.PEMKeyFile, &c.Net.Ssl.CAFile, &c.Net.Ssl.ClusterFile}
+	}
+	return TLSFields{&c.Net.Tls.Mode, &c.Net.Tls.CertificateKeyFile, &c.Net.Tls.CAFile, &c.Net.Tls.ClusterFile}
+}
+
+// TLSEnabled reports whether the member accepts TLS connections
+func (c *Type) TLSEnabled() bool {
+	mode := *c.TLS().Mode
+	return mode != "" && mode != "disabled"
+}
+
+// TLSPreferred reports whether clients should connect to the member with TLS: it prefers or requires it
+func (c *Type) TLSPreferred() bool {
+	switch *c.TLS().Mode {
+	case "preferTLS", "requireTLS", "preferSSL", "requireSSL":
+		return true
+	}
+	return false
+}
+
+// AuthMechanisms lists the mechanisms in setParameter authenticationMechanisms, nil if it isn't set
+func (c *Type) AuthMechanisms() []string {
+	var mechanisms []string
+	switch v := c.SetParameter["authenticationMechanisms"].(type) {
+	case string:
+		for _, m := range strings.Split(v, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				mechanisms = append(mechanisms, m)
+			}
+		}
+	case []interface{}:
+		for _, m := range v {
+			mechanisms = append(mechanisms, fmt.Sprint(m))
+		}
+	}
+	return mechanisms
+}
+
+// X509Auth reports whether clients can authenticate with x.509 certificates
+func (c *Type) X509Auth() bool {
+	for _, m := range c.AuthMechanisms() {
+		if m == "MONGODB-X509" {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordAuth reports whether clients can authenticate with a user name and password
+// mongod allows SCRAM unless authenticationMechanisms leaves it out.
+func (c *Type) PasswordAuth() bool {
+	mechanisms := c.AuthMechanisms()
+	if mechanisms == nil {
+		return true
+	}
+	for _, m := range mechanisms {
+		if strings.HasPrefix(m, "SCRAM-") {
+			return true
+		}
+	}
+	return false
+}
+
+// problems with the settings x.509 authentication relies on
+func (c *Type) x509Problems() []string {
+	if !c.X509Auth() {
+		return nil
+	}
+	if !c.TLSEnabled() {
+		return []string{"setParameter.authenticationMechanisms: MONGODB-X509 requires TLS to be enabled"}
+	}
+	if *c.TLS().CAFile == "" {
+		return []string{"setParameter.authenticationMechanisms: MONGODB-X509 requires a CA file to check client certificates"}
+	}
+	return nil
+}
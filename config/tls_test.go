@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestType_AuthMechanisms(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       Parameters
+		wantX509     bool
+		wantPassword bool
+	}{
+		{"not set", nil, false, true},
+		{"scram", Parameters{"authenticationMechanisms": "SCRAM-SHA-1,SCRAM-SHA-256"}, false, true},
+		{"both", Parameters{"authenticationMechanisms": "SCRAM-SHA-256, MONGODB-X509"}, true, true},
+		{"x509 only", Parameters{"authenticationMechanisms": "MONGODB-X509"}, true, false},
+		{"list", Parameters{"authenticationMechanisms": []interface{}{"MONGODB-X509"}}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MongoDBDefaults.Copy()
+			c.SetParameter = tt.params
+			if got := c.X509Auth(); got != tt.wantX509 {
+				t.Errorf("X509Auth() = %v, want %v", got, tt.wantX509)
+			}
+			if got := c.PasswordAuth(); got != tt.wantPassword {
+				t.Errorf("PasswordAuth() = %v, want %v", got, tt.wantPassword)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"reflect"
+	"strings"
+)
+
+// Releases and editions supporting a setting
+// A rule's key matches the setting with that dotted key and every setting below it.
+// If value is set, the rule only applies when the setting has that value or is a list containing it.
+type support struct {
+	key        string
+	value      string
+	since      string // first release supporting the setting, "" if all releases do
+	until      string // first release no longer supporting it, "" if it is still supported
+	enterprise bool   // MongoDB Enterprise only
+}
+
+var supportTable = []support{
+	{key: "storage.engine", value: "mmapv1", until: "4.2"},
+	{key: "storage.engine", value: "inMemory", since: "3.2", enterprise: true},
+	{key: "storage.journal.enabled", until: "6.1"},
+	{key: "storage.wiredTiger.collectionConfig.blockCompressor", value: "zstd", since: "4.2"},
+	{key: "net.tls", since: "4.2"},
+	{key: "net.compression", since: "3.4"},
+	{key: "net.compression.compressors", value: "zstd", since: "4.2"},
+	{key: "operationProfiling.slowOpSampleRate", since: "3.6"},
+	{key: "auditLog", enterprise: true},
+	{key: "security.ldap", since: "3.4", enterprise: true},
+	{key: "security.enableEncryption", since: "3.2", enterprise: true},
+	{key: "security.encryptionCipherMode", since: "3.2", enterprise: true},
+	{key: "security.encryptionKeyFile", since: "3.2", enterprise: true},
+	{key: "security.kmip", since: "3.2", enterprise: true},
+}
+
+// Validate checks every setting of a config against the releases and editions that support it
+// Options that were renamed are translated to the name the release understands, e.g. net.ssl to net.tls on 4.2
+// and later; every translation is returned. All unsupported settings are reported together in the error, along with
+// cluster auth modes and x.509 authentication missing the keyfile, certificates or CA they need.
+func (c *Type) Validate(v *version.Version) ([]Change, error) {
+	var changes []Change
+	var problems []string
+	if v.Release.AtLeast(4, 2) {
+		changes, problems = renameFields(&c.Net.Ssl, &c.Net.Tls, "net.ssl", "net.tls", sslToTls)
+	} else {
+		changes, problems = renameFields(&c.Net.Tls, &c.Net.Ssl, "net.tls", "net.ssl", tlsToSsl)
+	}
+
+	problems = append(problems, c.clusterAuthProblems()...)
+	problems = append(problems, c.x509Problems()...)
+	problems = append(problems, c.ldapProblems()...)
+	problems = append(problems, c.encryptionProblems()...)
+	problems = append(problems, c.auditProblems()...)
+	for _, s := range c.settings(false) {
+		for _, rule := range supportTable {
+			if !rule.matches(s) {
+				continue
+			}
+			if rule.since != "" && !inSeries(v.Release, rule.since) {
+				problems = append(problems, fmt.Sprintf("%s is not supported before %s", rule.describe(s), rule.since))
+			}
+			if rule.until != "" && inSeries(v.Release, rule.until) {
+				problems = append(problems, fmt.Sprintf("%s is not supported in %s and later", rule.describe(s), rule.until))
+			}
+			if rule.enterprise && !v.Release.Enterprise {
+				problems = append(problems, fmt.Sprintf("%s requires MongoDB Enterprise", rule.describe(s)))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return changes, fmt.Errorf("config not valid for %s:\n  %s", v.Release, strings.Join(problems, "\n  "))
+	}
+	return changes, nil
+}
+
+func (rule *support) matches(s setting) bool {
+	if s.key != rule.key && !strings.HasPrefix(s.key, rule.key+".") {
+		return false
+	}
+	if rule.value == "" {
+		return true
+	}
+	for _, item := range strings.Split(s.value, ",") {
+		if item == rule.value {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *support) describe(s setting) string {
+	if rule.value != "" {
+		return fmt.Sprintf("%s: %s", s.key, rule.value)
+	}
+	return s.key
+}
+
+// whether r is in series "x.y" from the support table or a newer one, counting pre-releases as their series
+func inSeries(r version.ReleaseType, series string) bool {
+	s, err := version.ToRelease(series + ".0")
+	if err != nil {
+		panic("bad release in support table: " + series)
+	}
+	return r.AtLeast(s.Version, s.Major)
+}
+
+// Field names in net.ssl and their equivalents in net.tls
+var sslToTls = map[string]string{
+	"mode":                                "mode",
+	"PEMKeyFile":                          "certificateKeyFile",
+	"PEMKeyPassword":                      "certificateKeyFilePassword",
+	"CAFile":                              "CAFile",
+	"CRLFile":                             "CRLFile",
+	"clusterFile":                         "clusterFile",
+	"allowConnectionsWithoutCertificates": "allowConnectionsWithoutCertificates",
+	"allowInvalidCertificates":            "allowInvalidCertificates",
+	"allowInvalidHostnames":               "allowInvalidHostnames",
+	"disabledProtocols":                   "disabledProtocols",
+}
+
+var tlsToSsl = map[string]string{}
+
+func init() {
+	for k, v := range sslToTls {
+		tlsToSsl[v] = k
+	}
+}
+
+// move every set field of the struct at from into the struct at to, using names to map field names
+// Mode values are renamed as well, e.g. "requireSSL" to "requireTLS". A field set in both to a different value is
+// left alone and reported as a problem; one set to the same value is dropped from from.
+func renameFields(from interface{}, to interface{}, fromKey string, toKey string, names map[string]string) ([]Change, []string) {
+	var changes []Change
+	var problems []string
+	fv := reflect.ValueOf(from).Elem()
+	tv := reflect.ValueOf(to).Elem()
+	for i := 0; i < fv.NumField(); i++ {
+		sv := fv.Field(i)
+		sf := fv.Type().Field(i)
+		if isDefault(&sv, &sf) {
+			continue
+		}
+		fromName := yamlName(&sf)
+		toName := names[fromName]
+		conflict := false
+		for j := 0; j < tv.NumField(); j++ {
+			df := tv.Type().Field(j)
+			if yamlName(&df) != toName {
+				continue
+			}
+			nv := reflect.New(sf.Type).Elem()
+			nv.Set(sv)
+			if fromName == "mode" {
+				mode := sv.String()
+				if strings.HasSuffix(mode, "SSL") || strings.HasSuffix(mode, "TLS") {
+					mode = mode[:len(mode)-3] + strings.ToUpper(toKey[len(toKey)-3:])
+				}
+				nv.SetString(mode)
+			}
+			dv := tv.Field(j)
+			if !isDefault(&dv, &df) {
+				if !reflect.DeepEqual(dv.Interface(), nv.Interface()) {
+					problems = append(problems, fmt.Sprintf("%s.%s and %s.%s are both set, to different values", fromKey, fromName, toKey, toName))
+					conflict = true
+				}
+				continue
+			}
+			dv.Set(nv)
+			var s []setting
+			flattenValue(toKey+"."+toName, &dv, &df, &s, false)
+			changes = append(changes, Change{Key: toKey + "." + toName, New: s[0].value, Reason: "renamed from " + fromKey + "." + fromName})
+		}
+		if !conflict {
+			sv.Set(reflect.Zero(sf.Type))
+		}
+	}
+	return changes, problems
+}
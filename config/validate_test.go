@@ -0,0 +1,248 @@
+package config
+
+import (
+	"github.com/SpencerBrown/mongodb-repro/version"
+	"testing"
+)
+
+func TestType_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		release     string
+		enterprise  bool
+		set         func(c *Type)
+		check       func(c *Type) bool
+		wantChanges int
+		wantErr     bool
+	}{
+		{
+			name:    "defaults",
+			release: "4.4.1",
+			set:     func(c *Type) {},
+		},
+		{
+			name:    "tls on 4.2",
+			release: "4.2.9",
+			set:     func(c *Type) { c.Net.Tls.Mode = "requireTLS" },
+		},
+		{
+			name:    "tls on 4.2 rc",
+			release: "4.2.0-rc1",
+			set:     func(c *Type) { c.Net.Tls.Mode = "requireTLS" },
+			check:   func(c *Type) bool { return c.Net.Tls.Mode == "requireTLS" },
+		},
+		{
+			name:    "tls on 4.0",
+			release: "4.0.19",
+			set:     func(c *Type) { c.Net.Tls.Mode = "requireTLS"; c.Net.Tls.CertificateKeyFile = "/x/server.pem" },
+			check: func(c *Type) bool {
+				return c.Net.Ssl.Mode == "requireSSL" && c.Net.Ssl.PEMKeyFile == "/x/server.pem" && c.Net.Tls.Mode == ""
+			},
+			wantChanges: 2,
+		},
+		{
+			name:    "ssl on 4.4",
+			release: "4.4.0",
+			set:     func(c *Type) { c.Net.Ssl.Mode = "preferSSL"; c.Net.Ssl.AllowInvalidHostnames = true },
+			check: func(c *Type) bool {
+				return c.Net.Tls.Mode == "preferTLS" && c.Net.Tls.AllowInvalidHostnames && c.Net.Ssl.Mode == ""
+			},
+			wantChanges: 2,
+		},
+		{
+			name:    "ssl and tls on 4.4",
+			release: "4.4.0",
+			set: func(c *Type) {
+				c.Net.Ssl.Mode, c.Net.Ssl.PEMKeyFile, c.Net.Ssl.CAFile = "requireSSL", "/x/old.pem", "/x/ca.pem"
+				c.Net.Tls.Mode, c.Net.Tls.CertificateKeyFile = "requireTLS", "/x/server.pem"
+			},
+			check: func(c *Type) bool {
+				return c.Net.Tls.CertificateKeyFile == "/x/server.pem" && c.Net.Ssl.PEMKeyFile == "/x/old.pem" &&
+					c.Net.Tls.CAFile == "/x/ca.pem" && c.Net.Ssl.Mode == ""
+			},
+			wantChanges: 1,
+			wantErr:     true,
+		},
+		{
+			name:    "mmapv1 on 4.2",
+			release: "4.2.0",
+			set:     func(c *Type) { c.Storage.Engine = "mmapv1" },
+			wantErr: true,
+		},
+		{
+			name:    "mmapv1 on 4.0",
+			release: "4.0.19",
+			set:     func(c *Type) { c.Storage.Engine = "mmapv1" },
+		},
+		{
+			name:    "inMemory community",
+			release: "4.2.9",
+			set:     func(c *Type) { c.Storage.Engine = "inMemory" },
+			wantErr: true,
+		},
+		{
+			name:       "inMemory enterprise",
+			release:    "4.2.9",
+			enterprise: true,
+			set:        func(c *Type) { c.Storage.Engine = "inMemory" },
+		},
+		{
+			name:    "zstd compressor on 4.0",
+			release: "4.0.19",
+			set:     func(c *Type) { c.Net.Compression.Compressors = []string{"snappy", "zstd"} },
+			wantErr: true,
+		},
+		{
+			name:    "audit community",
+			release: "4.4.1",
+			set:     func(c *Type) { c.AuditLog.Destination = "console" },
+			wantErr: true,
+		},
+		{
+			name:    "keyFile mode",
+			release: "4.4.1",
+			set:     func(c *Type) { c.Security.ClusterAuthMode = "keyFile"; c.Security.KeyFile = "/x/keyfile" },
+		},
+		{
+			name:    "keyFile mode without keyfile",
+			release: "4.4.1",
+			set:     func(c *Type) { c.Security.ClusterAuthMode = "keyFile" },
+			wantErr: true,
+		},
+		{
+			name:    "sendX509 without tls",
+			release: "4.4.1",
+			set:     func(c *Type) { c.Security.ClusterAuthMode = "sendX509"; c.Security.KeyFile = "/x/keyfile" },
+			wantErr: true,
+		},
+		{
+			name:    "x509",
+			release: "4.4.1",
+			set: func(c *Type) {
+				c.Security.ClusterAuthMode = "x509"
+				c.Net.Tls.Mode = "requireTLS"
+				c.Net.Tls.CertificateKeyFile = "/x/server.pem"
+			},
+		},
+		{
+			name:    "x509 auth without CA",
+			release: "4.4.1",
+			set: func(c *Type) {
+				c.SetParameter = Parameters{"authenticationMechanisms": "SCRAM-SHA-256,MONGODB-X509"}
+				c.Net.Tls.Mode = "requireTLS"
+				c.Net.Tls.CertificateKeyFile = "/x/server.pem"
+			},
+			wantErr: true,
+		},
+		{
+			name:       "ldap",
+			release:    "4.4.1",
+			enterprise: true,
+			set: func(c *Type) {
+				c.Security.Ldap.Servers = "localhost:3389"
+				c.Security.Ldap.Authz.QueryTemplate = "ou=groups,dc=repro,dc=test??one?(member={USER})"
+				c.SetParameter = Parameters{"authenticationMechanisms": "PLAIN,SCRAM-SHA-256"}
+			},
+		},
+		{
+			name:    "ldap community",
+			release: "4.4.1",
+			set: func(c *Type) {
+				c.Security.Ldap.Servers = "localhost:3389"
+				c.SetParameter = Parameters{"authenticationMechanisms": "PLAIN"}
+			},
+			wantErr: true,
+		},
+		{
+			name:       "ldap query template without user",
+			release:    "4.4.1",
+			enterprise: true,
+			set: func(c *Type) {
+				c.Security.Ldap.Servers = "localhost:3389"
+				c.Security.Ldap.Authz.QueryTemplate = "ou=groups,dc=repro,dc=test??one?(member=alice)"
+			},
+			wantErr: true,
+		},
+		{
+			name:       "ldap settings without servers",
+			release:    "4.4.1",
+			enterprise: true,
+			set:        func(c *Type) { c.Security.Ldap.UserToDNMapping = `[{match: "(.+)", substitution: "uid={0}"}]` },
+			wantErr:    true,
+		},
+		{
+			name:       "encryption with key file",
+			release:    "4.4.1",
+			enterprise: true,
+			set:        func(c *Type) { c.Security.EnableEncryption = true; c.Security.EncryptionKeyFile = "/x/encryption.key" },
+		},
+		{
+			name:       "encryption without key",
+			release:    "4.4.1",
+			enterprise: true,
+			set:        func(c *Type) { c.Security.EnableEncryption = true },
+			wantErr:    true,
+		},
+		{
+			name:       "kmip without client certificate",
+			release:    "4.4.1",
+			enterprise: true,
+			set:        func(c *Type) { c.Security.EnableEncryption = true; c.Security.Kmip.ServerName = "localhost" },
+			wantErr:    true,
+		},
+		{
+			name:    "encryption community",
+			release: "4.4.1",
+			set:     func(c *Type) { c.Security.EnableEncryption = true; c.Security.EncryptionKeyFile = "/x/encryption.key" },
+			wantErr: true,
+		},
+		{
+			name:       "audit to file",
+			release:    "4.4.1",
+			enterprise: true,
+			set: func(c *Type) {
+				c.AuditLog.Destination, c.AuditLog.Format, c.AuditLog.Path = "file", "BSON", "/x/audit.bson"
+				c.AuditLog.Filter = `{ atype: "authenticate" }`
+			},
+		},
+		{
+			name:       "audit to file without path",
+			release:    "4.4.1",
+			enterprise: true,
+			set:        func(c *Type) { c.AuditLog.Destination = "file"; c.AuditLog.Format = "JSON" },
+			wantErr:    true,
+		},
+		{
+			name:       "audit filter not a document",
+			release:    "4.4.1",
+			enterprise: true,
+			set:        func(c *Type) { c.AuditLog.Destination = "console"; c.AuditLog.Filter = "atype: authenticate" },
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &version.Version{Arch: "x86_64", OS: "linux", Distro: "ubuntu1804"}
+			v.Release, _ = version.ToRelease(tt.release)
+			v.Release.Enterprise = tt.enterprise
+			c := MongoDBDefaults.Copy()
+			tt.set(c)
+			changes, err := c.Validate(v)
+			if err == nil {
+				if tt.wantErr {
+					t.Errorf("Validate(): wanted error, got none")
+				}
+			} else {
+				if !tt.wantErr {
+					t.Errorf("Validate(): got unwanted error %v", err)
+				}
+			}
+			if len(changes) != tt.wantChanges {
+				t.Errorf("Validate(): got changes %v, wanted %d", changes, tt.wantChanges)
+			}
+			if tt.check != nil && !tt.check(c) {
+				t.Errorf("Validate(): translation not applied: %v", c.Net)
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A minimal YAML reader covering what appears in mongod.conf files: block mappings and sequences, plain and quoted
+// scalars, single-line flow mappings and sequences, and comments. Anchors, tags and block scalars are not supported.
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMap
+	yamlSeq
+)
+
+type yamlNode struct {
+	kind   yamlKind
+	value  string      // scalar value with quotes removed
+	quoted bool        // true if the scalar was quoted, so it is always a string
+	pairs  []yamlPair  // mapping entries in document order
+	items  []*yamlNode // sequence items
+	line   int         // source line, for error messages
+}
+
+type yamlPair struct {
+	key   string
+	value *yamlNode
+}
+
+// isNull reports whether a node is an unquoted YAML null (empty, "~" or "null")
+func (n *yamlNode) isNull() bool {
+	if n.kind != yamlScalar || n.quoted {
+		return false
+	}
+	switch n.value {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	}
+	return false
+}
+
+// error for a node, prefixed with its line number if it came from a YAML source
+func (n *yamlNode) errorf(format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	if n.line > 0 {
+		return fmt.Errorf("line %d: %s", n.line, msg)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+type yamlLine struct {
+	num    int    // 1-based line number
+	indent int    // count of leading spaces
+	text   string // content with indentation and comments removed
+}
+
+// parse a YAML document into a tree of nodes
+func parseYaml(in []byte) (*yamlNode, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(in), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if strings.HasPrefix(raw, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := strings.TrimRight(stripComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "%") {
+			continue // directive
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return &yamlNode{kind: yamlMap}, nil
+	}
+	p := &yamlParser{lines: lines}
+	node, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return node, nil
+}
+
+// remove a trailing comment, i.e. a '#' at the start of the line or after whitespace, outside of quotes
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'' && c == '\'':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++ // escaped single quote
+			} else {
+				quote = 0
+			}
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '"' && c == '"':
+			quote = 0
+		case quote != 0:
+		case c == '\'' || c == '"':
+			if i == 0 || strings.ContainsRune(" \t:-[{,", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parse the mapping or sequence starting at the current line, which must be at the given indentation
+func (p *yamlParser) parseBlock(indent int) (*yamlNode, error) {
+	l := p.lines[p.pos]
+	if isSeqItem(l.text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseMap(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlMap, line: p.lines[p.pos].num}
+	seen := make(map[string]bool)
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		if isSeqItem(l.text) {
+			break
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key: value', found '%s'", l.num, l.text)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", l.num, key)
+		}
+		seen[key] = true
+		p.pos++
+		var value *yamlNode
+		var err error
+		switch {
+		case rest != "":
+			value, err = parseInline(rest, l.num)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			value, err = p.parseBlock(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text):
+			// sequences may sit at the same indentation as their key
+			value, err = p.parseSeq(indent)
+		default:
+			value = &yamlNode{kind: yamlScalar, line: l.num}
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.pairs = append(node.pairs, yamlPair{key: key, value: value})
+	}
+	return node, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSeq, line: p.lines[p.pos].num}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isSeqItem(l.text) {
+			if l.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+			}
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		var item *yamlNode
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err = p.parseBlock(p.lines[p.pos].indent)
+			} else {
+				item = &yamlNode{kind: yamlScalar, line: l.num}
+			}
+		case isSeqItem(rest) || isMapEntry(rest):
+			// the item is a nested block starting on this line; re-read the line as if the dash were indentation
+			p.lines[p.pos].indent += len(l.text) - len(rest)
+			p.lines[p.pos].text = rest
+			item, err = p.parseBlock(p.lines[p.pos].indent)
+		default:
+			p.pos++
+			item, err = parseInline(rest, l.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+	}
+	return node, nil
+}
+
+func isMapEntry(text string) bool {
+	if text[0] == '{' || text[0] == '[' {
+		return false
+	}
+	_, _, ok := splitKey(text)
+	return ok
+}
+
+// split "key: value" at the first colon followed by a space or end of line, outside of quotes
+func splitKey(text string) (key string, rest string, ok bool) {
+	if text[0] == '\'' || text[0] == '"' {
+		k, n, err := unquote(text, 0)
+		if err != nil || n >= len(text) || text[n] != ':' || (n+1 < len(text) && text[n+1] != ' ') {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(text[n+1:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), i > 0
+		}
+	}
+	return "", "", false
+}
+
+// parse a value written on the same line as its key: a scalar or a flow collection
+func parseInline(text string, line int) (*yamlNode, error) {
+	switch text[0] {
+	case '{', '[':
+		f := &flowParser{text: text, line: line}
+		node, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos != len(f.text) {
+			return nil, fmt.Errorf("line %d: unexpected text after flow collection: '%s'", line, f.text[f.pos:])
+		}
+		return node, nil
+	case '|', '>':
+		return nil, fmt.Errorf("line %d: block scalars are not supported", line)
+	case '&', '*', '!':
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported", line)
+	case '\'', '"':
+		s, n, err := unquote(text, 0)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if n != len(text) {
+			return nil, fmt.Errorf("line %d: unexpected text after quoted string: '%s'", line, text[n:])
+		}
+		return &yamlNode{kind: yamlScalar, value: s, quoted: true, line: line}, nil
+	}
+	return &yamlNode{kind: yamlScalar, value: text, line: line}, nil
+}
+
+// unquote the quoted string starting at text[start], returning the string and the index just past the closing quote
+func unquote(text string, start int) (string, int, error) {
+	q := text[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case q == '\'' && c == '\'':
+			if i+1 < len(text) && text[i+1] == '\'' {
+				sb.WriteByte('\'')
+				i++
+				continue
+			}
+			return sb.String(), i + 1, nil
+		case q == '"' && c == '"':
+			return sb.String(), i + 1, nil
+		case q == '"' && c == '\\':
+			i++
+			if i == len(text) {
+				return "", 0, fmt.Errorf("unterminated escape sequence")
+			}
+			switch text[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '0':
+				sb.WriteByte(0)
+			case '"', '\\', '/', ' ':
+				sb.WriteByte(text[i])
+			default:
+				return "", 0, fmt.Errorf("unsupported escape sequence '\\%c'", text[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string")
+}
+
+// parser for single-line flow collections such as "{ a: 1, b: [x, y] }"
+type flowParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flowParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %s", f.line, fmt.Sprintf(format, a...))
+}
+
+func (f *flowParser) parseValue() (*yamlNode, error) {
+	f.skipSpace()
+	if f.pos == len(f.text) {
+		return nil, f.errorf("unexpected end of flow collection")
+	}
+	switch f.text[f.pos] {
+	case '{':
+		return f.parseCollection(yamlMap, '}')
+	case '[':
+		return f.parseCollection(yamlSeq, ']')
+	case '\'', '"':
+		s, n, err := unquote(f.text, f.pos)
+		if err != nil {
+			return nil, f.errorf("%v", err)
+		}
+		f.pos = n
+		return &yamlNode{kind: yamlScalar, value: s, quoted: true, line: f.line}, nil
+	}
+	start := f.pos
+	for f.pos < len(f.text) && !strings.ContainsRune(",]}", rune(f.text[f.pos])) {
+		if f.text[f.pos] == ':' && (f.pos+1 == len(f.text) || strings.ContainsRune(" ,]}", rune(f.text[f.pos+1]))) {
+			break
+		}
+		f.pos++
+	}
+	return &yamlNode{kind: yamlScalar, value: strings.TrimSpace(f.text[start:f.pos]), line: f.line}, nil
+}
+
+func (f *flowParser) parseCollection(kind yamlKind, end byte) (*yamlNode, error) {
+	node := &yamlNode{kind: kind, line: f.line}
+	f.pos++ // opening bracket
+	for {
+		f.skipSpace()
+		if f.pos == len(f.text) {
+			return nil, f.errorf("unterminated flow collection")
+		}
+		if f.text[f.pos] == end {
+			f.pos++
+			return node, nil
+		}
+		value, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if kind == yamlMap {
+			if value.kind != yamlScalar || f.pos == len(f.text) || f.text[f.pos] != ':' {
+				return nil, f.errorf("expected 'key: value' in flow mapping")
+			}
+			f.pos++
+			key := value.value
+			value, err = f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			for _, pair := range node.pairs {
+				if pair.key == key {
+					return nil, f.errorf("duplicate key '%s'", key)
+				}
+			}
+			node.pairs = append(node.pairs, yamlPair{key: key, value: value})
+		} else {
+			node.items = append(node.items, value)
+		}
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == ',' {
+			f.pos++
+		} else if f.pos == len(f.text) || f.text[f.pos] != end {
+			return nil, f.errorf("expected ',' or '%c' in flow collection", end)
+		}
+	}
+}
+
+// quote a string for YAML output if it would otherwise not read back as the same plain scalar
+func yamlString(s string) string {
+	if s == "" || needsQuotes(s) {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return s
+}
+
+func needsQuotes(s string) bool {
+	if s != strings.TrimSpace(s) {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "{}[],&*#?|-<>=!%@`'\"") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") || strings.ContainsAny(s, "\n\r\t") {
+		return true
+	}
+	return false
+}
+
+// A MapItem is one entry of a mapping read by ParseYamlDoc
+type MapItem struct {
+	Key   string
+	Value interface{}
+	Line  int // source line of the value
+}
+
+// ParseYamlDoc reads a YAML document other than a config, such as a seed spec, into plain values: mappings become
+// []MapItem in document order, sequences []interface{}, and scalars are typed as setParameter values are
+func ParseYamlDoc(in []byte) (interface{}, error) {
+	root, err := parseYaml(in)
+	if err != nil {
+		return nil, err
+	}
+	return docValue(root), nil
+}
+
+func docValue(node *yamlNode) interface{} {
+	switch node.kind {
+	case yamlMap:
+		items := make([]MapItem, len(node.pairs))
+		for i, pair := range node.pairs {
+			items[i] = MapItem{Key: pair.key, Value: docValue(pair.value), Line: pair.value.line}
+		}
+		return items
+	case yamlSeq:
+		a := make([]interface{}, len(node.items))
+		for i, item := range node.items {
+			a[i] = docValue(item)
+		}
+		return a
+	}
+	return scalarValue(node)
+}
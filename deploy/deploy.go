@@ -0,0 +1,171 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+A deployment is a named set of MongoDB processes (members) sharing a directory under the runtime path:
+	<runtime>/<deployment>/<member>.yaml	config file for each member
+	<runtime>/<deployment>/<member>/	data directory, log file and other runtime files for each member
+	<runtime>/<deployment>/keyfile	keyfile shared by the members for internal authentication
+	<runtime>/<deployment>/faults.jsonl	faults injected into the members, to replay
+	<runtime>/<deployment>/releases.json	the release each member was last started with, to start it with again
+*/
+
+type Deployment struct {
+	Name    string
+	Path    string // directory holding the deployment
+	Members []*Member
+}
+
+type Member struct {
+	Name   string
+	Config *config.Type
+}
+
+const configExt = ".yaml"
+const faultLogName = "faults.jsonl"
+const releasesName = "releases.json"
+
+// New returns an empty deployment; nothing is written until Write is called
+func New(runtimePath string, name string) *Deployment {
+	return &Deployment{
+		Name: name,
+		Path: filepath.Join(runtimePath, name),
+	}
+}
+
+// Open reads an existing deployment's member configs from disk
+func Open(runtimePath string, name string) (*Deployment, error) {
+	d := New(runtimePath, name)
+	files, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return nil, fmt.Errorf("deployment '%s' not found: %v", name, err)
+	}
+	for _, f := range files {
+		fn := f.Name()
+		if f.IsDir() || filepath.Ext(fn) != configExt {
+			continue
+		}
+		cfgbytes, err := ioutil.ReadFile(filepath.Join(d.Path, fn))
+		if err != nil {
+			return nil, fmt.Errorf("error reading config %s: %v", fn, err)
+		}
+		cfg, _, err := config.FromYaml(cfgbytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %v", fn, err)
+		}
+		d.Members = append(d.Members, &Member{Name: strings.TrimSuffix(fn, configExt), Config: cfg})
+	}
+	if len(d.Members) == 0 {
+		return nil, fmt.Errorf("deployment '%s' has no members", name)
+	}
+	sort.Slice(d.Members, func(i, j int) bool { return d.Members[i].Name < d.Members[j].Name })
+	return d, nil
+}
+
+// Add a member to the deployment, replacing any member with the same name
+func (d *Deployment) Add(name string, cfg *config.Type) *Member {
+	m := &Member{Name: name, Config: cfg}
+	for i, old := range d.Members {
+		if old.Name == name {
+			d.Members[i] = m
+			return m
+		}
+	}
+	d.Members = append(d.Members, m)
+	return m
+}
+
+// Member returns the named member, or nil if there is none
+func (d *Deployment) Member(name string) *Member {
+	for _, m := range d.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// MemberPath is the directory holding a member's data, logs and other runtime files
+func (d *Deployment) MemberPath(member string) string {
+	return filepath.Join(d.Path, member)
+}
+
+// ConfigFile is the path of a member's config file
+func (d *Deployment) ConfigFile(member string) string {
+	return filepath.Join(d.Path, member+configExt)
+}
+
+// FaultLog is the path of the file recording the faults injected into the deployment's members
+func (d *Deployment) FaultLog() string {
+	return filepath.Join(d.Path, faultLogName)
+}
+
+// Releases reads the release each member was last started with, by member; none are known if nothing was recorded
+func (d *Deployment) Releases() (map[string]string, error) {
+	releases := make(map[string]string)
+	in, err := ioutil.ReadFile(filepath.Join(d.Path, releasesName))
+	if os.IsNotExist(err) {
+		return releases, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(in, &releases)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading releases: %v", err)
+	}
+	return releases, nil
+}
+
+// SetRelease records the release a member was started with
+func (d *Deployment) SetRelease(member string, release string) error {
+	releases, err := d.Releases()
+	if err != nil {
+		return err
+	}
+	if releases[member] == release {
+		return nil
+	}
+	releases[member] = release
+	out, err := json.MarshalIndent(releases, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(d.Path, releasesName), append(out, '\n'), 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("error recording release: %v", err)
+	}
+	return nil
+}
+
+// Write every member's config file, creating the directories and keyfiles they need
+func (d *Deployment) Write(isWindows bool) error {
+	for _, m := range d.Members {
+		err := config.WriteConfig(m.Config, d.Path, m.Name+configExt, isWindows)
+		if err != nil {
+			return fmt.Errorf("member %s: %v", m.Name, err)
+		}
+	}
+	err := d.createKeyFiles()
+	if err != nil {
+		return err
+	}
+	return d.createEncryptionKeys()
+}
+
+// HostPort is the address to connect to a member on this machine
+func (m *Member) HostPort() string {
+	port := m.Config.Net.Port
+	if port == 0 {
+		port = 27017
+	}
+	return fmt.Sprintf("localhost:%d", port)
+}
@@ -0,0 +1,61 @@
+package deploy
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const encryptionKeyFileName = "encryption.key"
+
+// encryption keys are 256-bit AES keys
+const encryptionKeyBytes = 32
+
+// EncryptionKeyFile is the path of a member's local master key for encryption at rest
+// Each member has its own: members encrypt their own data files, and the key never leaves the machine.
+func (d *Deployment) EncryptionKeyFile(member string) string {
+	return filepath.Join(d.MemberPath(member), encryptionKeyFileName)
+}
+
+// create every encryption key file the members refer to that doesn't exist yet
+func (d *Deployment) createEncryptionKeys() error {
+	for _, m := range d.Members {
+		if m.Config.Security.EncryptionKeyFile == "" {
+			continue
+		}
+		err := GenerateEncryptionKey(m.Config.Security.EncryptionKeyFile)
+		if err != nil {
+			return fmt.Errorf("member %s: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// GenerateEncryptionKey writes a random master key readable only by its owner, as mongod requires
+// An existing key is kept: data files encrypted with it can't be read with any other.
+func GenerateEncryptionKey(fn string) error {
+	if _, err := os.Stat(fn); err == nil {
+		return nil
+	}
+	key := make([]byte, encryptionKeyBytes)
+	_, err := rand.Read(key)
+	if err != nil {
+		return fmt.Errorf("error generating key: %v", err)
+	}
+	err = os.MkdirAll(filepath.Dir(fn), 0777)
+	if err != nil {
+		return fmt.Errorf("encryption key MkDirAll error: %v", err)
+	}
+	err = ioutil.WriteFile(fn, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600)
+	if err != nil {
+		return fmt.Errorf("encryption key write error: %v", err)
+	}
+	err = os.Chmod(fn, 0600)
+	if err != nil {
+		return fmt.Errorf("encryption key Chmod error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package deploy
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const keyFileName = "keyfile"
+
+// keyfiles hold 6 to 1024 base64 characters; 756 random bytes make 1008
+const keyFileBytes = 756
+
+// KeyFile is the path of the keyfile shared by the deployment's members
+func (d *Deployment) KeyFile() string {
+	return filepath.Join(d.Path, keyFileName)
+}
+
+// NeedsClusterAuth reports whether members authenticate to each other: auth is enabled and some member is part of a
+// replica set or sharded cluster
+func (d *Deployment) NeedsClusterAuth() bool {
+	for _, m := range d.Members {
+		c := m.Config
+		if c.Security.Authorization == "enabled" && (c.Replication.ReplSetName != "" || c.Sharding.ClusterRole != "" || c.Sharding.ConfigDB != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// UseKeyFile points every member that has no keyfile at the deployment's shared keyfile
+// Members in x509 mode don't use a keyfile and are left alone. The keyfile itself is created by Write.
+func (d *Deployment) UseKeyFile() {
+	for _, m := range d.Members {
+		if m.Config.Security.KeyFile == "" && m.Config.ClusterAuthMode() != "x509" {
+			m.Config.Security.KeyFile = d.KeyFile()
+		}
+	}
+}
+
+// create every keyfile the members refer to that doesn't exist yet
+func (d *Deployment) createKeyFiles() error {
+	for _, m := range d.Members {
+		if m.Config.Security.KeyFile == "" {
+			continue
+		}
+		err := GenerateKeyFile(m.Config.Security.KeyFile)
+		if err != nil {
+			return fmt.Errorf("member %s: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// GenerateKeyFile writes a random keyfile readable only by its owner, as mongod requires
+// An existing keyfile is kept, so members that already share it can still authenticate to each other.
+func GenerateKeyFile(fn string) error {
+	if _, err := os.Stat(fn); err == nil {
+		return nil
+	}
+	key := make([]byte, keyFileBytes)
+	_, err := rand.Read(key)
+	if err != nil {
+		return fmt.Errorf("error generating key: %v", err)
+	}
+	err = os.MkdirAll(filepath.Dir(fn), 0777)
+	if err != nil {
+		return fmt.Errorf("keyfile MkDirAll error: %v", err)
+	}
+	err = ioutil.WriteFile(fn, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0400)
+	if err != nil {
+		return fmt.Errorf("keyfile write error: %v", err)
+	}
+	err = os.Chmod(fn, 0400)
+	if err != nil {
+		return fmt.Errorf("keyfile Chmod error: %v", err)
+	}
+	return nil
+}
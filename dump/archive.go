@@ -0,0 +1,135 @@
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+)
+
+/*
+A mongodump --archive file, gzipped as a whole with --gzip:
+	magic number	4 bytes, little-endian 0x8199e26d
+	prelude	a header document, then one document per collection with its metadata, then a terminator
+	body	blocks, each a namespace header document followed by documents of that collection and a terminator
+A terminator is the 4 bytes ff ff ff ff. Collections are dumped concurrently, so their blocks are interleaved; a
+header with EOF set ends a collection.
+*/
+
+const archiveMagic = 0x8199e26d
+
+type archiveHeader struct {
+	FormatVersion string `bson:"version"`
+	ServerVersion string `bson:"server_version"`
+	ToolVersion   string `bson:"tool_version"`
+}
+
+type archiveMetadata struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	Metadata   string `bson:"metadata"` // the metadata file's JSON
+}
+
+type namespaceHeader struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	EOF        bool   `bson:"EOF"`
+}
+
+// ReadArchive reads a mongodump archive, gzipped or not, passing its collections and documents to h
+// The oplog dumped with --oplog is skipped.
+func ReadArchive(r io.Reader, h Handler) error {
+	br := bufio.NewReaderSize(r, 1024*1024)
+	if b, err := br.Peek(2); err == nil && b[0] == 0x1f && b[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		br = bufio.NewReaderSize(gz, 1024*1024)
+	}
+	var magic uint32
+	err := binary.Read(br, binary.LittleEndian, &magic)
+	if err != nil || magic != archiveMagic {
+		return fmt.Errorf("not a mongodump archive")
+	}
+
+	doc, err := readDoc(br)
+	if err != nil || doc == nil {
+		return fmt.Errorf("error reading archive header: %v", err)
+	}
+	var header archiveHeader
+	err = bson.Unmarshal(doc, &header)
+	if err != nil {
+		return fmt.Errorf("error reading archive header: %v", err)
+	}
+	collections := make(map[string]*Collection)
+	for {
+		doc, err = readDoc(br)
+		if err != nil {
+			return fmt.Errorf("error reading archive prelude: %v", err)
+		}
+		if doc == nil {
+			break
+		}
+		var md archiveMetadata
+		err = bson.Unmarshal(doc, &md)
+		if err != nil {
+			return fmt.Errorf("error reading archive prelude: %v", err)
+		}
+		c, err := parseMetadata([]byte(md.Metadata), md.Database, md.Collection)
+		if err != nil {
+			return err
+		}
+		collections[c.Namespace()] = c
+		err = h.Collection(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		doc, err = readDoc(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil || doc == nil {
+			return fmt.Errorf("error reading archive: expected a namespace header: %v", err)
+		}
+		var nh namespaceHeader
+		err = bson.Unmarshal(doc, &nh)
+		if err != nil {
+			return fmt.Errorf("error reading archive namespace header: %v", err)
+		}
+		c := collections[nh.Database+"."+nh.Collection]
+		if c == nil && nh.Database != "" {
+			c = &Collection{Database: nh.Database, Name: nh.Collection}
+			collections[c.Namespace()] = c
+			err = h.Collection(c)
+			if err != nil {
+				return err
+			}
+		}
+		for {
+			doc, err = readDoc(br)
+			if err == io.EOF {
+				err = fmt.Errorf("missing terminator")
+			}
+			if err != nil {
+				return fmt.Errorf("error reading %s.%s from archive: %v", nh.Database, nh.Collection, err)
+			}
+			if doc == nil {
+				break
+			}
+			if c == nil {
+				continue // the oplog
+			}
+			err = h.Document(c, doc)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+A mongodump directory, with .gz added to every file name by --gzip:
+	<dir>/<db>/<collection>.bson	the collection's documents, one after another
+	<dir>/<db>/<collection>.metadata.json	its options and indexes; views have only this file
+	<dir>/oplog.bson	the oplog, with --oplog
+*/
+
+const bsonExt = ".bson"
+const metadataExt = ".metadata.json"
+
+// ReadDir reads a mongodump directory, one database's directory within it, or a single .bson file, passing its
+// collections and documents to h
+func ReadDir(path string, h Handler) error {
+	path = filepath.Clean(path) // as Walk passes the files in it, e.g. without a trailing slash
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		db, name := filepath.Base(filepath.Dir(path)), trimExt(filepath.Base(path), bsonExt)
+		return readCollection(filepath.Dir(path), db, name, h)
+	}
+
+	// collections by directory and name, from both data and metadata files
+	type source struct{ dir, db, name string }
+	found := make(map[source]bool)
+	err = filepath.Walk(path, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		dir, base := filepath.Dir(fn), fi.Name()
+		if dir == path && trimExt(base, bsonExt) == "oplog" {
+			return nil
+		}
+		name := trimExt(base, bsonExt)
+		if name == base {
+			name = trimExt(base, metadataExt)
+		}
+		if name != base {
+			found[source{dir, filepath.Base(dir), name}] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no .bson or .metadata.json files in %s", path)
+	}
+	sources := make([]source, 0, len(found))
+	for s := range found {
+		sources = append(sources, s)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].db+"."+sources[i].name < sources[j].db+"."+sources[j].name
+	})
+	for _, s := range sources {
+		err = readCollection(s.dir, s.db, s.name, h)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read one collection's metadata and documents from a dump directory
+func readCollection(dir string, db string, name string, h Handler) error {
+	metadata, err := readMaybeGzipped(filepath.Join(dir, name+metadataExt))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	c, err := parseMetadata(metadata, db, name)
+	if err != nil {
+		return err
+	}
+	err = h.Collection(c)
+	if err != nil {
+		return err
+	}
+	in, err := openMaybeGzipped(filepath.Join(dir, name+bsonExt))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	r := bufio.NewReaderSize(in, 1024*1024)
+	for {
+		doc, err := readDoc(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil && doc == nil {
+			err = fmt.Errorf("unexpected terminator")
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", c.Namespace(), err)
+		}
+		err = h.Document(c, doc)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// open fn, or fn.gz decompressing it
+func openMaybeGzipped(fn string) (io.ReadCloser, error) {
+	f, err := os.Open(fn)
+	if err == nil || !os.IsNotExist(err) {
+		return f, err
+	}
+	f, err = os.Open(fn + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s.gz: %v", fn, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+func readMaybeGzipped(fn string) ([]byte, error) {
+	in, err := openMaybeGzipped(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = in.Close() }()
+	return ioutil.ReadAll(in)
+}
+
+// remove ext, or ext followed by .gz, from the end of a file name; the name is unchanged if it has neither
+func trimExt(name string, ext string) string {
+	if strings.HasSuffix(name, ext) {
+		return strings.TrimSuffix(name, ext)
+	}
+	if strings.HasSuffix(name, ext+".gz") {
+		return strings.TrimSuffix(name, ext+".gz")
+	}
+	return name
+}
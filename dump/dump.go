@@ -0,0 +1,102 @@
+// Package dump reads the output of mongodump, both archives and directories of BSON files, and of mongoexport,
+// without needing the database tools
+package dump
+
+import (
+	"encoding/binary"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+)
+
+// A Collection in a dump, with what mongodump recorded about it
+type Collection struct {
+	Database string
+	Name     string
+	Options  bson.D   // collection options, e.g. capped or validator; viewOn and pipeline for a view
+	Indexes  []bson.D // index specs other than the _id index, as given to createIndexes
+}
+
+// Namespace is the collection's full name, e.g. "shop.orders"
+func (c *Collection) Namespace() string {
+	return c.Database + "." + c.Name
+}
+
+// IsView reports whether the collection is a view, which has a definition but no documents
+func (c *Collection) IsView() bool {
+	for _, e := range c.Options {
+		if e.Key == "viewOn" {
+			return true
+		}
+	}
+	return false
+}
+
+// A Handler is given the collections read from a dump, each before any of its documents
+// Documents of different collections may be interleaved, as they are in archives.
+type Handler interface {
+	Collection(c *Collection) error
+	Document(c *Collection, doc bson.Raw) error
+}
+
+// parse the metadata mongodump writes for a collection: extended JSON with its options and indexes
+func parseMetadata(data []byte, db string, name string) (*Collection, error) {
+	c := &Collection{Database: db, Name: name}
+	if len(data) == 0 {
+		return c, nil
+	}
+	var md struct {
+		Options bson.D   `bson:"options"`
+		Indexes []bson.D `bson:"indexes"`
+	}
+	err := bson.UnmarshalExtJSON(data, false, &md)
+	if err != nil {
+		return nil, fmt.Errorf("error reading metadata for %s: %v", c.Namespace(), err)
+	}
+	c.Options = md.Options
+	for _, ix := range md.Indexes {
+		if ix.Map()["name"] == "_id_" {
+			continue
+		}
+		spec := make(bson.D, 0, len(ix))
+		for _, e := range ix {
+			if e.Key != "ns" { // createIndexes doesn't take the namespace, and rejects it from 4.4
+				spec = append(spec, e)
+			}
+		}
+		c.Indexes = append(c.Indexes, spec)
+	}
+	return c, nil
+}
+
+// marks the end of a run of documents in an archive
+const terminator = 0xffffffff
+
+// the largest document mongod accepts, with room for the overhead of an insert
+const maxDocSize = 16*1024*1024 + 16*1024
+
+// read the next BSON document, nil at a terminator; io.EOF only comes at the end of the input, between documents
+func readDoc(r io.Reader) (bson.Raw, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("truncated document")
+	}
+	if err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n == terminator {
+		return nil, nil
+	}
+	if n < 5 || n > maxDocSize {
+		return nil, fmt.Errorf("bad document size %d", n)
+	}
+	doc := make([]byte, n)
+	copy(doc, size[:])
+	_, err = io.ReadFull(r, doc[4:])
+	if err != nil {
+		return nil, fmt.Errorf("truncated document")
+	}
+	return doc, nil
+}
@@ -0,0 +1,170 @@
+package dump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"go.mongodb.org/mongo-driver/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ordersMetadata = `{"options":{"capped":true,"size":{"$numberLong":"1048576"}},"indexes":[` +
+	`{"v":{"$numberInt":"2"},"key":{"_id":{"$numberInt":"1"}},"name":"_id_","ns":"shop.orders"},` +
+	`{"v":{"$numberInt":"2"},"key":{"customerId":{"$numberInt":"1"}},"name":"customerId_1","ns":"shop.orders"}],` +
+	`"uuid":"6a3b1c0e0d6c4f0b9a6e2c5b8d0e1f2a","collectionName":"orders"}`
+
+// records what a reader passes on, as "ns" for a collection and "ns:_id" for a document
+type recorder struct {
+	events      []string
+	collections map[string]*Collection
+}
+
+func (r *recorder) Collection(c *Collection) error {
+	if r.collections == nil {
+		r.collections = make(map[string]*Collection)
+	}
+	r.collections[c.Namespace()] = c
+	r.events = append(r.events, c.Namespace())
+	return nil
+}
+
+func (r *recorder) Document(c *Collection, doc bson.Raw) error {
+	r.events = append(r.events, c.Namespace()+":"+doc.Lookup("_id").String())
+	return nil
+}
+
+func marshal(t *testing.T, doc interface{}) []byte {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func terminate(buf *bytes.Buffer) {
+	_ = binary.Write(buf, binary.LittleEndian, uint32(terminator))
+}
+
+func TestReadArchive(t *testing.T) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(archiveMagic))
+	buf.Write(marshal(t, bson.D{{"concurrent_collections", int32(4)}, {"version", "0.1"}, {"server_version", "4.4.1"}, {"tool_version", "100.1.1"}}))
+	buf.Write(marshal(t, bson.D{{"db", "shop"}, {"collection", "orders"}, {"metadata", ordersMetadata}, {"size", int32(0)}}))
+	buf.Write(marshal(t, bson.D{{"db", "shop"}, {"collection", "users"}, {"metadata", ""}, {"size", int32(0)}}))
+	terminate(&buf)
+	block := func(db, coll string, eof bool, ids ...int32) {
+		buf.Write(marshal(t, bson.D{{"db", db}, {"collection", coll}, {"EOF", eof}, {"CRC", int64(0)}}))
+		for _, id := range ids {
+			buf.Write(marshal(t, bson.D{{"_id", id}}))
+		}
+		terminate(&buf)
+	}
+	block("shop", "orders", false, 1, 2)
+	block("shop", "users", false, 10)
+	block("", "oplog", false, 99)
+	block("shop", "orders", false, 3)
+	block("shop", "orders", true)
+	block("shop", "users", true)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write(buf.Bytes())
+	_ = w.Close()
+
+	for name, in := range map[string][]byte{"plain": buf.Bytes(), "gzipped": gz.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			r := new(recorder)
+			err := ReadArchive(bytes.NewReader(in), r)
+			if err != nil {
+				t.Fatalf("ReadArchive(): %v", err)
+			}
+			want := `shop.orders,shop.users,shop.orders:{"$numberInt":"1"},shop.orders:{"$numberInt":"2"},` +
+				`shop.users:{"$numberInt":"10"},shop.orders:{"$numberInt":"3"}`
+			if got := strings.Join(r.events, ","); got != want {
+				t.Errorf("ReadArchive(): got %s, wanted %s", got, want)
+			}
+			orders := r.collections["shop.orders"]
+			if len(orders.Indexes) != 1 || !reflect.DeepEqual(orders.Indexes[0], bson.D{{"v", int32(2)}, {"key", bson.D{{"customerId", int32(1)}}}, {"name", "customerId_1"}}) {
+				t.Errorf("ReadArchive(): got indexes %v", orders.Indexes)
+			}
+			if !reflect.DeepEqual(orders.Options, bson.D{{"capped", true}, {"size", int64(1048576)}}) {
+				t.Errorf("ReadArchive(): got options %v", orders.Options)
+			}
+		})
+	}
+
+	r := new(recorder)
+	if err := ReadArchive(bytes.NewReader(buf.Bytes()[:buf.Len()-6]), r); err == nil {
+		t.Errorf("ReadArchive(): no error for a truncated archive")
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	shop := filepath.Join(dir, "shop")
+	_ = os.Mkdir(shop, 0755)
+	var docs bytes.Buffer
+	docs.Write(marshal(t, bson.D{{"_id", int32(1)}}))
+	docs.Write(marshal(t, bson.D{{"_id", int32(2)}}))
+	_ = ioutil.WriteFile(filepath.Join(shop, "orders.bson"), docs.Bytes(), 0644)
+	_ = ioutil.WriteFile(filepath.Join(shop, "orders.metadata.json"), []byte(ordersMetadata), 0644)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write(marshal(t, bson.D{{"_id", "a"}}))
+	_ = w.Close()
+	_ = ioutil.WriteFile(filepath.Join(shop, "users.bson.gz"), gz.Bytes(), 0644)
+	_ = ioutil.WriteFile(filepath.Join(shop, "recent.metadata.json"), []byte(`{"options":{"viewOn":"orders","pipeline":[]},"indexes":[]}`), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "oplog.bson"), marshal(t, bson.D{{"_id", int32(99)}}), 0644)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{dir, `shop.orders,shop.orders:{"$numberInt":"1"},shop.orders:{"$numberInt":"2"},shop.recent,shop.users,shop.users:"a"`},
+		{dir + string(filepath.Separator), `shop.orders,shop.orders:{"$numberInt":"1"},shop.orders:{"$numberInt":"2"},shop.recent,shop.users,shop.users:"a"`},
+		{shop, `shop.orders,shop.orders:{"$numberInt":"1"},shop.orders:{"$numberInt":"2"},shop.recent,shop.users,shop.users:"a"`},
+		{filepath.Join(shop, "users.bson.gz"), `shop.users,shop.users:"a"`},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			r := new(recorder)
+			err := ReadDir(tt.path, r)
+			if err != nil {
+				t.Fatalf("ReadDir(): %v", err)
+			}
+			if got := strings.Join(r.events, ","); got != tt.want {
+				t.Errorf("ReadDir(): got %s, wanted %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"lines", `{"_id":{"$oid":"5f5a1b2c3d4e5f6a7b8c9d0e"},"n":1}` + "\n" + `{"_id":2,"when":{"$date":"2021-03-04T10:00:00Z"}}` + "\n",
+			`t.c,t.c:{"$oid":"5f5a1b2c3d4e5f6a7b8c9d0e"},t.c:{"$numberInt":"2"}`},
+		{"array", "[\n  {\"_id\": 1},\n  {\"_id\": {\"$numberLong\": \"2\"}}\n]\n", `t.c,t.c:{"$numberInt":"1"},t.c:{"$numberLong":"2"}`},
+		{"empty", "\n", "t.c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(recorder)
+			err := ReadJSON(strings.NewReader(tt.in), &Collection{Database: "t", Name: "c"}, r)
+			if err != nil {
+				t.Fatalf("ReadJSON(): %v", err)
+			}
+			if got := strings.Join(r.events, ","); got != tt.want {
+				t.Errorf("ReadJSON(): got %s, wanted %s", got, tt.want)
+			}
+		})
+	}
+}
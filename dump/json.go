@@ -0,0 +1,61 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"unicode"
+)
+
+// ReadJSON reads a mongoexport JSON file into collection c, passing c and then each document to h
+// Documents are in extended JSON, canonical or relaxed, either one after another (mongoexport's default) or in a
+// single array (--jsonArray).
+func ReadJSON(r io.Reader, c *Collection, h Handler) error {
+	err := h.Collection(c)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, 1024*1024)
+	array := false
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(rune(b)) {
+			array = b == '['
+			_ = br.UnreadByte()
+			break
+		}
+	}
+	dec := json.NewDecoder(br)
+	if array {
+		_, _ = dec.Token() // the [
+	}
+	for n := 1; dec.More(); n++ {
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return fmt.Errorf("document %d: %v", n, err)
+		}
+		var doc bson.D
+		err = bson.UnmarshalExtJSON(raw, false, &doc)
+		if err != nil {
+			return fmt.Errorf("document %d: %v", n, err)
+		}
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("document %d: %v", n, err)
+		}
+		err = h.Document(c, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
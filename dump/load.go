@@ -0,0 +1,138 @@
+package dump
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// A Loader is a Handler writing what it is given into a deployment, as mongorestore does
+// Collections are created with their options, dropped first if Drop is set, and documents inserted in unordered
+// batches; documents whose _id is already there are counted and skipped. Indexes are built by Finish, after the data.
+// System collections such as users and roles are skipped.
+type Loader struct {
+	Client    *mongo.Client
+	Drop      bool
+	BatchSize int
+
+	loaded []*Loaded
+	byNS   map[string]*Loaded
+}
+
+// What was Loaded into a collection
+type Loaded struct {
+	*Collection
+	Documents  int64
+	Duplicates int64  // documents skipped because their _id was already in the collection
+	Skipped    string // why the collection was skipped, "" if it wasn't
+	batch      []interface{}
+}
+
+// error codes for creating a collection that exists, and inserting a duplicate key
+const namespaceExists = 48
+const duplicateKey = 11000
+
+// NewLoader returns a loader writing through client, inserting batchSize documents at a time
+func NewLoader(client *mongo.Client, drop bool, batchSize int) *Loader {
+	return &Loader{Client: client, Drop: drop, BatchSize: batchSize, byNS: make(map[string]*Loaded)}
+}
+
+// Collection creates a collection with its options
+func (l *Loader) Collection(c *Collection) error {
+	ld := &Loaded{Collection: c}
+	l.loaded = append(l.loaded, ld)
+	l.byNS[c.Namespace()] = ld
+	if strings.HasPrefix(c.Name, "system.") {
+		ld.Skipped = "system collection"
+		return nil
+	}
+	ctx := context.Background()
+	db := l.Client.Database(c.Database)
+	if l.Drop {
+		err := db.Collection(c.Name).Drop(ctx)
+		if err != nil {
+			return fmt.Errorf("error dropping %s: %v", c.Namespace(), err)
+		}
+	}
+	if len(c.Options) == 0 {
+		return nil // created by the first insert
+	}
+	err := db.RunCommand(ctx, append(bson.D{{"create", c.Name}}, c.Options...)).Err()
+	if ce, ok := err.(mongo.CommandError); ok && ce.Code == namespaceExists {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", c.Namespace(), err)
+	}
+	return nil
+}
+
+// Document adds a document to its collection's next batch, inserting the batch when it is full
+func (l *Loader) Document(c *Collection, doc bson.Raw) error {
+	ld := l.byNS[c.Namespace()]
+	if ld.Skipped != "" {
+		return nil
+	}
+	ld.batch = append(ld.batch, doc)
+	if len(ld.batch) >= l.BatchSize {
+		return l.flush(ld)
+	}
+	return nil
+}
+
+func (l *Loader) flush(ld *Loaded) error {
+	if len(ld.batch) == 0 {
+		return nil
+	}
+	coll := l.Client.Database(ld.Database).Collection(ld.Name)
+	_, err := coll.InsertMany(context.Background(), ld.batch, options.InsertMany().SetOrdered(false))
+	n := int64(len(ld.batch))
+	ld.batch = ld.batch[:0]
+	if bwe, ok := err.(mongo.BulkWriteException); ok && bwe.WriteConcernError == nil {
+		dups := int64(0)
+		for _, we := range bwe.WriteErrors {
+			if we.Code == duplicateKey {
+				dups++
+			}
+		}
+		if dups == int64(len(bwe.WriteErrors)) {
+			ld.Duplicates += dups
+			n -= dups
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error inserting into %s: %v", ld.Namespace(), err)
+	}
+	ld.Documents += n
+	return nil
+}
+
+// Finish inserts the last batches and builds each collection's indexes, returning what was loaded
+func (l *Loader) Finish() ([]*Loaded, error) {
+	for _, ld := range l.loaded {
+		if ld.Skipped != "" {
+			continue
+		}
+		err := l.flush(ld)
+		if err != nil {
+			return l.loaded, err
+		}
+		if len(ld.Indexes) == 0 || ld.IsView() {
+			continue
+		}
+		indexes := make(bson.A, len(ld.Indexes))
+		for i, ix := range ld.Indexes {
+			indexes[i] = ix
+		}
+		cmd := bson.D{{"createIndexes", ld.Name}, {"indexes", indexes}}
+		err = l.Client.Database(ld.Database).RunCommand(context.Background(), cmd).Err()
+		if err != nil {
+			return l.loaded, fmt.Errorf("error creating indexes on %s: %v", ld.Namespace(), err)
+		}
+	}
+	return l.loaded, nil
+}
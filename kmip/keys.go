@@ -0,0 +1,137 @@
+package kmip
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Key states (KMIP 1.2 section 3.22)
+const (
+	StatePreActive   = 1
+	StateActive      = 2
+	StateDeactivated = 3
+	StateDestroyed   = 5
+)
+
+var stateNames = map[int]string{StatePreActive: "Pre-Active", StateActive: "Active", StateDeactivated: "Deactivated", StateDestroyed: "Destroyed"}
+
+// A Key is a symmetric AES key managed by the server
+type Key struct {
+	ID       string
+	Material []byte `json:",omitempty"` // removed when the key is destroyed
+	Bits     int
+	State    int
+	Created  time.Time
+}
+
+// StateName is the key's state as KMIP names it, e.g. "Active"
+func (k *Key) StateName() string {
+	return stateNames[k.State]
+}
+
+// A KeyStore holds the server's keys, saved to a file after every change so they outlive the server
+// mongod can't read its data files without its master key, so losing the file loses the deployment's data.
+type KeyStore struct {
+	File string // "" to keep the keys in memory only
+	Keys []*Key
+
+	mu sync.Mutex
+}
+
+// OpenKeyStore loads the keys saved in fn, or starts with none if it doesn't exist
+func OpenKeyStore(fn string) (*KeyStore, error) {
+	ks := &KeyStore{File: fn}
+	in, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading keys: %v", err)
+	}
+	err = json.Unmarshal(in, &ks.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keys in %s: %v", fn, err)
+	}
+	return ks, nil
+}
+
+func (ks *KeyStore) save() error {
+	if ks.File == "" {
+		return nil
+	}
+	out, err := json.MarshalIndent(ks.Keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(ks.File, out, 0600)
+	if err != nil {
+		return fmt.Errorf("error saving keys: %v", err)
+	}
+	return nil
+}
+
+// Create a new pre-active key with a random value, identified by the next unused number
+func (ks *KeyStore) Create(bits int) (*Key, error) {
+	if bits != 128 && bits != 192 && bits != 256 {
+		return nil, fmt.Errorf("AES keys are 128, 192 or 256 bits, not %d", bits)
+	}
+	material := make([]byte, bits/8)
+	_, err := rand.Read(material)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key: %v", err)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k := &Key{ID: strconv.Itoa(len(ks.Keys) + 1), Material: material, Bits: bits, State: StatePreActive, Created: time.Now().UTC()}
+	ks.Keys = append(ks.Keys, k)
+	c := *k
+	return &c, ks.save()
+}
+
+// Get a copy of the key with an identifier, nil if there is none
+func (ks *KeyStore) Get(id string) *Key {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, k := range ks.Keys {
+		if k.ID == id {
+			c := *k
+			return &c
+		}
+	}
+	return nil
+}
+
+// SetState moves a key to another state, destroying its value if the new state is StateDestroyed
+func (ks *KeyStore) SetState(id string, state int) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, k := range ks.Keys {
+		if k.ID == id {
+			k.State = state
+			if state == StateDestroyed {
+				k.Material = nil
+			}
+			return ks.save()
+		}
+	}
+	return fmt.Errorf("no key %s", id)
+}
+
+// IDs lists the identifiers of the keys that haven't been destroyed
+func (ks *KeyStore) IDs() []string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	var ids []string
+	for _, k := range ks.Keys {
+		if k.State != StateDestroyed {
+			ids = append(ids, k.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,369 @@
+// Package kmip is a minimal in-process KMIP server, standing in for a customer's key manager when reproducing
+// problems with MongoDB Enterprise's encryption at rest and master key rotation
+package kmip
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+/*
+Supported operations: Create (AES keys), Get, Get Attributes, Activate, Revoke, Destroy, Locate (every live key),
+Query, Discover Versions, and Encrypt and Decrypt with AES-CBC and PKCS#5 padding.
+Keys are returned in Raw format. Clients are trusted once their certificate is verified; nothing is access controlled.
+*/
+
+// Operations
+const (
+	opCreate           = 0x01
+	opLocate           = 0x08
+	opGet              = 0x0A
+	opGetAttributes    = 0x0B
+	opActivate         = 0x12
+	opRevoke           = 0x13
+	opDestroy          = 0x14
+	opQuery            = 0x18
+	opDiscoverVersions = 0x1E
+	opEncrypt          = 0x1F
+	opDecrypt          = 0x20
+)
+
+var opNames = map[int]string{opCreate: "Create", opLocate: "Locate", opGet: "Get", opGetAttributes: "Get Attributes",
+	opActivate: "Activate", opRevoke: "Revoke", opDestroy: "Destroy", opQuery: "Query",
+	opDiscoverVersions: "Discover Versions", opEncrypt: "Encrypt", opDecrypt: "Decrypt"}
+
+// Result reasons
+const (
+	reasonItemNotFound          = 0x01
+	reasonOperationNotSupported = 0x05
+	reasonMissingData           = 0x06
+	reasonInvalidField          = 0x07
+	reasonFeatureNotSupported   = 0x08
+	reasonCryptographicFailure  = 0x0A
+	reasonIllegalOperation      = 0x0B
+)
+
+// Enumeration values
+const (
+	objectSymmetricKey  = 0x02
+	algorithmAES        = 0x03
+	formatRaw           = 0x01
+	modeCBC             = 0x01
+	paddingNone         = 0x01
+	paddingPKCS5        = 0x03
+	usageEncryptDecrypt = 0x04 | 0x08
+)
+
+// protocol versions answered to Discover Versions, newest first
+var protocolVersions = [][2]int{{1, 2}, {1, 1}, {1, 0}}
+
+// A Server answers KMIP requests from mongod, keeping its keys in a KeyStore
+type Server struct {
+	Keys *KeyStore
+	Log  *log.Logger // every operation and its result, if not nil
+}
+
+// ListenAndServe serves KMIP over TLS on a TCP address such as "localhost:5696" until the listener fails
+// tlsConfig should require and verify client certificates, as KMIP servers do.
+func (s *Server) ListenAndServe(addr string, tlsConfig *tls.Config) error {
+	l, err := tls.Listen("tcp", addr, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", addr, err)
+	}
+	return s.Serve(l)
+}
+
+// Serve KMIP on connections from a listener
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log.Printf(format, args...)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	client := conn.RemoteAddr().String()
+	if tc, ok := conn.(*tls.Conn); ok {
+		err := tc.Handshake()
+		if err != nil {
+			s.logf("%s: TLS handshake failed: %v", client, err)
+			return
+		}
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			client += " (" + certs[0].Subject.String() + ")"
+		}
+	}
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readItem(r)
+		if err != nil {
+			if err != io.EOF {
+				s.logf("%s: %v", client, err)
+			}
+			return
+		}
+		if msg.tag != tagRequestMessage {
+			s.logf("%s: not a request message", client)
+			return
+		}
+		_, err = conn.Write(s.respond(client, msg))
+		if err != nil {
+			s.logf("%s: %v", client, err)
+			return
+		}
+	}
+}
+
+// the response to a request message, answering its batch items in order
+func (s *Server) respond(client string, msg *item) []byte {
+	// answer in the version the client asked for
+	version := msg.child(tagRequestHeader).child(tagProtocolVersion)
+	major, minor := version.child(tagProtocolVersionMajor).Int(), version.child(tagProtocolVersionMinor).Int()
+	var items [][]byte
+	for _, bi := range msg.children {
+		if bi.tag != tagBatchItem {
+			continue
+		}
+		op := bi.child(tagOperation).Int()
+		payload, reason, err := s.operation(op, bi.child(tagRequestPayload))
+		name := opNames[op]
+		if name == "" {
+			name = fmt.Sprintf("operation 0x%02x", op)
+		}
+		fields := [][]byte{encodeEnum(tagOperation, op)}
+		if id := bi.child(tagUniqueBatchItemID); id != nil {
+			fields = append(fields, encodeBytes(tagUniqueBatchItemID, id.Bytes()))
+		}
+		if err != nil {
+			s.logf("%s: %s failed: %v", client, name, err)
+			fields = append(fields, encodeEnum(tagResultStatus, 1), encodeEnum(tagResultReason, reason), encodeText(tagResultMessage, err.Error()))
+		} else {
+			encoded := encodeStruct(tagResponsePayload, payload...)
+			s.logf("%s: %s", client, describe(name, encoded))
+			fields = append(fields, encodeEnum(tagResultStatus, 0), encoded)
+		}
+		items = append(items, encodeStruct(tagBatchItem, fields...))
+	}
+	header := encodeStruct(tagResponseHeader,
+		encodeStruct(tagProtocolVersion, encodeInt(tagProtocolVersionMajor, major), encodeInt(tagProtocolVersionMinor, minor)),
+		encodeTime(tagTimeStamp, time.Now()),
+		encodeInt(tagBatchCount, len(items)))
+	return encodeStruct(tagResponseMessage, append([][]byte{header}, items...)...)
+}
+
+// an operation and the key it acted on, for the log
+func describe(name string, payload []byte) string {
+	it, err := parseItem(payload[:8], payload[8:])
+	if id := it.child(tagUniqueIdentifier); err == nil && id != nil {
+		return name + " key " + id.String()
+	}
+	return name
+}
+
+// perform an operation, returning the fields of its response payload, or the reason it failed
+func (s *Server) operation(op int, req *item) ([][]byte, int, error) {
+	if _, ok := opNames[op]; !ok {
+		return nil, reasonOperationNotSupported, fmt.Errorf("not supported")
+	}
+	switch op {
+	case opDiscoverVersions:
+		var versions [][]byte
+		for _, v := range protocolVersions {
+			versions = append(versions, encodeStruct(tagProtocolVersion, encodeInt(tagProtocolVersionMajor, v[0]), encodeInt(tagProtocolVersionMinor, v[1])))
+		}
+		return versions, 0, nil
+	case opQuery:
+		var fields [][]byte
+		for _, o := range []int{opCreate, opLocate, opGet, opGetAttributes, opActivate, opRevoke, opDestroy, opQuery, opDiscoverVersions, opEncrypt, opDecrypt} {
+			fields = append(fields, encodeEnum(tagOperation, o))
+		}
+		return append(fields, encodeEnum(tagObjectType, objectSymmetricKey)), 0, nil
+	case opCreate:
+		return s.create(req)
+	case opLocate:
+		var fields [][]byte
+		for _, id := range s.Keys.IDs() {
+			fields = append(fields, encodeText(tagUniqueIdentifier, id))
+		}
+		return fields, 0, nil
+	}
+
+	id := req.child(tagUniqueIdentifier)
+	if id == nil {
+		return nil, reasonMissingData, fmt.Errorf("no unique identifier")
+	}
+	k := s.Keys.Get(id.String())
+	if k == nil {
+		return nil, reasonItemNotFound, fmt.Errorf("no key %s", id.String())
+	}
+	idField := encodeText(tagUniqueIdentifier, k.ID)
+	switch op {
+	case opGet:
+		if k.State == StateDestroyed {
+			return nil, reasonIllegalOperation, fmt.Errorf("key %s has been destroyed", k.ID)
+		}
+		block := encodeStruct(tagKeyBlock,
+			encodeEnum(tagKeyFormatType, formatRaw),
+			encodeStruct(tagKeyValue, encodeBytes(tagKeyMaterial, k.Material)),
+			encodeEnum(tagCryptographicAlgorithm, algorithmAES),
+			encodeInt(tagCryptographicLength, k.Bits))
+		return [][]byte{encodeEnum(tagObjectType, objectSymmetricKey), idField, encodeStruct(tagSymmetricKey, block)}, 0, nil
+	case opGetAttributes:
+		return append([][]byte{idField}, attributes(k, req)...), 0, nil
+	case opActivate, opRevoke, opDestroy:
+		state := map[int]int{opActivate: StateActive, opRevoke: StateDeactivated, opDestroy: StateDestroyed}[op]
+		if op == opActivate && k.State != StatePreActive {
+			return nil, reasonIllegalOperation, fmt.Errorf("key %s is %s, only pre-active keys can be activated", k.ID, k.StateName())
+		}
+		err := s.Keys.SetState(k.ID, state)
+		if err != nil {
+			return nil, reasonIllegalOperation, err
+		}
+		return [][]byte{idField}, 0, nil
+	case opEncrypt, opDecrypt:
+		return s.crypt(op, k, req)
+	}
+	return nil, reasonOperationNotSupported, fmt.Errorf("not supported")
+}
+
+func (s *Server) create(req *item) ([][]byte, int, error) {
+	if t := req.child(tagObjectType).Int(); t != objectSymmetricKey {
+		return nil, reasonFeatureNotSupported, fmt.Errorf("only symmetric keys can be created, not object type %d", t)
+	}
+	bits := 256
+	for _, a := range req.child(tagTemplateAttribute).children {
+		if a.tag != tagAttribute {
+			continue
+		}
+		value := a.child(tagAttributeValue).Int()
+		switch a.child(tagAttributeName).String() {
+		case "Cryptographic Algorithm":
+			if value != algorithmAES {
+				return nil, reasonFeatureNotSupported, fmt.Errorf("only AES keys can be created, not algorithm %d", value)
+			}
+		case "Cryptographic Length":
+			bits = value
+		}
+	}
+	k, err := s.Keys.Create(bits)
+	if err != nil {
+		return nil, reasonInvalidField, err
+	}
+	return [][]byte{encodeEnum(tagObjectType, objectSymmetricKey), encodeText(tagUniqueIdentifier, k.ID)}, 0, nil
+}
+
+// a key's attributes named in a Get Attributes request, all of them if none are named
+func attributes(k *Key, req *item) [][]byte {
+	all := map[string][]byte{
+		"Unique Identifier":        encodeText(tagAttributeValue, k.ID),
+		"Object Type":              encodeEnum(tagAttributeValue, objectSymmetricKey),
+		"Cryptographic Algorithm":  encodeEnum(tagAttributeValue, algorithmAES),
+		"Cryptographic Length":     encodeInt(tagAttributeValue, k.Bits),
+		"Cryptographic Usage Mask": encodeInt(tagAttributeValue, usageEncryptDecrypt),
+		"State":                    encodeEnum(tagAttributeValue, k.State),
+		"Initial Date":             encodeTime(tagAttributeValue, k.Created),
+	}
+	var names []string
+	for _, c := range req.children {
+		if c.tag == tagAttributeName {
+			names = append(names, c.String())
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"Unique Identifier", "Object Type", "Cryptographic Algorithm", "Cryptographic Length",
+			"Cryptographic Usage Mask", "State", "Initial Date"}
+	}
+	var fields [][]byte
+	for _, name := range names {
+		if value, ok := all[name]; ok {
+			fields = append(fields, encodeStruct(tagAttribute, encodeText(tagAttributeName, name), value))
+		}
+	}
+	return fields
+}
+
+// encrypt or decrypt data with a key, using AES-CBC with PKCS#5 padding unless the request asks for no padding
+func (s *Server) crypt(op int, k *Key, req *item) ([][]byte, int, error) {
+	if k.State != StateActive && !(op == opDecrypt && k.State == StateDeactivated) {
+		return nil, reasonIllegalOperation, fmt.Errorf("key %s is %s", k.ID, k.StateName())
+	}
+	params := req.child(tagCryptographicParams)
+	if m := params.child(tagBlockCipherMode); m != nil && m.Int() != modeCBC {
+		return nil, reasonFeatureNotSupported, fmt.Errorf("only CBC mode is supported, not block cipher mode %d", m.Int())
+	}
+	pad := params.child(tagPaddingMethod) == nil || params.child(tagPaddingMethod).Int() == paddingPKCS5
+	if p := params.child(tagPaddingMethod); p != nil && p.Int() != paddingPKCS5 && p.Int() != paddingNone {
+		return nil, reasonFeatureNotSupported, fmt.Errorf("only PKCS5 or no padding is supported, not padding method %d", p.Int())
+	}
+	data := append([]byte(nil), req.child(tagData).Bytes()...)
+	block, err := aes.NewCipher(k.Material)
+	if err != nil {
+		return nil, reasonCryptographicFailure, err
+	}
+	fields := [][]byte{encodeText(tagUniqueIdentifier, k.ID)}
+	iv := req.child(tagIVCounterNonce).Bytes()
+	if iv == nil {
+		if op == opDecrypt {
+			return nil, reasonMissingData, fmt.Errorf("no IV to decrypt with")
+		}
+		iv = make([]byte, aes.BlockSize)
+		_, err = rand.Read(iv)
+		if err != nil {
+			return nil, reasonCryptographicFailure, err
+		}
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, reasonInvalidField, fmt.Errorf("IV must be %d bytes", aes.BlockSize)
+	}
+
+	if op == opEncrypt {
+		if pad {
+			n := aes.BlockSize - len(data)%aes.BlockSize
+			for i := 0; i < n; i++ {
+				data = append(data, byte(n))
+			}
+		}
+		if len(data)%aes.BlockSize != 0 {
+			return nil, reasonInvalidField, fmt.Errorf("data must be a multiple of %d bytes without padding", aes.BlockSize)
+		}
+		out := make([]byte, len(data))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+		fields = append(fields, encodeBytes(tagData, out))
+		if req.child(tagIVCounterNonce) == nil {
+			fields = append(fields, encodeBytes(tagIVCounterNonce, iv))
+		}
+		return fields, 0, nil
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, reasonCryptographicFailure, fmt.Errorf("ciphertext must be a multiple of %d bytes", aes.BlockSize)
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	if pad {
+		n := int(out[len(out)-1])
+		if n == 0 || n > aes.BlockSize {
+			return nil, reasonCryptographicFailure, fmt.Errorf("bad padding, wrong key?")
+		}
+		out = out[:len(out)-n]
+	}
+	return append(fields, encodeBytes(tagData, out)), 0, nil
+}
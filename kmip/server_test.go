@@ -0,0 +1,124 @@
+package kmip
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// a minimal client sending one operation per request, as mongod does
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// send an operation, returning the response's result status and reason and its payload
+func (c *testClient) do(t *testing.T, op int, payload ...[]byte) (int, int, *item) {
+	header := encodeStruct(tagRequestHeader,
+		encodeStruct(tagProtocolVersion, encodeInt(tagProtocolVersionMajor, 1), encodeInt(tagProtocolVersionMinor, 0)),
+		encodeInt(tagBatchCount, 1))
+	msg := encodeStruct(tagRequestMessage, header,
+		encodeStruct(tagBatchItem, encodeEnum(tagOperation, op), encodeStruct(tagRequestPayload, payload...)))
+	_, err := c.conn.Write(msg)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	resp, err := readItem(c.r)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if resp.tag != tagResponseMessage {
+		t.Fatalf("got tag 0x%06x, wanted a response message", resp.tag)
+	}
+	bi := resp.child(tagBatchItem)
+	if got := bi.child(tagOperation).Int(); got != op {
+		t.Fatalf("got response to operation 0x%02x, wanted 0x%02x", got, op)
+	}
+	return bi.child(tagResultStatus).Int(), bi.child(tagResultReason).Int(), bi.child(tagResponsePayload)
+}
+
+func TestServer(t *testing.T) {
+	keys, err := OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	go func() { _ = (&Server{Keys: keys}).Serve(l) }()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	c := &testClient{conn: conn, r: bufio.NewReader(conn)}
+
+	attr := func(name string, value []byte) []byte {
+		return encodeStruct(tagAttribute, encodeText(tagAttributeName, name), value)
+	}
+	status, _, payload := c.do(t, opCreate, encodeEnum(tagObjectType, objectSymmetricKey), encodeStruct(tagTemplateAttribute,
+		attr("Cryptographic Algorithm", encodeEnum(tagAttributeValue, algorithmAES)),
+		attr("Cryptographic Length", encodeInt(tagAttributeValue, 256))))
+	if status != 0 {
+		t.Fatalf("Create: got status %d", status)
+	}
+	id := payload.child(tagUniqueIdentifier).String()
+	idField := encodeText(tagUniqueIdentifier, id)
+
+	status, _, payload = c.do(t, opGet, idField)
+	material := payload.child(tagSymmetricKey).child(tagKeyBlock).child(tagKeyValue).child(tagKeyMaterial).Bytes()
+	if status != 0 || len(material) != 32 {
+		t.Fatalf("Get: got status %d and a %d-byte key", status, len(material))
+	}
+
+	state := func() int {
+		_, _, payload := c.do(t, opGetAttributes, idField, encodeText(tagAttributeName, "State"))
+		return payload.child(tagAttribute).child(tagAttributeValue).Int()
+	}
+	if got := state(); got != StatePreActive {
+		t.Errorf("state after Create: got %d, wanted %d", got, StatePreActive)
+	}
+	if status, _, _ = c.do(t, opActivate, idField); status != 0 || state() != StateActive {
+		t.Errorf("Activate: got status %d and state %d", status, state())
+	}
+	if _, reason, _ := c.do(t, opActivate, idField); reason != reasonIllegalOperation {
+		t.Errorf("second Activate: got reason %d, wanted %d", reason, reasonIllegalOperation)
+	}
+
+	plain := []byte("the database keystore key")
+	status, _, payload = c.do(t, opEncrypt, idField, encodeBytes(tagData, plain))
+	if status != 0 {
+		t.Fatalf("Encrypt: got status %d", status)
+	}
+	iv := payload.child(tagIVCounterNonce).Bytes()
+	status, _, payload = c.do(t, opDecrypt, idField, encodeBytes(tagData, payload.child(tagData).Bytes()), encodeBytes(tagIVCounterNonce, iv))
+	if status != 0 || !bytes.Equal(payload.child(tagData).Bytes(), plain) {
+		t.Errorf("Decrypt: got status %d and %q, wanted %q", status, payload.child(tagData).Bytes(), plain)
+	}
+
+	if status, _, _ = c.do(t, opRevoke, idField); status != 0 || state() != StateDeactivated {
+		t.Errorf("Revoke: got status %d and state %d", status, state())
+	}
+	if _, reason, _ := c.do(t, opEncrypt, idField, encodeBytes(tagData, plain)); reason != reasonIllegalOperation {
+		t.Errorf("Encrypt with deactivated key: got reason %d, wanted %d", reason, reasonIllegalOperation)
+	}
+	if _, reason, _ := c.do(t, opGet, encodeText(tagUniqueIdentifier, "99")); reason != reasonItemNotFound {
+		t.Errorf("Get of missing key: got reason %d, wanted %d", reason, reasonItemNotFound)
+	}
+	if _, reason, _ := c.do(t, 0x04); reason != reasonOperationNotSupported {
+		t.Errorf("Re-key: got reason %d, wanted %d", reason, reasonOperationNotSupported)
+	}
+
+	// keys outlive the server
+	reopened, err := OpenKeyStore(keys.File)
+	if err != nil {
+		t.Fatalf("OpenKeyStore(): %v", err)
+	}
+	if k := reopened.Get(id); k == nil || !bytes.Equal(k.Material, material) || k.State != StateDeactivated {
+		t.Errorf("OpenKeyStore(): got key %+v, wanted the deactivated key created above", k)
+	}
+}
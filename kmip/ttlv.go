@@ -0,0 +1,201 @@
+package kmip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// KMIP messages are TTLV encoded: a 3-byte tag, a 1-byte type, a 4-byte length and the value, padded to 8 bytes
+
+// Item types
+const (
+	typeStructure   = 0x01
+	typeInteger     = 0x02
+	typeEnumeration = 0x05
+	typeTextString  = 0x07
+	typeByteString  = 0x08
+	typeDateTime    = 0x09
+)
+
+// Tags
+const (
+	tagAttribute              = 0x420008
+	tagAttributeName          = 0x42000A
+	tagAttributeValue         = 0x42000B
+	tagBatchCount             = 0x42000D
+	tagBatchItem              = 0x42000F
+	tagBlockCipherMode        = 0x420011
+	tagCryptographicAlgorithm = 0x420028
+	tagCryptographicLength    = 0x42002A
+	tagCryptographicParams    = 0x42002B
+	tagData                   = 0x4200C2
+	tagIVCounterNonce         = 0x42003D
+	tagKeyBlock               = 0x420040
+	tagKeyFormatType          = 0x420042
+	tagKeyMaterial            = 0x420043
+	tagKeyValue               = 0x420045
+	tagObjectType             = 0x420057
+	tagOperation              = 0x42005C
+	tagPaddingMethod          = 0x42005F
+	tagProtocolVersion        = 0x420069
+	tagProtocolVersionMajor   = 0x42006A
+	tagProtocolVersionMinor   = 0x42006B
+	tagRequestHeader          = 0x420077
+	tagRequestMessage         = 0x420078
+	tagRequestPayload         = 0x420079
+	tagResponseHeader         = 0x42007A
+	tagResponseMessage        = 0x42007B
+	tagResponsePayload        = 0x42007C
+	tagResultMessage          = 0x42007D
+	tagResultReason           = 0x42007E
+	tagResultStatus           = 0x42007F
+	tagSymmetricKey           = 0x42008F
+	tagTemplateAttribute      = 0x420091
+	tagTimeStamp              = 0x420092
+	tagUniqueBatchItemID      = 0x420093
+	tagUniqueIdentifier       = 0x420094
+)
+
+// largest message accepted, to stop a bad length from allocating without limit
+const maxMessage = 1 << 20
+
+// An item is one TTLV element; structures are parsed into their children
+type item struct {
+	tag      int
+	typ      byte
+	value    []byte
+	children []*item
+}
+
+// read one message from r
+func readItem(r io.Reader) (*item, error) {
+	header := make([]byte, 8)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(header[4:]))
+	if length > maxMessage {
+		return nil, fmt.Errorf("TTLV item of %d bytes is too large", length)
+	}
+	value := make([]byte, padded(length))
+	_, err = io.ReadFull(r, value)
+	if err != nil {
+		return nil, err
+	}
+	return parseItem(header, value[:length])
+}
+
+func padded(n int) int {
+	return (n + 7) / 8 * 8
+}
+
+// parse an item's value, and its children if it is a structure
+func parseItem(header []byte, value []byte) (*item, error) {
+	it := &item{tag: int(header[0])<<16 | int(header[1])<<8 | int(header[2]), typ: header[3], value: value}
+	if it.typ != typeStructure {
+		return it, nil
+	}
+	for len(value) > 0 {
+		if len(value) < 8 {
+			return nil, fmt.Errorf("truncated TTLV item")
+		}
+		length := int(binary.BigEndian.Uint32(value[4:8]))
+		if len(value) < 8+length {
+			return nil, fmt.Errorf("truncated TTLV item")
+		}
+		child, err := parseItem(value[:8], value[8:8+length])
+		if err != nil {
+			return nil, err
+		}
+		it.children = append(it.children, child)
+		skip := 8 + padded(length)
+		if skip > len(value) {
+			skip = len(value)
+		}
+		value = value[skip:]
+	}
+	return it, nil
+}
+
+// the first child with a tag, nil if there is none
+func (it *item) child(tag int) *item {
+	if it == nil {
+		return nil
+	}
+	for _, c := range it.children {
+		if c.tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// Int is the value of an integer or enumeration, 0 if the item is missing
+func (it *item) Int() int {
+	if it == nil || len(it.value) < 4 {
+		return 0
+	}
+	return int(int32(binary.BigEndian.Uint32(it.value)))
+}
+
+// String is the value of a text string, "" if the item is missing
+func (it *item) String() string {
+	if it == nil {
+		return ""
+	}
+	return string(it.value)
+}
+
+// Bytes is the value of a byte string, nil if the item is missing
+func (it *item) Bytes() []byte {
+	if it == nil {
+		return nil
+	}
+	return it.value
+}
+
+// encode an item with the given value
+func encode(tag int, typ byte, value []byte) []byte {
+	out := make([]byte, 8, 8+padded(len(value)))
+	out[0], out[1], out[2], out[3] = byte(tag>>16), byte(tag>>8), byte(tag), typ
+	binary.BigEndian.PutUint32(out[4:], uint32(len(value)))
+	out = append(out, value...)
+	return append(out, make([]byte, padded(len(value))-len(value))...)
+}
+
+func encodeStruct(tag int, children ...[]byte) []byte {
+	var value []byte
+	for _, c := range children {
+		value = append(value, c...)
+	}
+	return encode(tag, typeStructure, value)
+}
+
+func encodeInt(tag int, n int) []byte {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(n))
+	return encode(tag, typeInteger, value)
+}
+
+func encodeEnum(tag int, n int) []byte {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(n))
+	return encode(tag, typeEnumeration, value)
+}
+
+func encodeText(tag int, s string) []byte {
+	return encode(tag, typeTextString, []byte(s))
+}
+
+func encodeBytes(tag int, b []byte) []byte {
+	return encode(tag, typeByteString, b)
+}
+
+func encodeTime(tag int, t time.Time) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(t.Unix()))
+	return encode(tag, typeDateTime, value)
+}
@@ -0,0 +1,187 @@
+package ldap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// The subset of ASN.1 BER that LDAP messages use: definite lengths and single-byte tags
+
+// BER tags
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	classContext     = 0x80
+	classApplication = 0x40
+	constructed      = 0x20
+)
+
+// largest message accepted, to stop a bad length from allocating without limit
+const maxPacket = 1 << 20
+
+// A packet is one BER element; constructed elements are parsed into their children
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+// read one element from r
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, fmt.Errorf("multi-byte tags are not supported")
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, length)
+	_, err = io.ReadFull(r, value)
+	if err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, value)
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b&0x80 == 0 {
+		return int(b), nil
+	}
+	n := int(b & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, fmt.Errorf("unsupported BER length encoding")
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxPacket {
+		return 0, fmt.Errorf("BER element of %d bytes is too large", length)
+	}
+	return length, nil
+}
+
+// parse an element's contents, and its children if it is constructed
+func parsePacket(tag byte, value []byte) (*packet, error) {
+	p := &packet{tag: tag, value: value}
+	if !p.isConstructed() {
+		return p, nil
+	}
+	for len(value) > 0 {
+		if len(value) < 2 {
+			return nil, fmt.Errorf("truncated BER element")
+		}
+		childTag := value[0]
+		length, header := int(value[1]), 2
+		if value[1]&0x80 != 0 {
+			n := int(value[1] & 0x7f)
+			if n == 0 || n > 4 || len(value) < 2+n {
+				return nil, fmt.Errorf("bad BER length")
+			}
+			length = 0
+			for _, b := range value[2 : 2+n] {
+				length = length<<8 | int(b)
+			}
+			header += n
+		}
+		if length < 0 || len(value) < header+length {
+			return nil, fmt.Errorf("truncated BER element")
+		}
+		child, err := parsePacket(childTag, value[header:header+length])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		value = value[header+length:]
+	}
+	return p, nil
+}
+
+func (p *packet) String() string {
+	return string(p.value)
+}
+
+func (p *packet) Int() int {
+	n := 0
+	for i, b := range p.value {
+		if i == 0 && b&0x80 != 0 {
+			n = -1 // negative
+		}
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+func (p *packet) Bool() bool {
+	return len(p.value) > 0 && p.value[0] != 0
+}
+
+// encode an element with the given contents
+func encode(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	n := len(content)
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	case n < 0x10000:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+func encodeInt(tag byte, n int) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(n)}, content...)
+		if (n >= -0x80 && n < 0x80) || len(content) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return encode(tag, content)
+}
+
+func encodeString(tag byte, s string) []byte {
+	return encode(tag, []byte(s))
+}
+
+func encodeBool(b bool) []byte {
+	if b {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0})
+}
+
+func encodeSeq(tag byte, children ...[]byte) []byte {
+	var content []byte
+	for _, c := range children {
+		content = append(content, c...)
+	}
+	return encode(tag, content)
+}
@@ -0,0 +1,175 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An Entry is one object in the directory
+type Entry struct {
+	DN    string
+	Attrs map[string][]string // keyed by attribute name as written in the LDIF
+}
+
+// Get returns the values of an attribute, matching its name without regard to case
+func (e *Entry) Get(attr string) []string {
+	for name, values := range e.Attrs {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+// A Directory is the set of entries a Server serves
+type Directory struct {
+	Entries []*Entry
+}
+
+// ParseLDIF reads a directory from LDIF (RFC 2849) content records
+// Continuation lines, comments and base64 values ("attr:: ...") are supported; change records are not.
+func ParseLDIF(in []byte) (*Directory, error) {
+	dir := new(Directory)
+	var entry *Entry
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	lineNum := 0
+	flush := func() error {
+		for _, line := range lines {
+			i := strings.Index(line, ":")
+			if i <= 0 {
+				return fmt.Errorf("line %d: expected 'attribute: value'", lineNum)
+			}
+			attr, value := line[:i], strings.TrimLeft(line[i+1:], " ")
+			if strings.HasPrefix(line[i+1:], ":") {
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line[i+2:]))
+				if err != nil {
+					return fmt.Errorf("line %d: bad base64 value for %s: %v", lineNum, attr, err)
+				}
+				value = string(decoded)
+			}
+			if entry == nil {
+				if !strings.EqualFold(attr, "dn") {
+					return fmt.Errorf("line %d: entry must start with dn", lineNum)
+				}
+				entry = &Entry{DN: value, Attrs: make(map[string][]string)}
+				continue
+			}
+			entry.Attrs[attr] = append(entry.Attrs[attr], value)
+		}
+		if entry != nil {
+			dir.Entries = append(dir.Entries, entry)
+		}
+		entry, lines = nil, nil
+		return nil
+	}
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " "):
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("line %d: continuation with nothing to continue", lineNum)
+			}
+			lines[len(lines)-1] += line[1:]
+		case strings.TrimSpace(line) == "":
+			err := flush()
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "version:") && len(dir.Entries) == 0 && len(lines) == 0:
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+	return dir, nil
+}
+
+// Find the entry with a DN, nil if there is none
+func (d *Directory) Find(dn string) *Entry {
+	n := normalizeDN(dn)
+	for _, e := range d.Entries {
+		if normalizeDN(e.DN) == n {
+			return e
+		}
+	}
+	return nil
+}
+
+// Search scopes
+const (
+	ScopeBase = iota
+	ScopeOne
+	ScopeSub
+)
+
+// entries under base within scope that match a filter, in directory order
+func (d *Directory) search(base string, scope int, f filter) []*Entry {
+	b := normalizeDN(base)
+	var found []*Entry
+	for _, e := range d.Entries {
+		dn := normalizeDN(e.DN)
+		var inScope bool
+		switch scope {
+		case ScopeBase:
+			inScope = dn == b
+		case ScopeOne:
+			inScope = parentDN(dn) == b
+		default:
+			inScope = dn == b || b == "" || strings.HasSuffix(dn, ","+b)
+		}
+		if inScope && f.match(e) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// the root DSE, describing the server to clients that ask for it with a base search of ""
+func (d *Directory) rootDSE() *Entry {
+	var contexts []string
+	for _, e := range d.Entries {
+		if parent := parentDN(normalizeDN(e.DN)); parent == "" || d.Find(parent) == nil {
+			contexts = append(contexts, e.DN)
+		}
+	}
+	sort.Strings(contexts)
+	return &Entry{Attrs: map[string][]string{
+		"objectClass":          {"top"},
+		"namingContexts":       contexts,
+		"supportedLDAPVersion": {"3"},
+	}}
+}
+
+// lowercase a DN and remove the spaces around its separators, so equal DNs compare equal
+func normalizeDN(dn string) string {
+	rdns := strings.Split(dn, ",")
+	for i, rdn := range rdns {
+		parts := strings.SplitN(rdn, "=", 2)
+		for j := range parts {
+			parts[j] = strings.TrimSpace(parts[j])
+		}
+		rdns[i] = strings.ToLower(strings.Join(parts, "="))
+	}
+	return strings.Join(rdns, ",")
+}
+
+func parentDN(dn string) string {
+	i := strings.Index(dn, ",")
+	if i < 0 {
+		return ""
+	}
+	return dn[i+1:]
+}
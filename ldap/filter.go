@@ -0,0 +1,198 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A filter from a search request (RFC 4511 section 4.5.1.7)
+type filter interface {
+	match(e *Entry) bool
+	String() string
+}
+
+type andFilter []filter
+type orFilter []filter
+type notFilter struct{ f filter }
+type presentFilter string
+type compareFilter struct {
+	op    string // "=", ">=", "<=" or "~="
+	attr  string
+	value string
+}
+type substringFilter struct {
+	attr    string
+	initial string
+	any     []string
+	final   string
+}
+
+// Filter choice tags
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEquality       = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+	filterApprox         = classContext | constructed | 8
+)
+
+func parseFilter(p *packet) (filter, error) {
+	switch p.tag {
+	case filterAnd, filterOr:
+		var fs []filter
+		for _, c := range p.children {
+			f, err := parseFilter(c)
+			if err != nil {
+				return nil, err
+			}
+			fs = append(fs, f)
+		}
+		if p.tag == filterAnd {
+			return andFilter(fs), nil
+		}
+		return orFilter(fs), nil
+	case filterNot:
+		if len(p.children) != 1 {
+			return nil, fmt.Errorf("bad not filter")
+		}
+		f, err := parseFilter(p.children[0])
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{f}, nil
+	case filterEquality, filterGreaterOrEqual, filterLessOrEqual, filterApprox:
+		if len(p.children) != 2 {
+			return nil, fmt.Errorf("bad comparison filter")
+		}
+		op := map[byte]string{filterEquality: "=", filterGreaterOrEqual: ">=", filterLessOrEqual: "<=", filterApprox: "~="}[p.tag]
+		return compareFilter{op: op, attr: p.children[0].String(), value: p.children[1].String()}, nil
+	case filterSubstrings:
+		if len(p.children) != 2 {
+			return nil, fmt.Errorf("bad substrings filter")
+		}
+		f := substringFilter{attr: p.children[0].String()}
+		for _, c := range p.children[1].children {
+			switch c.tag {
+			case classContext | 0:
+				f.initial = c.String()
+			case classContext | 1:
+				f.any = append(f.any, c.String())
+			case classContext | 2:
+				f.final = c.String()
+			}
+		}
+		return f, nil
+	case filterPresent:
+		return presentFilter(p.String()), nil
+	}
+	return nil, fmt.Errorf("unsupported filter type 0x%02x", p.tag)
+}
+
+func (f andFilter) match(e *Entry) bool {
+	for _, c := range f {
+		if !c.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f orFilter) match(e *Entry) bool {
+	for _, c := range f {
+		if c.match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f notFilter) match(e *Entry) bool {
+	return !f.f.match(e)
+}
+
+func (f presentFilter) match(e *Entry) bool {
+	return strings.EqualFold(string(f), "objectClass") || len(e.Get(string(f))) > 0
+}
+
+// values are compared without regard to case, and DN-valued attributes such as member with normalized DNs
+func (f compareFilter) match(e *Entry) bool {
+	want := normalizeDN(f.value)
+	for _, v := range e.Get(f.attr) {
+		got := normalizeDN(v)
+		switch f.op {
+		case "=", "~=":
+			if got == want {
+				return true
+			}
+		case ">=":
+			if got >= want {
+				return true
+			}
+		case "<=":
+			if got <= want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f substringFilter) match(e *Entry) bool {
+	for _, v := range e.Get(f.attr) {
+		v = strings.ToLower(v)
+		if !strings.HasPrefix(v, strings.ToLower(f.initial)) {
+			continue
+		}
+		v = v[len(f.initial):]
+		ok := true
+		for _, a := range f.any {
+			i := strings.Index(v, strings.ToLower(a))
+			if i < 0 {
+				ok = false
+				break
+			}
+			v = v[i+len(a):]
+		}
+		if ok && strings.HasSuffix(v, strings.ToLower(f.final)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f andFilter) String() string {
+	return "(&" + joinFilters(f) + ")"
+}
+
+func (f orFilter) String() string {
+	return "(|" + joinFilters(f) + ")"
+}
+
+func (f notFilter) String() string {
+	return "(!" + f.f.String() + ")"
+}
+
+func (f presentFilter) String() string {
+	return "(" + string(f) + "=*)"
+}
+
+func (f compareFilter) String() string {
+	return "(" + f.attr + f.op + f.value + ")"
+}
+
+func (f substringFilter) String() string {
+	parts := append(append([]string{f.initial}, f.any...), f.final)
+	return "(" + f.attr + "=" + strings.Join(parts, "*") + ")"
+}
+
+func joinFilters(fs []filter) string {
+	var s []string
+	for _, f := range fs {
+		s = append(s, f.String())
+	}
+	return strings.Join(s, "")
+}
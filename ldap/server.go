@@ -0,0 +1,253 @@
+// Package ldap is a minimal in-process LDAP v3 server, standing in for a corporate directory when reproducing
+// problems with MongoDB Enterprise's LDAP authentication and authorization
+package ldap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+)
+
+/*
+Supported operations: simple bind, search (every filter type, all scopes), unbind and abandon.
+SASL binds, StartTLS and other extended operations, and modifications are refused.
+Entries' userPassword attributes are checked on bind and never returned by searches.
+*/
+
+// Protocol operation tags
+const (
+	opBindRequest      = classApplication | constructed | 0
+	opBindResponse     = classApplication | constructed | 1
+	opUnbindRequest    = classApplication | 2
+	opSearchRequest    = classApplication | constructed | 3
+	opSearchEntry      = classApplication | constructed | 4
+	opSearchDone       = classApplication | constructed | 5
+	opAbandonRequest   = classApplication | 16
+	opExtendedRequest  = classApplication | constructed | 23
+	opExtendedResponse = classApplication | constructed | 24
+)
+
+// Result codes
+const (
+	resultSuccess                 = 0
+	resultProtocolError           = 2
+	resultAuthMethodNotSupported  = 7
+	resultNoSuchObject            = 32
+	resultInvalidCredentials      = 49
+	resultInsufficientAccessRight = 50
+	resultUnwillingToPerform      = 53
+)
+
+// A Server answers LDAP requests from a Directory
+type Server struct {
+	Dir       *Directory
+	Anonymous bool        // allow searches without binding first
+	Log       *log.Logger // every request and its result, if not nil
+}
+
+// ListenAndServe serves LDAP on a TCP address such as "localhost:3389" until the listener fails
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", addr, err)
+	}
+	return s.Serve(l)
+}
+
+// Serve LDAP on connections from a listener
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log.Printf(format, args...)
+	}
+}
+
+// a client connection and who it is bound as
+type session struct {
+	s     *Server
+	conn  net.Conn
+	bound string // DN of the bound user, "" if anonymous
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	sess := &session{s: s, conn: conn}
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			if err != io.EOF {
+				s.logf("%s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if msg.tag != tagSequence || len(msg.children) < 2 {
+			s.logf("%s: malformed message", conn.RemoteAddr())
+			return
+		}
+		id, op := msg.children[0].Int(), msg.children[1]
+		switch op.tag {
+		case opBindRequest:
+			err = sess.bind(id, op)
+		case opSearchRequest:
+			err = sess.search(id, op)
+		case opUnbindRequest:
+			return
+		case opAbandonRequest:
+		case opExtendedRequest:
+			name := ""
+			if len(op.children) > 0 {
+				name = op.children[0].String()
+			}
+			s.logf("%s: extended operation %s refused", conn.RemoteAddr(), name)
+			err = sess.reply(id, opExtendedResponse, result(resultProtocolError, "extended operations are not supported"))
+		default:
+			s.logf("%s: operation 0x%02x refused", conn.RemoteAddr(), op.tag)
+			err = sess.reply(id, (op.tag|constructed)+1, result(resultUnwillingToPerform, "operation not supported"))
+		}
+		if err != nil {
+			s.logf("%s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func (sess *session) reply(id int, tag byte, content ...[]byte) error {
+	_, err := sess.conn.Write(encodeSeq(tagSequence, encodeInt(tagInteger, id), encodeSeq(tag, content...)))
+	return err
+}
+
+// the components of an LDAPResult
+func result(code int, message string) []byte {
+	return append(append(encodeInt(tagEnumerated, code), encodeString(tagOctetString, "")...), encodeString(tagOctetString, message)...)
+}
+
+func (sess *session) bind(id int, op *packet) error {
+	if len(op.children) < 3 {
+		return fmt.Errorf("malformed bind request")
+	}
+	dn, auth := op.children[1].String(), op.children[2]
+	if auth.tag != classContext|0 {
+		sess.s.logf("%s: bind as %s with SASL refused", sess.conn.RemoteAddr(), dn)
+		return sess.reply(id, opBindResponse, result(resultAuthMethodNotSupported, "only simple binds are supported"))
+	}
+	password := auth.String()
+	if dn == "" && password == "" {
+		sess.bound = ""
+		sess.s.logf("%s: anonymous bind", sess.conn.RemoteAddr())
+		return sess.reply(id, opBindResponse, result(resultSuccess, ""))
+	}
+	e := sess.s.Dir.Find(dn)
+	ok := e != nil && password != ""
+	if ok {
+		ok = false
+		for _, p := range e.Get("userPassword") {
+			if p == password {
+				ok = true
+			}
+		}
+	}
+	if !ok {
+		sess.s.logf("%s: bind as %s failed: invalid credentials", sess.conn.RemoteAddr(), dn)
+		return sess.reply(id, opBindResponse, result(resultInvalidCredentials, "invalid credentials"))
+	}
+	sess.bound = e.DN
+	sess.s.logf("%s: bind as %s", sess.conn.RemoteAddr(), e.DN)
+	return sess.reply(id, opBindResponse, result(resultSuccess, ""))
+}
+
+func (sess *session) search(id int, op *packet) error {
+	if len(op.children) < 8 {
+		return fmt.Errorf("malformed search request")
+	}
+	base, scope := op.children[0].String(), op.children[1].Int()
+	if scope < ScopeBase || scope > ScopeSub {
+		return sess.reply(id, opSearchDone, result(resultProtocolError, "bad scope"))
+	}
+	typesOnly := op.children[5].Bool()
+	f, err := parseFilter(op.children[6])
+	if err != nil {
+		sess.s.logf("%s: search refused: %v", sess.conn.RemoteAddr(), err)
+		return sess.reply(id, opSearchDone, result(resultProtocolError, err.Error()))
+	}
+	var attrs []string
+	for _, a := range op.children[7].children {
+		attrs = append(attrs, a.String())
+	}
+	scopeName := [...]string{"base", "one", "sub"}[scope]
+
+	var entries []*Entry
+	if base == "" && scope == ScopeBase {
+		entries = []*Entry{sess.s.Dir.rootDSE()}
+	} else {
+		if sess.bound == "" && !sess.s.Anonymous {
+			sess.s.logf("%s: anonymous search of %s refused", sess.conn.RemoteAddr(), base)
+			return sess.reply(id, opSearchDone, result(resultInsufficientAccessRight, "bind first"))
+		}
+		if base != "" && sess.s.Dir.Find(base) == nil {
+			sess.s.logf("%s: search %s %s %v: no such object", sess.conn.RemoteAddr(), base, scopeName, f)
+			return sess.reply(id, opSearchDone, result(resultNoSuchObject, "no such object"))
+		}
+		entries = sess.s.Dir.search(base, scope, f)
+	}
+	for _, e := range entries {
+		err = sess.reply(id, opSearchEntry, encodeString(tagOctetString, e.DN), encodeAttrs(e, attrs, typesOnly))
+		if err != nil {
+			return err
+		}
+	}
+	var dns []string
+	for _, e := range entries {
+		dns = append(dns, e.DN)
+	}
+	sess.s.logf("%s: search %s %s %v %v: %d found %v", sess.conn.RemoteAddr(), base, scopeName, f, attrs, len(entries), dns)
+	return sess.reply(id, opSearchDone, result(resultSuccess, ""))
+}
+
+// the attributes of an entry requested by a search: all of them if none are named or "*" is, none for "1.1"
+func encodeAttrs(e *Entry, attrs []string, typesOnly bool) []byte {
+	all := len(attrs) == 0
+	for _, a := range attrs {
+		all = all || a == "*"
+	}
+	names := make([]string, 0, len(e.Attrs))
+	for name := range e.Attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list [][]byte
+	for _, name := range names {
+		values := e.Attrs[name]
+		if strings.EqualFold(name, "userPassword") {
+			continue
+		}
+		wanted := all
+		for _, a := range attrs {
+			wanted = wanted || strings.EqualFold(a, name)
+		}
+		if !wanted {
+			continue
+		}
+		var vals [][]byte
+		if !typesOnly {
+			for _, v := range values {
+				vals = append(vals, encodeString(tagOctetString, v))
+			}
+		}
+		list = append(list, encodeSeq(tagSequence, encodeString(tagOctetString, name), encodeSeq(tagSet, vals...)))
+	}
+	return encodeSeq(tagSequence, list...)
+}
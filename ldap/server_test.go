@@ -0,0 +1,148 @@
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+)
+
+const testLDIF = `dn: dc=repro,dc=test
+dc: repro
+
+dn: cn=query,dc=repro,dc=test
+cn: query
+userPassword: secret
+
+dn: ou=users,dc=repro,dc=test
+ou: users
+
+dn: uid=alice, ou=users, dc=repro, dc=test
+uid: alice
+cn:: QWxpY2U=
+userPassword: alice-password
+
+dn: uid=bob,ou=users,dc=repro,dc=test
+uid: bob
+cn: Bob
+ by
+userPassword: bob-password
+
+dn: cn=dba,dc=repro,dc=test
+cn: dba
+member: uid=alice,ou=users,dc=repro,dc=test
+`
+
+// a minimal client speaking just enough LDAP to test the server
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	id   int
+}
+
+func (c *testClient) send(op []byte) {
+	c.id++
+	_, _ = c.conn.Write(encodeSeq(tagSequence, encodeInt(tagInteger, c.id), op))
+}
+
+func (c *testClient) read(t *testing.T) *packet {
+	msg, err := readPacket(c.r)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if msg.children[0].Int() != c.id {
+		t.Fatalf("reply to message %d, wanted %d", msg.children[0].Int(), c.id)
+	}
+	return msg.children[1]
+}
+
+func (c *testClient) bind(t *testing.T, dn string, password string) int {
+	c.send(encodeSeq(opBindRequest, encodeInt(tagInteger, 3), encodeString(tagOctetString, dn), encodeString(classContext|0, password)))
+	return c.read(t).children[0].Int()
+}
+
+func (c *testClient) search(t *testing.T, base string, scope int, filter []byte) ([]string, int) {
+	c.send(encodeSeq(opSearchRequest, encodeString(tagOctetString, base), encodeInt(tagEnumerated, scope),
+		encodeInt(tagEnumerated, 0), encodeInt(tagInteger, 0), encodeInt(tagInteger, 0), encodeBool(false),
+		filter, encodeSeq(tagSequence, encodeString(tagOctetString, "cn"))))
+	var dns []string
+	for {
+		op := c.read(t)
+		if op.tag == opSearchDone {
+			return dns, op.children[0].Int()
+		}
+		dns = append(dns, op.children[0].String())
+	}
+}
+
+func equality(attr string, value string) []byte {
+	return encodeSeq(filterEquality, encodeString(tagOctetString, attr), encodeString(tagOctetString, value))
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ParseLDIF([]byte(testLDIF))
+	if err != nil {
+		t.Fatalf("ParseLDIF(): %v", err)
+	}
+	if got := dir.Find("uid=bob,ou=users,dc=repro,dc=test").Get("cn"); !reflect.DeepEqual(got, []string{"Bobby"}) {
+		t.Errorf("ParseLDIF(): continuation line got %v", got)
+	}
+	if got := dir.Find("uid=alice,ou=users,dc=repro,dc=test").Get("CN"); !reflect.DeepEqual(got, []string{"Alice"}) {
+		t.Errorf("ParseLDIF(): base64 value got %v", got)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	go func() { _ = (&Server{Dir: dir}).Serve(l) }()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	c := &testClient{conn: conn, r: bufio.NewReader(conn)}
+
+	if _, code := c.search(t, "dc=repro,dc=test", ScopeSub, encodeString(filterPresent, "objectClass")); code != resultInsufficientAccessRight {
+		t.Errorf("anonymous search: got result %d", code)
+	}
+	if code := c.bind(t, "cn=query,dc=repro,dc=test", "wrong"); code != resultInvalidCredentials {
+		t.Errorf("bind with wrong password: got result %d", code)
+	}
+	if code := c.bind(t, "CN=Query, DC=repro,DC=test", "secret"); code != resultSuccess {
+		t.Errorf("bind: got result %d", code)
+	}
+
+	tests := []struct {
+		name   string
+		base   string
+		scope  int
+		filter []byte
+		want   []string
+		code   int
+	}{
+		{"user by uid", "ou=users,dc=repro,dc=test", ScopeOne, equality("uid", "bob"), []string{"uid=bob,ou=users,dc=repro,dc=test"}, resultSuccess},
+		{"groups of user", "dc=repro,dc=test", ScopeSub, equality("member", "uid=alice, ou=users, dc=repro, dc=test"), []string{"cn=dba,dc=repro,dc=test"}, resultSuccess},
+		{"base scope", "ou=users,dc=repro,dc=test", ScopeBase, encodeString(filterPresent, "objectClass"), []string{"ou=users,dc=repro,dc=test"}, resultSuccess},
+		{"and/not", "ou=users,dc=repro,dc=test", ScopeSub,
+			encodeSeq(filterAnd, encodeString(filterPresent, "uid"), encodeSeq(filterNot, equality("uid", "alice"))),
+			[]string{"uid=bob,ou=users,dc=repro,dc=test"}, resultSuccess},
+		{"substrings", "dc=repro,dc=test", ScopeSub,
+			encodeSeq(filterSubstrings, encodeString(tagOctetString, "cn"), encodeSeq(tagSequence, encodeString(classContext|0, "b"))),
+			[]string{"uid=bob,ou=users,dc=repro,dc=test"}, resultSuccess},
+		{"no such base", "ou=nobody,dc=repro,dc=test", ScopeSub, encodeString(filterPresent, "objectClass"), nil, resultNoSuchObject},
+		{"root DSE", "", ScopeBase, encodeString(filterPresent, "objectClass"), []string{""}, resultSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, code := c.search(t, tt.base, tt.scope, tt.filter)
+			if code != tt.code {
+				t.Errorf("search: got result %d, wanted %d", code, tt.code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search: got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
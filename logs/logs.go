@@ -0,0 +1,249 @@
+// Package logs parses mongod and mongos logs, in both the JSON format of 4.4 and later and the text format of
+// earlier releases, and filters their entries
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An Entry is one log message
+type Entry struct {
+	Time      time.Time
+	Severity  string // "F", "E", "W", "I", or "D1" to "D5"
+	Component string // e.g. "NETWORK", "" if none
+	ID        int    // message id, 0 in text logs, which don't have them
+	Context   string // thread or connection, e.g. "conn12"
+	Msg       string
+	Attr      json.RawMessage // attributes, nil in text logs
+	Line      string          // the entry as it appears in the log
+}
+
+// severities from most to least severe; debug levels are numbered
+var severities = []string{"F", "E", "W", "I", "D1", "D2", "D3", "D4", "D5"}
+
+func severityRank(s string) int {
+	if s == "D" {
+		s = "D1"
+	}
+	for i, sev := range severities {
+		if sev == s {
+			return i
+		}
+	}
+	return len(severities)
+}
+
+// the fields of a JSON log line (https://docs.mongodb.com/manual/reference/log-messages/#structured-logging)
+type jsonEntry struct {
+	T struct {
+		Date string `json:"$date"`
+	} `json:"t"`
+	S    string          `json:"s"`
+	C    string          `json:"c"`
+	ID   int             `json:"id"`
+	Ctx  string          `json:"ctx"`
+	Msg  string          `json:"msg"`
+	Attr json.RawMessage `json:"attr"`
+}
+
+// e.g. 2020-03-04T10:11:12.123+0000 I  COMMAND  [conn12] command test.foo ...
+var textLine = regexp.MustCompile(`^(\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(?:\.\d+)?(?:Z|[+-]\d{4}))\s+([FEWID]\d?)\s+(\S+)\s+\[([^\]]*)\]\s?(.*)$`)
+
+var textTimeLayouts = []string{"2006-01-02T15:04:05.999-0700", "2006-01-02T15:04:05.999Z07:00"}
+
+// ParseLine parses one line of a log; ok is false if the line doesn't start an entry, e.g. it continues a text
+// log message spread over several lines
+func ParseLine(line string) (e *Entry, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "{") {
+		var j jsonEntry
+		if json.Unmarshal([]byte(line), &j) != nil {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, j.T.Date)
+		if err != nil {
+			return nil, false
+		}
+		if j.C == "-" {
+			j.C = ""
+		}
+		return &Entry{Time: t, Severity: j.S, Component: j.C, ID: j.ID, Context: j.Ctx, Msg: j.Msg, Attr: j.Attr, Line: line}, true
+	}
+	m := textLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	var t time.Time
+	var err error
+	for _, layout := range textTimeLayouts {
+		t, err = time.Parse(layout, m[1])
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, false
+	}
+	component := m[3]
+	if component == "-" {
+		component = ""
+	}
+	return &Entry{Time: t, Severity: m[2], Component: component, Context: m[4], Msg: m[5], Line: line}, true
+}
+
+// Parse reads a log, calling fn with each entry in order
+// Lines that don't start an entry are added to the previous one; any before the first entry are skipped.
+func Parse(r io.Reader, fn func(e *Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var pending *Entry
+	for scanner.Scan() {
+		line := scanner.Text()
+		e, ok := ParseLine(line)
+		if !ok {
+			if pending != nil {
+				pending.Msg += "\n" + line
+				pending.Line += "\n" + line
+			}
+			continue
+		}
+		if pending != nil {
+			err := fn(pending)
+			if err != nil {
+				return err
+			}
+		}
+		pending = e
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if pending != nil {
+		return fn(pending)
+	}
+	return nil
+}
+
+// e.g. "... protocol:op_msg 1502ms" at the end of a text slow query message
+var textDuration = regexp.MustCompile(`\s(\d+)ms$`)
+
+// DurationMillis is how long the operation the entry reports took, ok is false if it doesn't report one
+func (e *Entry) DurationMillis() (ms int, ok bool) {
+	if e.Attr != nil {
+		var attr struct {
+			DurationMillis *int `json:"durationMillis"`
+		}
+		if json.Unmarshal(e.Attr, &attr) == nil && attr.DurationMillis != nil {
+			return *attr.DurationMillis, true
+		}
+		return 0, false
+	}
+	if m := textDuration.FindStringSubmatch(e.Msg); m != nil {
+		ms, err := strconv.Atoi(m[1])
+		return ms, err == nil
+	}
+	return 0, false
+}
+
+// Header is the entry without its attributes, on one line: time, severity, component, id, context and message
+func (e *Entry) Header() string {
+	id := "-"
+	if e.ID != 0 {
+		id = strconv.Itoa(e.ID)
+	}
+	component := e.Component
+	if component == "" {
+		component = "-"
+	}
+	msg := e.Msg
+	if i := strings.Index(msg, "\n"); i >= 0 {
+		msg = msg[:i] + " ..."
+	}
+	return fmt.Sprintf("%s %-2s %-8s %-5s [%s] %s", e.Time.UTC().Format("2006-01-02T15:04:05.000Z"), e.Severity, component, id, e.Context, msg)
+}
+
+// PrettyAttr is the entry's attributes as indented JSON, or the rest of a multi-line text message; "" if it has neither
+func (e *Entry) PrettyAttr(indent string) string {
+	if len(e.Attr) > 0 {
+		var out bytes.Buffer
+		if json.Indent(&out, e.Attr, indent, "  ") == nil {
+			return indent + out.String()
+		}
+		return indent + string(e.Attr)
+	}
+	if i := strings.Index(e.Msg, "\n"); i >= 0 {
+		return indent + strings.Replace(e.Msg[i+1:], "\n", "\n"+indent, -1)
+	}
+	return ""
+}
+
+// A Filter selects entries; empty fields select everything
+type Filter struct {
+	Severity   string   // the least severe level wanted, e.g. "W" for fatal, error and warning entries
+	Components []string // without regard to case
+	IDs        []int
+	Contexts   []string // e.g. "conn12", or just "12" for a connection
+	Since      time.Time
+	Until      time.Time
+	SlowMS     int // only entries reporting an operation that took at least this long, if not 0
+}
+
+// Match reports whether an entry passes the filter
+func (f *Filter) Match(e *Entry) bool {
+	if f.Severity != "" && severityRank(e.Severity) > severityRank(f.Severity) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if len(f.Components) > 0 {
+		found := false
+		for _, c := range f.Components {
+			found = found || strings.EqualFold(c, e.Component)
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.IDs) > 0 {
+		found := false
+		for _, id := range f.IDs {
+			found = found || id == e.ID
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Contexts) > 0 {
+		found := false
+		for _, c := range f.Contexts {
+			found = found || c == e.Context || "conn"+c == e.Context
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.SlowMS > 0 {
+		ms, ok := e.DurationMillis()
+		if !ok || ms < f.SlowMS {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidSeverity reports whether s names a severity level Filter understands
+func ValidSeverity(s string) bool {
+	return severityRank(s) < len(severities)
+}
@@ -0,0 +1,91 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testJSON = `{"t":{"$date":"2021-03-04T10:00:00.000+00:00"},"s":"I",  "c":"NETWORK",  "id":22943,   "ctx":"listener","msg":"Connection accepted","attr":{"remote":"127.0.0.1:50000","connectionId":12}}
+{"t":{"$date":"2021-03-04T10:00:01.000+00:00"},"s":"W",  "c":"CONTROL",  "id":22120,   "ctx":"initandlisten","msg":"Access control is not enabled for the database"}
+{"t":{"$date":"2021-03-04T10:00:02.500+00:00"},"s":"I",  "c":"COMMAND",  "id":51803,   "ctx":"conn12","msg":"Slow query","attr":{"type":"command","ns":"test.orders","durationMillis":1502}}
+{"t":{"$date":"2021-03-04T10:00:03.000+00:00"},"s":"E",  "c":"STORAGE",  "id":22435,   "ctx":"conn13","msg":"WiredTiger error","attr":{"error":13}}
+`
+
+const testText = `2019-11-20T15:00:00.000+0000 I  CONTROL  [initandlisten] MongoDB starting : pid=1 port=27017
+2019-11-20T15:00:01.000+0000 W  ACCESS   [conn5] Unsupported authentication mechanism
+2019-11-20T15:00:02.000+0000 I  COMMAND  [conn5] command test.orders command: find { find: "orders" } planSummary: COLLSCAN protocol:op_msg 250ms
+2019-11-20T15:00:03.000+0000 F  -        [conn6] Invariant failure
+ at src/mongo/db/x.cpp 42
+2019-11-20T15:00:04.000+0000 D1 QUERY    [conn6] Running query
+`
+
+func parseAll(t *testing.T, in string) []*Entry {
+	var entries []*Entry
+	err := Parse(strings.NewReader(in), func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+	return entries
+}
+
+func TestParse(t *testing.T) {
+	entries := parseAll(t, testJSON)
+	if len(entries) != 4 {
+		t.Fatalf("Parse(JSON): got %d entries, wanted 4", len(entries))
+	}
+	e := entries[0]
+	if e.ID != 22943 || e.Component != "NETWORK" || e.Context != "listener" || !e.Time.Equal(time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Parse(JSON): got %+v", e)
+	}
+	if got := e.PrettyAttr(""); !strings.Contains(got, "\n  \"connectionId\": 12") {
+		t.Errorf("PrettyAttr(): got %s", got)
+	}
+
+	entries = parseAll(t, testText)
+	if len(entries) != 5 {
+		t.Fatalf("Parse(text): got %d entries, wanted 5", len(entries))
+	}
+	e = entries[3]
+	if e.Severity != "F" || e.Component != "" || e.Context != "conn6" || e.Msg != "Invariant failure\n at src/mongo/db/x.cpp 42" {
+		t.Errorf("Parse(text): continuation line got %+v", e)
+	}
+	if ms, ok := entries[2].DurationMillis(); !ok || ms != 250 {
+		t.Errorf("DurationMillis(): got %d, %v, wanted 250", ms, ok)
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	entries := append(parseAll(t, testJSON), parseAll(t, testText)...)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"everything", Filter{}, 9},
+		{"warnings and worse", Filter{Severity: "W"}, 4},
+		{"debug", Filter{Severity: "D1"}, 9},
+		{"component", Filter{Components: []string{"command", "access"}}, 3},
+		{"id", Filter{IDs: []int{51803}}, 1},
+		{"connection", Filter{Contexts: []string{"12", "conn6"}}, 3},
+		{"time window", Filter{Since: time.Date(2021, 3, 4, 10, 0, 1, 0, time.UTC), Until: time.Date(2021, 3, 4, 10, 0, 3, 0, time.UTC)}, 2},
+		{"slow", Filter{SlowMS: 200}, 2},
+		{"slower", Filter{SlowMS: 1000}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			for _, e := range entries {
+				if tt.filter.Match(e) {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Match(): got %d entries, wanted %d", got, tt.want)
+			}
+		})
+	}
+}
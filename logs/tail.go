@@ -0,0 +1,190 @@
+package logs
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tail follows a log file as tail -F does, calling fn with the complete lines appended to it each time it grows,
+// until stop is closed. It polls every interval, and waits for the file if it doesn't exist yet. Once it has read all
+// there is, fn is called with no lines, so a Grouper knows the last entry is complete.
+// Log rotation is followed: when the file is renamed and replaced (logRotate, or systemLog.logRotate: reopen with
+// an external tool) the rest of the old file is read before the new one; when it is truncated it is read again from
+// the start. If fromStart is false, only lines added after Tail starts are passed on.
+func Tail(path string, fromStart bool, interval time.Duration, stop <-chan struct{}, fn func(lines []string)) error {
+	var f *os.File
+	var info os.FileInfo
+	var offset int64
+	var partial string
+	var modTime time.Time // when the file was last seen to change
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+	buf := make([]byte, 64*1024)
+	// read everything that's there, passing on complete lines
+	read := func() (bool, error) {
+		grew := false
+		for {
+			n, err := f.Read(buf)
+			offset += int64(n)
+			if n > 0 {
+				grew = true
+				lines := strings.Split(partial+string(buf[:n]), "\n")
+				partial = lines[len(lines)-1]
+				if len(lines) > 1 {
+					fn(lines[:len(lines)-1])
+				}
+			}
+			if err == io.EOF || n == 0 {
+				return grew, nil
+			}
+			if err != nil {
+				return grew, err
+			}
+		}
+	}
+	for {
+		if f == nil {
+			var err error
+			f, err = os.Open(path)
+			if err == nil {
+				info, err = f.Stat()
+			}
+			if err != nil {
+				if f != nil {
+					_ = f.Close()
+				}
+				f = nil
+			} else if !fromStart {
+				offset, _ = f.Seek(0, io.SeekEnd)
+			}
+			fromStart = true // files appearing later, or replacing this one, are new: read them in full
+		}
+
+		grew := false
+		if f != nil {
+			var err error
+			grew, err = read()
+			if err != nil {
+				return err
+			}
+			if grew {
+				fn(nil)
+			}
+		}
+
+		// at the end of the file: check whether it has been rotated or truncated
+		if f != nil {
+			current, err := os.Stat(path)
+			switch {
+			case err != nil || !os.SameFile(info, current):
+				// anything written since the last read is in the old file, ending with the last line in full
+				if _, rerr := read(); rerr != nil {
+					return rerr
+				}
+				if partial != "" {
+					fn([]string{partial})
+					fn(nil)
+				}
+				_ = f.Close()
+				f, offset, partial = nil, 0, ""
+				if err == nil {
+					continue // the new file is there already
+				}
+			case current.Size() < offset || (current.Size() == offset && !grew && !modTime.IsZero() && !current.ModTime().Equal(modTime)):
+				// truncated, perhaps rewritten to the same length, since nothing was added
+				offset, _ = f.Seek(0, io.SeekStart)
+				partial, modTime = "", time.Time{}
+				continue
+			}
+			modTime = current.ModTime()
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// A Grouper turns batches of lines from Tail into entries, adding continuation lines to the entry they follow
+// Batches can end in the middle of an entry, so the last one is held back until the next starts or a batch with no
+// lines says there is no more for now.
+type Grouper struct {
+	last *Entry
+}
+
+// Add a batch of lines, returning the entries now complete
+func (g *Grouper) Add(lines []string) []*Entry {
+	var entries []*Entry
+	if len(lines) == 0 {
+		if g.last != nil {
+			entries = append(entries, g.last)
+			g.last = nil
+		}
+		return entries
+	}
+	for _, line := range lines {
+		e, ok := ParseLine(line)
+		if !ok {
+			if g.last != nil {
+				g.last.Msg += "\n" + line
+				g.last.Line += "\n" + line
+			}
+			continue
+		}
+		if g.last != nil {
+			entries = append(entries, g.last)
+		}
+		g.last = e
+	}
+	return entries
+}
+
+// A Sourced entry is one read from a particular log
+type Sourced struct {
+	Source string
+	*Entry
+	received time.Time
+}
+
+// A Merger puts entries arriving from several logs back in time order
+// Each entry is held for Delay after it arrives, in case an earlier one from another log is still on its way.
+type Merger struct {
+	Delay time.Duration
+
+	mu      sync.Mutex
+	pending []Sourced
+}
+
+// Add entries read from a log now
+func (m *Merger) Add(source string, entries []*Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, e := range entries {
+		m.pending = append(m.pending, Sourced{Source: source, Entry: e, received: now})
+	}
+}
+
+// Ready removes and returns the entries that can be written out in time order: those before the earliest entry that
+// arrived less than Delay before now
+func (m *Merger) Ready(now time.Time) []Sourced {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sort.SliceStable(m.pending, func(i, j int) bool { return m.pending[i].Time.Before(m.pending[j].Time) })
+	n := 0
+	for n < len(m.pending) && now.Sub(m.pending[n].received) >= m.Delay {
+		n++
+	}
+	ready := append([]Sourced(nil), m.pending[:n]...)
+	m.pending = m.pending[n:]
+	return ready
+}
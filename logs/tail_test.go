@@ -0,0 +1,113 @@
+package logs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "mongod.log")
+	write := func(flag int, s string) {
+		f, err := os.OpenFile(fn, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.WriteString(s)
+		_ = f.Close()
+	}
+	write(os.O_TRUNC, "before\n")
+
+	var mu sync.Mutex
+	var got []string
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Tail(fn, false, 5*time.Millisecond, stop, func(lines []string) {
+			mu.Lock()
+			got = append(got, lines...)
+			mu.Unlock()
+		})
+	}()
+	waitFor := func(want string) {
+		for i := 0; i < 200; i++ {
+			mu.Lock()
+			s := strings.Join(got, ",")
+			mu.Unlock()
+			if s == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("Tail(): got lines %v, wanted %s", got, want)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	write(os.O_APPEND, "one\ntw")
+	waitFor("one")
+	write(os.O_APPEND, "o\n")
+	waitFor("one,two")
+
+	// logRotate renames the log and starts a new one, after the last line, here unfinished, written to the old one
+	write(os.O_APPEND, "three\nthree and a half")
+	err := os.Rename(fn, fn+".2021-03-04T10-00-00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(os.O_TRUNC, "four\n")
+	waitFor("one,two,three,three and a half,four")
+
+	// truncated in place
+	time.Sleep(20 * time.Millisecond)
+	err = ioutil.WriteFile(fn, []byte("five\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("one,two,three,three and a half,four,five")
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("Tail(): %v", err)
+	}
+}
+
+func TestGrouper(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(testText), "\n")
+	g := new(Grouper)
+	var entries []*Entry
+	for _, batch := range [][]string{lines[:4], lines[4:5], nil, lines[5:], nil} { // split in the middle of an entry
+		entries = append(entries, g.Add(batch)...)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("Add(): got %d entries, wanted 5", len(entries))
+	}
+	if e := entries[3]; e.Msg != "Invariant failure\n at src/mongo/db/x.cpp 42" {
+		t.Errorf("Add(): continuation line got %+v", e)
+	}
+}
+
+func TestMerger(t *testing.T) {
+	m := &Merger{Delay: time.Second}
+	g := new(Grouper)
+	a := append(g.Add(strings.Split(strings.TrimSpace(testJSON), "\n")), g.Add(nil)...)
+	m.Add("a", []*Entry{a[0], a[2]})
+	now := time.Now()
+	m.Add("b", []*Entry{a[1]})
+	if ready := m.Ready(now); len(ready) != 0 {
+		t.Errorf("Ready(): got %d entries before the delay", len(ready))
+	}
+	ready := m.Ready(now.Add(2 * time.Second))
+	var order []string
+	for _, s := range ready {
+		order = append(order, s.Source)
+	}
+	if strings.Join(order, "") != "aba" {
+		t.Errorf("Ready(): got entries from %v, wanted a, b, a", order)
+	}
+}
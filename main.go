@@ -24,6 +24,41 @@ type OptionsType struct {
 func printHelp() {
 	fmt.Printf("%s list - lists currently downloaded versions\n", os.Args[0])
 	fmt.Printf("%s get - downloads a version\n", os.Args[0])
+	fmt.Printf("%s config [-profile p1,p2] [-spec file] [deployment] - creates a deployment (default: standalone \"sa\")\n", os.Args[0])
+	fmt.Printf("%s config profiles - lists the available config profiles\n", os.Args[0])
+	fmt.Printf("%s config diff [-json] <a> <b> - compares configs: YAML files, deployments, deployment/member, defaults or ours\n", os.Args[0])
+	fmt.Printf("%s config import <file> <deployment> [member] - imports a customer's mongod.conf, getCmdLineOpts output or mongod command line\n", os.Args[0])
+	fmt.Printf("%s config schema - prints a JSON Schema for config files\n", os.Args[0])
+	fmt.Printf("%s config args <file|deployment[/member]|defaults|ours> - prints the equivalent mongod command line\n", os.Args[0])
+	fmt.Printf("%s run [deployment] - starts a deployment, initiating its replica sets the first time\n", os.Args[0])
+	fmt.Printf("%s stop [deployment] - stops a deployment\n", os.Args[0])
+	fmt.Printf("%s status [deployment] - shows which members of a deployment are running\n", os.Args[0])
+	fmt.Printf("%s setparameter <deployment> <name=value>... - sets server parameters on a running deployment\n", os.Args[0])
+	fmt.Printf("%s load [-drop] [-batch n] [-ns db.collection] <deployment[/member]> <archive, dump directory, .bson or .json file> - loads mongodump or mongoexport output\n", os.Args[0])
+	fmt.Printf("%s snapshot save <deployment> <name> - shuts a deployment down, saves its data and configs, and starts it again\n", os.Args[0])
+	fmt.Printf("%s snapshot restore <name> - rolls a deployment back to a snapshot\n", os.Args[0])
+	fmt.Printf("%s snapshot list | snapshot delete <name> - lists snapshots with their size and date, or deletes one\n", os.Args[0])
+	fmt.Printf("%s seed [-batch n] [-concurrency n] [-drop] [-seed n] <deployment[/member]> <spec file> - fills a collection with synthetic documents\n", os.Args[0])
+	fmt.Printf("%s scenario run [-report file] [-keep-going] [-seed n] <deployment> <spec file> - runs the steps in a spec on a deployment and reports their results\n", os.Args[0])
+	fmt.Printf("%s fault kill|pause|resume|restart <deployment>/<member> - kills a member, hangs it with SIGSTOP, continues it, or restarts it\n", os.Args[0])
+	fmt.Printf("%s fault freeze <deployment>/<member> [seconds] | fault stepdown <deployment>[/<replica set>] [seconds] - runs replSetFreeze or replSetStepDown\n", os.Args[0])
+	fmt.Printf("%s fault list|clear|replay <deployment> - shows the faults injected as scenario steps, forgets them, or injects them again\n", os.Args[0])
+	fmt.Printf("%s upgrade <deployment> -to <x.y.z|x.y> - upgrades a running deployment in place, one release series at a time\n", os.Args[0])
+	fmt.Printf("%s downgrade <deployment> -to <x.y.z|x.y> - downgrades a running deployment in place, one release series at a time\n", os.Args[0])
+	fmt.Printf("%s fcv get <deployment> - shows the featureCompatibilityVersion of each replica set, shard and standalone\n", os.Args[0])
+	fmt.Printf("%s fcv set <deployment> <x.y> - sets a running deployment's featureCompatibilityVersion\n", os.Args[0])
+	fmt.Printf("%s tls setup [-mode m] <deployment> - creates a local CA and certificates and turns on TLS for a deployment\n", os.Args[0])
+	fmt.Printf("%s tls issue [-expired] [-wrongsan] [-selfsigned] <deployment> <member[/cluster]|client> - reissues a certificate\n", os.Args[0])
+	fmt.Printf("%s clusterauth <deployment> <keyFile|sendKeyFile|sendX509|x509> - moves a running deployment to another cluster auth mode\n", os.Args[0])
+	fmt.Printf("%s logs [-severity s] [-component c] [-id n] [-conn c] [-since t] [-until t] [-slow ms] [-short] <deployment[/member]|file> - shows log entries\n", os.Args[0])
+	fmt.Printf("%s logs -f [-color=false] [filters] <deployment[/member]|file> - follows the logs of every member at once\n", os.Args[0])
+	fmt.Printf("%s audit setup [-format JSON|BSON] [-filter f] <deployment> - makes each member of a deployment audit to a file\n", os.Args[0])
+	fmt.Printf("%s audit [-user u] [-atype a] [-since t] [-until t] [-failed] [-full] <deployment[/member]|file> - shows audit events\n", os.Args[0])
+	fmt.Printf("%s encryption setup [-kmip] [-addr a] [-cipher mode] <deployment> - turns on encryption at rest with key files or a KMIP server\n", os.Args[0])
+	fmt.Printf("%s encryption kmip [-addr a] <deployment> - runs a local KMIP server holding a deployment's master keys\n", os.Args[0])
+	fmt.Printf("%s encryption rotate <deployment> - rotates the KMIP master key of each member of a running deployment\n", os.Args[0])
+	fmt.Printf("%s ldap serve [-addr a] [-ldif file] [-anonymous] - runs a local LDAP server, by default with a built-in directory\n", os.Args[0])
+	fmt.Printf("%s ldap setup [-addr a] <deployment> - sets up a deployment to authenticate and authorize users with the local LDAP server\n", os.Args[0])
 	flag.PrintDefaults()
 }
 
@@ -56,7 +91,7 @@ func main() {
 		return
 	}
 
-	if flag.NArg() != 1 {
+	if flag.NArg() < 1 {
 		printHelp()
 		return
 	}
@@ -81,7 +116,7 @@ func main() {
 
 	var isWindows = v.OS == "win32"
 
-	err = cmds.Cmds(flag.Arg(0), v, isWindows)
+	err = cmds.Cmds(flag.Args(), v, isWindows)
 	if err != nil {
 		fmt.Printf("Error processing request: %v", err)
 	}
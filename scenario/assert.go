@@ -0,0 +1,168 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reflect"
+	"strings"
+)
+
+// Check an assert step's expectation against the previous step's result and error
+// An expected error is a code number, a code name such as NotWritablePrimary, or part of the message; any other
+// fields must be in the result with equal values. Documents match if they have the expected fields, whatever others they
+// have; numbers match whatever their types, and arrays match element by element.
+func Check(expect interface{}, result bson.Raw, err error) error {
+	doc, _ := expect.(bson.D)
+	var fields bson.D
+	wantError := false
+	for _, e := range doc {
+		if e.Key != "error" {
+			fields = append(fields, e)
+			continue
+		}
+		wantError = true
+		if err == nil {
+			return fmt.Errorf("expected error %v, got none", e.Value)
+		}
+		if !errorMatches(e.Value, err) {
+			return fmt.Errorf("expected error %v, got %v", e.Value, err)
+		}
+	}
+	if err != nil && !wantError {
+		return fmt.Errorf("previous step failed: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if result == nil {
+		return fmt.Errorf("previous step has no result")
+	}
+	var actual bson.D
+	uerr := bson.Unmarshal(result, &actual)
+	if uerr != nil {
+		return uerr
+	}
+	return match("", fields, actual)
+}
+
+// whether err has a code, code name or message matching want
+func errorMatches(want interface{}, err error) bool {
+	var codes []int32
+	var names []string
+	var ce mongo.CommandError
+	var we mongo.WriteException
+	var bwe mongo.BulkWriteException
+	switch {
+	case errors.As(err, &ce):
+		codes, names = append(codes, ce.Code), append(names, ce.Name)
+	case errors.As(err, &we):
+		for _, e := range we.WriteErrors {
+			codes = append(codes, int32(e.Code))
+		}
+		if we.WriteConcernError != nil {
+			codes, names = append(codes, int32(we.WriteConcernError.Code)), append(names, we.WriteConcernError.Name)
+		}
+	case errors.As(err, &bwe):
+		for _, e := range bwe.WriteErrors {
+			codes = append(codes, int32(e.Code))
+		}
+	}
+	switch w := want.(type) {
+	case int:
+		for _, c := range codes {
+			if int(c) == w {
+				return true
+			}
+		}
+		return false
+	case string:
+		for _, n := range names {
+			if n == w {
+				return true
+			}
+		}
+		if w == "DuplicateKey" { // write errors have codes but no names
+			for _, c := range codes {
+				if c == 11000 {
+					return true
+				}
+			}
+		}
+		return strings.Contains(err.Error(), w)
+	}
+	return false
+}
+
+// check actual has the value wanted, naming path in what doesn't match
+func match(path string, want interface{}, actual interface{}) error {
+	switch w := want.(type) {
+	case bson.D:
+		a, ok := actual.(bson.D)
+		if !ok {
+			return mismatch(path, want, actual)
+		}
+		for _, e := range w {
+			p := e.Key
+			if path != "" {
+				p = path + "." + e.Key
+			}
+			found := false
+			for _, ae := range a {
+				if ae.Key == e.Key {
+					found = true
+					if err := match(p, e.Value, ae.Value); err != nil {
+						return err
+					}
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: missing", p)
+			}
+		}
+		return nil
+	case bson.A:
+		a, ok := actual.(bson.A)
+		if !ok || len(a) != len(w) {
+			return mismatch(path, want, actual)
+		}
+		for i := range w {
+			if err := match(fmt.Sprintf("%s.%d", path, i), w[i], a[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	wn, wok := number(want)
+	an, aok := number(actual)
+	if wok && aok && wn == an {
+		return nil
+	}
+	if !wok && reflect.DeepEqual(want, actual) {
+		return nil
+	}
+	return mismatch(path, want, actual)
+}
+
+func mismatch(path string, want interface{}, actual interface{}) error {
+	if path == "" {
+		path = "result"
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, shortJSON(want, 60), shortJSON(actual, 60))
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
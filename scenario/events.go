@@ -0,0 +1,123 @@
+package scenario
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// An Event is a fault injected into a deployment by hand, recorded so the sequence can be replayed
+type Event struct {
+	Time    time.Time
+	Action  string // Kill, Pause, Resume, Restart, Freeze or StepDown
+	Member  string `json:",omitempty"`
+	ReplSet string `json:",omitempty"`
+	Seconds int    `json:",omitempty"`
+	Release string `json:",omitempty"` // the release the member was running, to start it with again
+	Error   string `json:",omitempty"` // why injecting the fault failed, "" if it worked
+}
+
+// Step is the step that injects the event's fault
+func (e *Event) Step() *Step {
+	return &Step{Action: e.Action, Member: e.Member, ReplSet: e.ReplSet, Seconds: e.Seconds}
+}
+
+// RecordEvent adds an event to the end of the events in fn, one JSON document per line
+func RecordEvent(fn string, e *Event) error {
+	out, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("error recording event: %v", err)
+	}
+	_, err = f.Write(append(out, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error recording event: %v", err)
+	}
+	return nil
+}
+
+// ReadEvents reads the events recorded in fn, none if it doesn't exist
+func ReadEvents(fn string) ([]*Event, error) {
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var events []*Event
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		e := new(Event)
+		err = json.Unmarshal(scanner.Bytes(), e)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", fn, n, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// Replay is a scenario injecting the faults that worked again, waiting between them as long as they were apart
+func Replay(events []*Event) *Scenario {
+	s := new(Scenario)
+	var prev time.Time
+	for _, e := range events {
+		if e.Error != "" {
+			continue
+		}
+		if wait := gap(prev, e.Time); wait > 0 {
+			s.Steps = append(s.Steps, &Step{Action: Wait, Wait: wait})
+		}
+		s.Steps = append(s.Steps, e.Step())
+		prev = e.Time
+	}
+	return s
+}
+
+// FormatEvents writes the replay of events as the steps of a deployment spec, with the time of each fault
+func FormatEvents(events []*Event) string {
+	var b strings.Builder
+	b.WriteString("steps:\n")
+	var prev time.Time
+	for _, e := range events {
+		if e.Error != "" {
+			continue
+		}
+		if wait := gap(prev, e.Time); wait > 0 {
+			fmt.Fprintf(&b, "  - %s: %v\n", Wait, wait)
+		}
+		target := e.Member
+		if e.Action == StepDown {
+			target = e.ReplSet
+		}
+		line := strings.TrimRight(fmt.Sprintf("  - %s: %s", e.Action, target), " ")
+		fmt.Fprintf(&b, "%-32s # %s\n", line, e.Time.Format("2006-01-02 15:04:05.000"))
+		if e.Action == StepDown || e.Action == Freeze {
+			fmt.Fprintf(&b, "    seconds: %d\n", e.Seconds)
+		}
+		prev = e.Time
+	}
+	return b.String()
+}
+
+// the time between one fault and the next, to the millisecond; 0 before the first
+func gap(prev time.Time, t time.Time) time.Duration {
+	if prev.IsZero() {
+		return 0
+	}
+	return t.Sub(prev).Round(time.Millisecond)
+}
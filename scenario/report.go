@@ -0,0 +1,83 @@
+package scenario
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"time"
+)
+
+// A Result of running a step
+type Result struct {
+	Step     *Step
+	Start    time.Time
+	Duration time.Duration
+	Output   bson.Raw // a command's reply, or a summary of what the step did; nil if it has none
+	Member   string   // the member the step ran on, "" if none
+	Err      error    // the server's or the step's error
+}
+
+// Failed reports whether the result ends a run
+func (r *Result) Failed() bool {
+	return r.Err != nil && (!r.Step.MayFail || r.Step.Action == Assert)
+}
+
+// A Report of a run, written as each step finishes
+type Report struct {
+	w       io.Writer
+	start   time.Time
+	steps   int
+	errors  int
+	failure *Result
+}
+
+// NewReport starts a report of a scenario from spec run on a deployment
+func NewReport(w io.Writer, spec string, deployment string) *Report {
+	r := &Report{w: w, start: time.Now()}
+	_, _ = fmt.Fprintf(w, "Scenario %s on deployment %s, started %s\n", spec, deployment, r.start.Format("2006-01-02 15:04:05.000"))
+	return r
+}
+
+// Add the result of the nth step, from 1
+func (r *Report) Add(n int, res *Result) {
+	r.steps++
+	status := "ok"
+	switch {
+	case res.Err != nil && res.Step.Action == Assert:
+		status = "FAILED"
+	case res.Err != nil && res.Step.MayFail:
+		status = "error"
+	case res.Err != nil:
+		status = "FAILED"
+	case res.Step.Action == Assert:
+		status = "passed"
+	}
+	if res.Err != nil {
+		r.errors++
+	}
+	if res.Failed() && r.failure == nil {
+		r.failure = res
+	}
+	desc := res.Step.String()
+	if res.Member != "" && res.Member != res.Step.Member {
+		desc += " (" + res.Member + ")"
+	}
+	_, _ = fmt.Fprintf(r.w, "%s step %-3d line %-4d %-6s %10v  %s\n", res.Start.Format("15:04:05.000"), n, res.Step.Line, status,
+		res.Duration.Round(time.Millisecond), desc)
+	if res.Output != nil {
+		_, _ = fmt.Fprintf(r.w, "%51s result: %s\n", "", shortJSON(res.Output, 2000))
+	}
+	if res.Err != nil {
+		_, _ = fmt.Fprintf(r.w, "%51s error: %v\n", "", res.Err)
+	}
+}
+
+// Finish the report, returning an error if a step failed
+func (r *Report) Finish() error {
+	_, _ = fmt.Fprintf(r.w, "Scenario finished after %d steps with %d errors in %v\n", r.steps, r.errors,
+		time.Since(r.start).Round(time.Millisecond))
+	if r.failure != nil {
+		return fmt.Errorf("step at line %d, %s, failed: %v", r.failure.Step.Line, r.failure.Step, r.failure.Err)
+	}
+	return nil
+}
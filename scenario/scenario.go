@@ -0,0 +1,239 @@
+// Package scenario reads the steps of a repro scenario, checks assertions about their results and reports on a run
+package scenario
+
+import (
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"github.com/SpencerBrown/mongodb-repro/seed"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+	"time"
+)
+
+// Step actions
+const (
+	Command  = "command"  // run a command
+	Insert   = "insert"   // insert documents generated from a seed spec
+	StepDown = "stepDown" // make a replica set's primary step down
+	Freeze   = "freeze"   // stop a member seeking election
+	Kill     = "kill"     // kill a member's process without letting it shut down
+	Pause    = "pause"    // stop a member's process, as if it hung
+	Resume   = "resume"   // continue a paused member's process
+	Restart  = "restart"  // shut a member down, killing it if it doesn't answer, and start it again
+	Start    = "start"    // start a member that isn't running
+	Wait     = "wait"     // pause
+	Assert   = "assert"   // check the previous step's result or error
+)
+
+// A Step of a scenario
+type Step struct {
+	Line    int // source line
+	Action  string
+	Command bson.D        // Command
+	DB      string        // Command: the database to run it on, default admin
+	On      string        // Command: a member, primary, secondary or mongos; "" for where the seed command sends data
+	ReplSet string        // StepDown, and Command on a primary or secondary: the replica set, "" for the first one
+	Member  string        // Freeze, Kill, Pause, Resume, Restart and Start
+	Seconds int           // Freeze and StepDown: how long the member may not be elected; freezing for 0 unfreezes
+	Seed    *seed.Spec    // Insert
+	Wait    time.Duration // Wait
+	Expect  interface{}   // Assert: fields the previous result must have, or the error it must have failed with
+	MayFail bool          // an error doesn't end the run, e.g. for a following assert to check
+}
+
+// A Scenario is the steps key of a deployment spec, e.g.
+//
+//	steps:
+//	  - command: {insert: orders, documents: [{_id: 1}]}
+//	    db: shop
+//	  - assert: {n: 1}
+//	  - insert: {collection: shop.orders, count: 10000, fields: {qty: {int: [1, 10]}}}
+//	  - stepDown: rs0
+//	  - kill: rs0-1
+//	  - freeze: rs0-2
+//	    seconds: 30
+//	  - wait: 5s
+//	  - command: {insert: orders, documents: [{_id: 1}]}
+//	    db: shop
+//	    mayFail: true
+//	  - assert: {error: DuplicateKey}
+//	  - start: rs0-1
+type Scenario struct {
+	Steps []*Step
+}
+
+// Parse reads the scenario in a deployment spec
+func Parse(in []byte) (*Scenario, error) {
+	root, err := config.ParseYamlDoc(in)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing scenario: %v", err)
+	}
+	items, ok := root.([]config.MapItem)
+	if !ok {
+		return nil, fmt.Errorf("spec must be a mapping")
+	}
+	s := new(Scenario)
+	for _, item := range items {
+		if item.Key != "steps" {
+			continue // the deployment's profiles and members
+		}
+		list, ok := item.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("line %d: steps must be a list", item.Line)
+		}
+		for _, entry := range list {
+			fields, ok := entry.([]config.MapItem)
+			if !ok || len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: each step must be a mapping such as {wait: 5s}", item.Line)
+			}
+			step, err := parseStep(fields)
+			if err != nil {
+				return nil, err
+			}
+			s.Steps = append(s.Steps, step)
+		}
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("spec has no steps")
+	}
+	return s, nil
+}
+
+// seconds a member stepped down or frozen may not be elected, unless a step says otherwise
+const defaultSeconds = 60
+
+// parse a step: its action and value, then options
+func parseStep(fields []config.MapItem) (*Step, error) {
+	first := fields[0]
+	step := &Step{Line: first.Line, Action: first.Key}
+	var err error
+	switch first.Key {
+	case Command:
+		step.DB = "admin"
+		step.Command, _ = seed.ToBSON(first.Value).(bson.D)
+		if len(step.Command) == 0 {
+			err = fmt.Errorf("line %d: command must be a mapping such as {ping: 1}", first.Line)
+		}
+	case Insert:
+		items, ok := first.Value.([]config.MapItem)
+		if !ok {
+			return nil, fmt.Errorf("line %d: insert must be a seed spec", first.Line)
+		}
+		step.Seed, err = seed.ParseSpecItems(items)
+	case StepDown:
+		step.Seconds = defaultSeconds
+		if first.Value != nil {
+			step.ReplSet, err = name(first)
+		}
+	case Freeze:
+		step.Seconds = defaultSeconds
+		step.Member, err = name(first)
+	case Kill, Pause, Resume, Restart, Start:
+		step.Member, err = name(first)
+	case Wait:
+		s, _ := first.Value.(string)
+		step.Wait, err = time.ParseDuration(s)
+		if err != nil || step.Wait < 0 {
+			err = fmt.Errorf("line %d: wait must be a duration such as 5s or 500ms", first.Line)
+		}
+	case Assert:
+		step.Expect = seed.ToBSON(first.Value)
+		if d, ok := step.Expect.(bson.D); !ok || len(d) == 0 {
+			err = fmt.Errorf("line %d: assert must be a mapping such as {n: 1} or {error: NotWritablePrimary}", first.Line)
+		}
+	default:
+		err = fmt.Errorf("line %d: unknown step '%s'", first.Line, first.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range fields[1:] {
+		switch {
+		case opt.Key == "db" && step.Action == Command:
+			step.DB, err = name(opt)
+		case opt.Key == "on" && step.Action == Command:
+			step.On, err = name(opt)
+		case opt.Key == "replSet" && step.Action == Command:
+			step.ReplSet, err = name(opt)
+		case opt.Key == "seconds" && (step.Action == StepDown || step.Action == Freeze):
+			n, ok := opt.Value.(int)
+			if !ok || n < 0 {
+				err = fmt.Errorf("line %d: seconds must be a number of seconds", opt.Line)
+			}
+			step.Seconds = n
+		case opt.Key == "mayFail":
+			var ok bool
+			step.MayFail, ok = opt.Value.(bool)
+			if !ok {
+				err = fmt.Errorf("line %d: mayFail must be true or false", opt.Line)
+			}
+		default:
+			err = fmt.Errorf("line %d: unknown option '%s' for %s", opt.Line, opt.Key, step.Action)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if step.ReplSet != "" && step.On != "primary" && step.On != "secondary" && step.Action == Command {
+		return nil, fmt.Errorf("line %d: replSet is for commands on a primary or secondary", step.Line)
+	}
+	return step, nil
+}
+
+func name(item config.MapItem) (string, error) {
+	s, ok := item.Value.(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("line %d: %s must be a name", item.Line, item.Key)
+	}
+	return s, nil
+}
+
+// String describes the step for a report, e.g. "command {ping: 1} on admin"
+func (s *Step) String() string {
+	switch s.Action {
+	case Command:
+		where := s.On
+		if s.ReplSet != "" {
+			where += " of " + s.ReplSet
+		}
+		desc := fmt.Sprintf("command %s on %s", shortJSON(s.Command, 80), s.DB)
+		if where != "" {
+			desc += " at " + where
+		}
+		return desc
+	case Insert:
+		return fmt.Sprintf("insert %d documents into %s.%s", s.Seed.Count, s.Seed.Database, s.Seed.Collection)
+	case StepDown:
+		if s.ReplSet == "" {
+			return fmt.Sprintf("step down for %ds", s.Seconds)
+		}
+		return fmt.Sprintf("step down %s for %ds", s.ReplSet, s.Seconds)
+	case Freeze:
+		if s.Seconds == 0 {
+			return "unfreeze " + s.Member
+		}
+		return fmt.Sprintf("freeze %s for %ds", s.Member, s.Seconds)
+	case Kill, Pause, Resume, Restart, Start:
+		return s.Action + " " + s.Member
+	case Wait:
+		return "wait " + s.Wait.String()
+	case Assert:
+		return "assert " + shortJSON(s.Expect, 80)
+	}
+	return s.Action
+}
+
+// a value as relaxed extended JSON, cut to at most max characters
+func shortJSON(v interface{}, max int) string {
+	s := fmt.Sprint(v)
+	out, err := bson.MarshalExtJSON(bson.D{{"v", v}}, false, false)
+	if err == nil {
+		s = strings.TrimSuffix(strings.TrimPrefix(string(out), `{"v":`), "}")
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if max > 3 && len(s) > max {
+		s = s[:max-3] + "..."
+	}
+	return s
+}
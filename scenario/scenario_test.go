@@ -0,0 +1,195 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSpec = `
+profiles: [minimal]
+members:
+  rs0-0:
+    replication: {replSetName: rs0}
+steps:
+  - command: {insert: orders, documents: [{_id: 1, qty: 2}]}
+    db: shop
+    on: primary
+  - assert: {n: 1, ok: 1}
+  - insert: {collection: shop.orders, count: 100, fields: {qty: {int: [1, 10]}}}
+  - stepDown: rs0
+  - kill: rs0-1
+  - wait: 1500ms
+  - command: {ping: 1}
+    on: secondary
+    replSet: rs0
+    mayFail: true
+  - assert: {error: NotPrimaryNoSecondaryOk}
+  - start: rs0-1
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+	var got []string
+	for _, step := range s.Steps {
+		got = append(got, step.String())
+	}
+	want := []string{
+		`command {"insert":"orders","documents":[{"_id":1,"qty":2}]} on shop at primary`,
+		`assert {"n":1,"ok":1}`,
+		"insert 100 documents into shop.orders",
+		"step down rs0 for 60s",
+		"kill rs0-1",
+		"wait 1.5s",
+		`command {"ping":1} on admin at secondary of rs0`,
+		`assert {"error":"NotPrimaryNoSecondaryOk"}`,
+		"start rs0-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse(): got steps\n%s\nwanted\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if s.Steps[0].Line != 7 || !s.Steps[6].MayFail || s.Steps[0].MayFail {
+		t.Errorf("Parse(): got %+v and %+v", s.Steps[0], s.Steps[6])
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"profiles: [minimal]\n", "no steps"},
+		{"steps: {wait: 5s}\n", "must be a list"},
+		{"steps:\n  - wait: soon\n", "line 2: wait must be a duration"},
+		{"steps:\n  - reboot: rs0-1\n", "unknown step 'reboot'"},
+		{"steps:\n  - kill: rs0-1\n    db: x\n", "unknown option 'db' for kill"},
+		{"steps:\n  - command: {ping: 1}\n    replSet: rs0\n", "replSet is for commands on a primary or secondary"},
+		{"steps:\n  - assert: 1\n", "assert must be a mapping"},
+		{"steps:\n  - insert: {count: 5}\n", "seed spec has no collection"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.spec))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q): got error %v, wanted %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	result, _ := bson.Marshal(bson.D{{"n", int32(1)}, {"ok", 1.0}, {"cursor", bson.D{{"firstBatch", bson.A{bson.D{{"_id", int64(1)}, {"x", "a"}}}}}}})
+	notPrimary := mongo.CommandError{Code: 10107, Name: "NotWritablePrimary", Message: "not primary"}
+	dup := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+	tests := []struct {
+		name   string
+		expect bson.D
+		result bson.Raw
+		err    error
+		want   string // part of the error, "" for none
+	}{
+		{"fields", bson.D{{"n", 1}, {"ok", 1}}, result, nil, ""},
+		{"nested", bson.D{{"cursor", bson.D{{"firstBatch", bson.A{bson.D{{"_id", 1}}}}}}}, result, nil, ""},
+		{"wrong value", bson.D{{"n", 2}}, result, nil, "n: expected 2, got 1"},
+		{"missing", bson.D{{"cursor", bson.D{{"id", 0}}}}, result, nil, "cursor.id: missing"},
+		{"array length", bson.D{{"cursor", bson.D{{"firstBatch", bson.A{}}}}}, result, nil, "cursor.firstBatch: expected"},
+		{"no result", bson.D{{"n", 1}}, nil, nil, "no result"},
+		{"code", bson.D{{"error", 10107}}, nil, notPrimary, ""},
+		{"code name", bson.D{{"error", "NotWritablePrimary"}}, nil, notPrimary, ""},
+		{"message", bson.D{{"error", "not primary"}}, nil, notPrimary, ""},
+		{"duplicate", bson.D{{"error", "DuplicateKey"}}, nil, dup, ""},
+		{"wrapped", bson.D{{"error", 11000}}, nil, errors.New("wrapped"), "expected error 11000"},
+		{"other error", bson.D{{"error", 11000}}, nil, notPrimary, "expected error 11000, got"},
+		{"no error", bson.D{{"error", 11000}}, result, nil, "expected error 11000, got none"},
+		{"unexpected error", bson.D{{"n", 1}}, nil, notPrimary, "previous step failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.expect, tt.result, tt.err)
+			if (err == nil) != (tt.want == "") || (err != nil && !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("Check(): got %v, wanted %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewReport(&buf, "repro.yaml", "rs")
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	out, _ := bson.Marshal(bson.D{{"ok", 1}})
+	r.Add(1, &Result{Step: &Step{Line: 3, Action: Command, Command: bson.D{{"ping", 1}}, DB: "admin"}, Start: start, Duration: 2 * time.Millisecond, Output: out, Member: "rs0-0"})
+	r.Add(2, &Result{Step: &Step{Line: 5, Action: Kill, Member: "rs0-1", MayFail: true}, Start: start, Err: errors.New("not running")})
+	r.Add(3, &Result{Step: &Step{Line: 6, Action: Assert, Expect: bson.D{{"n", 1}}}, Start: start, Err: errors.New("n: missing")})
+	err := r.Finish()
+	if err == nil || !strings.Contains(err.Error(), "line 6") {
+		t.Errorf("Finish(): got %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for i, want := range []string{
+		"Scenario repro.yaml on deployment rs",
+		`10:00:00.000 step 1   line 3    ok            2ms  command {"ping":1} on admin (rs0-0)`,
+		`result: {"ok":1}`,
+		"step 2   line 5    error          0s  kill rs0-1",
+		"error: not running",
+		"step 3   line 6    FAILED         0s  assert",
+		"error: n: missing",
+		"Scenario finished after 3 steps with 2 errors",
+	} {
+		if i >= len(lines) || !strings.Contains(lines[i], want) {
+			t.Errorf("report line %d: wanted %q in\n%s", i+1, want, buf.String())
+			break
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "faults.jsonl")
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	for _, e := range []*Event{
+		{Time: start, Action: Pause, Member: "rs0-0", Release: "4.4.1"},
+		{Time: start.Add(12500 * time.Millisecond), Action: Resume, Member: "rs0-0"},
+		{Time: start.Add(13 * time.Second), Action: Freeze, Member: "rs0-2", Error: "not running"},
+		{Time: start.Add(20 * time.Second), Action: StepDown, Seconds: 30},
+		{Time: start.Add(20 * time.Second), Action: Freeze, Member: "rs0-1"},
+		{Time: start.Add(90 * time.Second), Action: Kill, Member: "rs0-1"},
+	} {
+		err := RecordEvent(fn, e)
+		if err != nil {
+			t.Fatalf("RecordEvent(): %v", err)
+		}
+	}
+	events, err := ReadEvents(fn)
+	if err != nil || len(events) != 6 || events[0].Release != "4.4.1" || events[2].Error != "not running" {
+		t.Fatalf("ReadEvents(): got %v, %v", events, err)
+	}
+	var replayed []string
+	for _, step := range Replay(events).Steps {
+		replayed = append(replayed, step.String())
+	}
+	want := []string{"pause rs0-0", "wait 12.5s", "resume rs0-0", "wait 7.5s", "step down for 30s", "unfreeze rs0-1",
+		"wait 1m10s", "kill rs0-1"}
+	if !reflect.DeepEqual(replayed, want) {
+		t.Errorf("Replay(): got %v, wanted %v", replayed, want)
+	}
+
+	// the steps written out parse back to the replay
+	s, err := Parse([]byte(FormatEvents(events)))
+	if err != nil {
+		t.Fatalf("Parse(FormatEvents()): %v\n%s", err, FormatEvents(events))
+	}
+	var parsed []string
+	for _, step := range s.Steps {
+		parsed = append(parsed, step.String())
+	}
+	if !reflect.DeepEqual(parsed, replayed) {
+		t.Errorf("FormatEvents(): parsed back to %v, wanted %v\n%s", parsed, replayed, FormatEvents(events))
+	}
+}
@@ -0,0 +1,347 @@
+package seed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/rand"
+	"time"
+)
+
+// A Generator makes the value of a field for the nth document of a collection, from 0
+type Generator interface {
+	Generate(r *rand.Rand, n int64) interface{}
+}
+
+type intGen struct {
+	min, max int64
+	long     bool // int64 rather than int32
+}
+
+func (g *intGen) Generate(r *rand.Rand, n int64) interface{} {
+	v := g.min + r.Int63n(g.max-g.min+1)
+	if g.long {
+		return v
+	}
+	return int32(v)
+}
+
+type doubleGen struct{ min, max float64 }
+
+func (g *doubleGen) Generate(r *rand.Rand, n int64) interface{} {
+	return g.min + r.Float64()*(g.max-g.min)
+}
+
+type stringGen struct{ min, max int }
+
+const letters = "abcdefghijklmnopqrstuvwxyz"
+
+func (g *stringGen) Generate(r *rand.Rand, n int64) interface{} {
+	b := make([]byte, g.min+r.Intn(g.max-g.min+1))
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+type dateGen struct{ from, to time.Time }
+
+func (g *dateGen) Generate(r *rand.Rand, n int64) interface{} {
+	ms := g.to.Sub(g.from).Milliseconds()
+	return g.from.Add(time.Duration(r.Int63n(ms+1)) * time.Millisecond)
+}
+
+type boolGen struct{}
+
+func (g *boolGen) Generate(r *rand.Rand, n int64) interface{} {
+	return r.Intn(2) == 1
+}
+
+// ObjectIds with the start of 2020 as their timestamp, so documents generated with the same seed are the same
+type objectIDGen struct{}
+
+func (g *objectIDGen) Generate(r *rand.Rand, n int64) interface{} {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[0:4], uint32(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()))
+	_, _ = r.Read(id[4:])
+	return id
+}
+
+// sequential numbers from start: unique, in insertion order
+type seqGen struct{ start int64 }
+
+func (g *seqGen) Generate(r *rand.Rand, n int64) interface{} {
+	return g.start + n
+}
+
+type choiceGen struct{ values []interface{} }
+
+func (g *choiceGen) Generate(r *rand.Rand, n int64) interface{} {
+	return g.values[r.Intn(len(g.values))]
+}
+
+type constGen struct{ value interface{} }
+
+func (g *constGen) Generate(r *rand.Rand, n int64) interface{} {
+	return g.value
+}
+
+type arrayGen struct {
+	elem     Generator
+	min, max int
+}
+
+func (g *arrayGen) Generate(r *rand.Rand, n int64) interface{} {
+	a := make(bson.A, g.min+r.Intn(g.max-g.min+1))
+	for i := range a {
+		a[i] = g.elem.Generate(r, n)
+	}
+	return a
+}
+
+type field struct {
+	name string
+	gen  Generator
+}
+
+type docGen struct{ fields []field }
+
+func (g *docGen) Generate(r *rand.Rand, n int64) interface{} {
+	doc := make(bson.D, len(g.fields))
+	for i, f := range g.fields {
+		doc[i] = bson.E{Key: f.name, Value: f.gen.Generate(r, n)}
+	}
+	return doc
+}
+
+// generator names, each of which can be given alone for its defaults or as {name: argument}
+var generatorNames = map[string]bool{
+	"int": true, "long": true, "double": true, "string": true, "date": true, "bool": true, "objectId": true,
+	"seq": true, "choice": true, "const": true, "array": true,
+}
+
+var defaultFrom = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+var defaultTo = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+/*
+parse a field's generator from a spec:
+
+	int, long, double, string, date, bool, objectId or seq	a generator with its defaults
+	{int: [1, 100]}, {long: 1000}, {double: [0, 1]}	numbers in a range, from 0 if only the maximum is given
+	{string: 12}, {string: [4, 20]}	lowercase strings of a length
+	{date: [2020-01-01, 2021-06-30T12:00:00Z]}	dates in a range
+	{seq: 1000}	sequential numbers from 1000
+	{choice: [new, shipped, 3]}	one of the values
+	{const: {any: value}}	always the same value
+	{array: <generator>, length: [0, 5]}	arrays of generated values
+	{street: string, zip: int}	a nested document: any mapping but a generator's, which has one key or is an array's
+	42, true	always this number or bool
+*/
+func parseGenerator(v interface{}, line int) (Generator, error) {
+	switch x := v.(type) {
+	case string:
+		if !generatorNames[x] {
+			return nil, fmt.Errorf("line %d: unknown generator '%s', use {const: %s} for a fixed string", line, x, x)
+		}
+		return parseNamed(x, nil, line)
+	case []config.MapItem:
+		if isNamed(x) {
+			return parseNamedItems(x)
+		}
+		return parseDoc(x)
+	case []interface{}:
+		return nil, fmt.Errorf("line %d: use {choice: [...]} to pick one of several values, or {array: ...} for arrays", line)
+	case nil:
+		return &constGen{}, nil
+	}
+	return &constGen{value: v}, nil
+}
+
+// whether a mapping names a generator, {name: argument} or {array: ..., length: ...}, rather than being a document
+// with fields that happen to be called like generators, e.g. {date: date, total: double}
+func isNamed(items []config.MapItem) bool {
+	switch len(items) {
+	case 1:
+		return generatorNames[items[0].Key]
+	case 2:
+		return items[0].Key == "array" && items[1].Key == "length"
+	}
+	return false
+}
+
+// parse a nested document's fields
+func parseDoc(items []config.MapItem) (*docGen, error) {
+	g := new(docGen)
+	for _, item := range items {
+		fg, err := parseGenerator(item.Value, item.Line)
+		if err != nil {
+			return nil, err
+		}
+		g.fields = append(g.fields, field{name: item.Key, gen: fg})
+	}
+	return g, nil
+}
+
+// parse {name: argument}, or {array: argument, length: range}
+func parseNamedItems(items []config.MapItem) (Generator, error) {
+	name, arg, line := items[0].Key, items[0].Value, items[0].Line
+	length := interface{}([]interface{}{0, 5})
+	if len(items) > 1 {
+		length = items[1].Value
+	}
+	if name == "array" {
+		elem, err := parseGenerator(arg, line)
+		if err != nil {
+			return nil, err
+		}
+		min, max, err := intRange(length, line)
+		if err == nil && min < 0 {
+			err = fmt.Errorf("line %d: array length can't be negative", line)
+		}
+		return &arrayGen{elem: elem, min: int(min), max: int(max)}, err
+	}
+	return parseNamed(name, arg, line)
+}
+
+// make a named generator from its argument, nil for its defaults
+func parseNamed(name string, arg interface{}, line int) (Generator, error) {
+	switch name {
+	case "int", "long":
+		if arg == nil {
+			arg = []interface{}{0, 1000000}
+		}
+		min, max, err := intRange(arg, line)
+		return &intGen{min: min, max: max, long: name == "long"}, err
+	case "double":
+		if arg == nil {
+			arg = []interface{}{0, 1}
+		}
+		min, max, err := floatRange(arg, line)
+		return &doubleGen{min: min, max: max}, err
+	case "string":
+		if arg == nil {
+			arg = 8
+		}
+		if n, ok := arg.(int); ok {
+			arg = []interface{}{n, n} // exactly n long
+		}
+		min, max, err := intRange(arg, line)
+		if err == nil && min < 0 {
+			err = fmt.Errorf("line %d: string length can't be negative", line)
+		}
+		return &stringGen{min: int(min), max: int(max)}, err
+	case "date":
+		if arg == nil {
+			return &dateGen{from: defaultFrom, to: defaultTo}, nil
+		}
+		from, to, err := dateRange(arg, line)
+		return &dateGen{from: from, to: to}, err
+	case "bool":
+		return &boolGen{}, nil
+	case "objectId":
+		return &objectIDGen{}, nil
+	case "seq":
+		start, ok := arg.(int)
+		if arg != nil && !ok {
+			return nil, fmt.Errorf("line %d: seq takes the first number", line)
+		}
+		return &seqGen{start: int64(start)}, nil
+	case "choice":
+		values, ok := arg.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("line %d: choice takes a list of values", line)
+		}
+		g := &choiceGen{}
+		for _, v := range values {
+			g.values = append(g.values, ToBSON(v))
+		}
+		return g, nil
+	case "const":
+		return &constGen{value: ToBSON(arg)}, nil
+	}
+	return nil, fmt.Errorf("line %d: %s needs an element generator", line, name)
+}
+
+// parse max or [min, max]; a single number is the maximum, from 0
+func intRange(v interface{}, line int) (int64, int64, error) {
+	switch x := v.(type) {
+	case int:
+		if x >= 0 {
+			return 0, int64(x), nil
+		}
+	case []interface{}:
+		if len(x) == 2 {
+			lo, ok1 := x[0].(int)
+			hi, ok2 := x[1].(int)
+			if ok1 && ok2 && lo <= hi {
+				return int64(lo), int64(hi), nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("line %d: expected a whole number or [min, max]", line)
+}
+
+func floatRange(v interface{}, line int) (float64, float64, error) {
+	toFloat := func(v interface{}) (float64, bool) {
+		switch x := v.(type) {
+		case int:
+			return float64(x), true
+		case float64:
+			return x, true
+		}
+		return 0, false
+	}
+	if max, ok := toFloat(v); ok {
+		return 0, max, nil
+	}
+	if x, ok := v.([]interface{}); ok && len(x) == 2 {
+		lo, ok1 := toFloat(x[0])
+		hi, ok2 := toFloat(x[1])
+		if ok1 && ok2 && lo <= hi {
+			return lo, hi, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("line %d: expected a number or [min, max]", line)
+}
+
+func dateRange(v interface{}, line int) (time.Time, time.Time, error) {
+	x, ok := v.([]interface{})
+	if ok && len(x) == 2 {
+		from, err1 := parseDate(x[0])
+		to, err2 := parseDate(x[1])
+		if err1 == nil && err2 == nil && !to.Before(from) {
+			return from, to, nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("line %d: expected [from, to], e.g. [2020-01-01, 2020-12-31T23:59:59Z]", line)
+}
+
+func parseDate(v interface{}) (time.Time, error) {
+	s, _ := v.(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+	}
+	return t, err
+}
+
+// ToBSON converts a value read by config.ParseYamlDoc to BSON: mappings become documents, in order, and sequences arrays
+func ToBSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []config.MapItem:
+		doc := make(bson.D, len(x))
+		for i, item := range x {
+			doc[i] = bson.E{Key: item.Key, Value: ToBSON(item.Value)}
+		}
+		return doc
+	case []interface{}:
+		a := make(bson.A, len(x))
+		for i, item := range x {
+			a[i] = ToBSON(item)
+		}
+		return a
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// error code for enableSharding on a database that already has it
+const alreadyInitialized = 23
+
+// Shard turns on sharding for the spec's database and shards its collection on ShardKey, through a mongos
+func (s *Spec) Shard(ctx context.Context, client *mongo.Client) error {
+	admin := client.Database("admin")
+	err := admin.RunCommand(ctx, bson.D{{"enableSharding", s.Database}}).Err()
+	if ce, ok := err.(mongo.CommandError); ok && ce.Code == alreadyInitialized {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("error enabling sharding for %s: %v", s.Database, err)
+	}
+	err = admin.RunCommand(ctx, bson.D{{"shardCollection", s.Database + "." + s.Collection}, {"key", s.ShardKey}}).Err()
+	if err != nil {
+		return fmt.Errorf("error sharding %s.%s: %v", s.Database, s.Collection, err)
+	}
+	return nil
+}
+
+// CreateIndexes creates the spec's indexes, named as the shell names them unless they have a name
+func (s *Spec) CreateIndexes(ctx context.Context, db *mongo.Database) error {
+	if len(s.Indexes) == 0 {
+		return nil
+	}
+	var indexes bson.A
+	for _, ix := range s.Indexes {
+		spec := bson.D{{"key", ix.Keys}}
+		named := false
+		for _, opt := range ix.Options {
+			named = named || opt.Key == "name"
+		}
+		if !named {
+			spec = append(spec, bson.E{Key: "name", Value: indexName(ix.Keys)})
+		}
+		indexes = append(indexes, append(spec, ix.Options...))
+	}
+	err := db.RunCommand(ctx, bson.D{{"createIndexes", s.Collection}, {"indexes", indexes}}).Err()
+	if err != nil {
+		return fmt.Errorf("error creating indexes on %s.%s: %v", s.Database, s.Collection, err)
+	}
+	return nil
+}
+
+// the default name of an index, e.g. "customerId_1_placed_-1"
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// Insert the spec's documents with unordered bulk writes of BatchSize documents, Concurrency at a time
+// Each batch has its own random source derived from seed, so the same seed and batch size always give the same
+// documents. progress, if not nil, is called with the total inserted so far after each bulk write.
+func (s *Spec) Insert(ctx context.Context, coll *mongo.Collection, seed int64, progress func(inserted int64)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	batches := make(chan int64)
+	errs := make(chan error, s.Concurrency)
+	var inserted int64
+	var wg sync.WaitGroup
+	for i := 0; i < s.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batches {
+				end := start + int64(s.BatchSize)
+				if end > s.Count {
+					end = s.Count
+				}
+				r := rand.New(rand.NewSource(seed<<32 ^ start))
+				models := make([]mongo.WriteModel, 0, end-start)
+				for n := start; n < end; n++ {
+					models = append(models, mongo.NewInsertOneModel().SetDocument(s.Document(r, n)))
+				}
+				_, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+				if err != nil {
+					errs <- fmt.Errorf("error inserting documents %d to %d: %v", start, end-1, err)
+					cancel()
+					return
+				}
+				n := atomic.AddInt64(&inserted, end-start)
+				if progress != nil {
+					progress(n)
+				}
+			}
+		}()
+	}
+feed:
+	for start := int64(0); start < s.Count; start += int64(s.BatchSize) {
+		select {
+		case batches <- start:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(batches)
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	return nil
+}
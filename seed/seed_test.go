@@ -0,0 +1,116 @@
+package seed
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSpec = `
+collection: shop.orders
+count: 2500
+batchSize: 500
+shardKey: {customerId: hashed}
+fields:
+  _id: objectId
+  n: {seq: 100}
+  customerId: {int: [1, 50]}
+  status: {choice: [new, shipped, 3]}
+  code: {string: [2, 4]}
+  price: {double: [1, 2]}
+  placed: {date: [2021-03-01, 2021-03-02]}
+  gift: bool
+  version: 2
+  address:
+    zip: long
+    city: {const: Dublin}
+  items: {array: {sku: {string: 6}}, length: [1, 3]}
+  summary: {date: date, total: double}
+indexes:
+  - {customerId: 1, placed: -1}
+  - {keys: {code: 1}, name: by_code, unique: true}
+`
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("ParseSpec(): %v", err)
+	}
+	if spec.Database != "shop" || spec.Collection != "orders" || spec.Count != 2500 || spec.BatchSize != 500 || spec.Concurrency != defaultConcurrency {
+		t.Errorf("ParseSpec(): got %+v", spec)
+	}
+	if !reflect.DeepEqual(spec.ShardKey, bson.D{{"customerId", "hashed"}}) {
+		t.Errorf("ParseSpec(): got shard key %v", spec.ShardKey)
+	}
+	if len(spec.Indexes) != 2 || indexName(spec.Indexes[0].Keys) != "customerId_1_placed_-1" ||
+		!reflect.DeepEqual(spec.Indexes[1].Options, bson.D{{"name", "by_code"}, {"unique", true}}) {
+		t.Errorf("ParseSpec(): got indexes %v", spec.Indexes)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	doc := spec.Document(r, 7)
+	var keys []string
+	for _, e := range doc {
+		keys = append(keys, e.Key)
+	}
+	if got := strings.Join(keys, ","); got != "_id,n,customerId,status,code,price,placed,gift,version,address,items,summary" {
+		t.Errorf("Document(): got fields %s", got)
+	}
+	m := doc.Map()
+	if _, ok := m["_id"].(primitive.ObjectID); !ok {
+		t.Errorf("Document(): _id is %T", m["_id"])
+	}
+	if m["n"] != int64(107) || m["version"] != 2 {
+		t.Errorf("Document(): got n %v, version %v", m["n"], m["version"])
+	}
+	if c := m["customerId"].(int32); c < 1 || c > 50 {
+		t.Errorf("Document(): customerId %d out of range", c)
+	}
+	if s := m["code"].(string); len(s) < 2 || len(s) > 4 {
+		t.Errorf("Document(): code %q has the wrong length", s)
+	}
+	if p := m["placed"].(time.Time); p.Before(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)) || p.After(time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Document(): placed %v out of range", p)
+	}
+	if a := m["address"].(bson.D).Map(); a["city"] != "Dublin" {
+		t.Errorf("Document(): got address %v", a)
+	}
+	if items := m["items"].(bson.A); len(items) < 1 || len(items) > 3 {
+		t.Errorf("Document(): got %d items", len(items))
+	}
+	if sum := m["summary"].(bson.D); len(sum) != 2 || sum[0].Key != "date" || sum[1].Key != "total" {
+		t.Errorf("Document(): got summary %v", sum)
+	}
+
+	again := spec.Document(rand.New(rand.NewSource(1)), 7)
+	if !reflect.DeepEqual(doc, again) {
+		t.Errorf("Document(): not the same with the same seed")
+	}
+}
+
+func TestParseSpec_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{"no count", "collection: a.b\nfields: {x: int}", "no count"},
+		{"unknown key", "collection: a.b\ncount: 1\nsize: 3\nfields: {x: int}", "unknown key 'size'"},
+		{"unknown generator", "collection: a.b\ncount: 1\nfields:\n  x: integer", "line 4: unknown generator 'integer'"},
+		{"bad range", "collection: a.b\ncount: 1\nfields:\n  x: {int: [5, 1]}", "line 4: expected a whole number"},
+		{"list", "collection: a.b\ncount: 1\nfields:\n  x: [1, 2]", "use {choice"},
+		{"bad key", "collection: a.b\ncount: 1\nfields: {x: int}\nshardKey: {x: 2}", "must be 1, -1 or an index type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec([]byte(tt.spec))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseSpec(): got error %v, wanted %s", err, tt.want)
+			}
+		})
+	}
+}
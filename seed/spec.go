@@ -0,0 +1,180 @@
+// Package seed fills collections with synthetic documents described by a small YAML spec
+package seed
+
+import (
+	"fmt"
+	"github.com/SpencerBrown/mongodb-repro/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"math/rand"
+	"strings"
+)
+
+// A Spec describes a collection to fill, e.g.
+//
+//	collection: shop.orders
+//	count: 100000
+//	shardKey: {customerId: hashed}
+//	fields:
+//	  _id: objectId
+//	  customerId: {int: [1, 5000]}
+//	  status: {choice: [new, shipped, delivered]}
+//	  placed: date
+//	  items: {array: {sku: {string: 6}, qty: {int: [1, 10]}}, length: [1, 4]}
+//	indexes:
+//	  - {customerId: 1, placed: -1}
+//	  - {keys: {status: 1}, name: by_status, sparse: true}
+type Spec struct {
+	Database    string
+	Collection  string
+	Count       int64
+	BatchSize   int  // documents per bulk write
+	Concurrency int  // bulk writes in flight at once
+	Drop        bool // drop the collection first
+	ShardKey    bson.D
+	Indexes     []Index
+	fields      *docGen
+}
+
+// An Index to create before inserting
+type Index struct {
+	Keys    bson.D
+	Options bson.D // e.g. unique, sparse, name, expireAfterSeconds
+}
+
+const defaultBatchSize = 1000
+const defaultConcurrency = 4
+
+// ParseSpec reads a seed spec from YAML
+func ParseSpec(in []byte) (*Spec, error) {
+	root, err := config.ParseYamlDoc(in)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing seed spec: %v", err)
+	}
+	items, ok := root.([]config.MapItem)
+	if !ok {
+		return nil, fmt.Errorf("seed spec must be a mapping")
+	}
+	return ParseSpecItems(items)
+}
+
+// ParseSpecItems reads a seed spec from a mapping read by config.ParseYamlDoc, e.g. one within a scenario
+func ParseSpecItems(items []config.MapItem) (*Spec, error) {
+	var err error
+	spec := &Spec{BatchSize: defaultBatchSize, Concurrency: defaultConcurrency}
+	for _, item := range items {
+		switch item.Key {
+		case "collection":
+			ns, _ := item.Value.(string)
+			spec.Database, spec.Collection = "test", ns
+			if i := strings.Index(ns, "."); i >= 0 {
+				spec.Database, spec.Collection = ns[:i], ns[i+1:]
+			}
+			if spec.Database == "" || spec.Collection == "" {
+				return nil, fmt.Errorf("line %d: collection must be a name such as db.collection", item.Line)
+			}
+		case "count":
+			err = positive(item, &spec.Count)
+		case "batchSize":
+			var n int64
+			err = positive(item, &n)
+			spec.BatchSize = int(n)
+		case "concurrency":
+			var n int64
+			err = positive(item, &n)
+			spec.Concurrency = int(n)
+		case "drop":
+			drop, ok := item.Value.(bool)
+			spec.Drop = drop
+			if !ok {
+				err = fmt.Errorf("line %d: drop must be true or false", item.Line)
+			}
+		case "shardKey":
+			spec.ShardKey, err = parseKeys(item.Value, item.Line)
+		case "fields":
+			fields, ok := item.Value.([]config.MapItem)
+			if !ok {
+				return nil, fmt.Errorf("line %d: fields must be a mapping of field names to generators", item.Line)
+			}
+			spec.fields, err = parseDoc(fields)
+		case "indexes":
+			spec.Indexes, err = parseIndexes(item.Value, item.Line)
+		default:
+			err = fmt.Errorf("line %d: unknown key '%s' in seed spec", item.Line, item.Key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case spec.Collection == "":
+		return nil, fmt.Errorf("seed spec has no collection")
+	case spec.Count == 0:
+		return nil, fmt.Errorf("seed spec has no count")
+	case spec.fields == nil:
+		return nil, fmt.Errorf("seed spec has no fields")
+	}
+	return spec, nil
+}
+
+func positive(item config.MapItem, n *int64) error {
+	i, ok := item.Value.(int)
+	if !ok || i <= 0 {
+		return fmt.Errorf("line %d: %s must be a positive number", item.Line, item.Key)
+	}
+	*n = int64(i)
+	return nil
+}
+
+// parse index or shard keys: {field: 1, other: -1}, or a type such as hashed, text or 2dsphere
+func parseKeys(v interface{}, line int) (bson.D, error) {
+	items, ok := v.([]config.MapItem)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("line %d: keys must be a mapping such as {field: 1}", line)
+	}
+	keys := make(bson.D, len(items))
+	for i, item := range items {
+		switch x := item.Value.(type) {
+		case int:
+			if x != 1 && x != -1 {
+				return nil, fmt.Errorf("line %d: key %s must be 1, -1 or an index type", item.Line, item.Key)
+			}
+		case string:
+		default:
+			return nil, fmt.Errorf("line %d: key %s must be 1, -1 or an index type", item.Line, item.Key)
+		}
+		keys[i] = bson.E{Key: item.Key, Value: item.Value}
+	}
+	return keys, nil
+}
+
+// parse a list of indexes, each its keys or {keys: {...}, option: value, ...}
+func parseIndexes(v interface{}, line int) ([]Index, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("line %d: indexes must be a list", line)
+	}
+	var indexes []Index
+	for _, entry := range list {
+		items, _ := entry.([]config.MapItem)
+		if len(items) == 0 || items[0].Key != "keys" {
+			keys, err := parseKeys(entry, line)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, Index{Keys: keys})
+			continue
+		}
+		keys, err := parseKeys(items[0].Value, items[0].Line)
+		if err != nil {
+			return nil, err
+		}
+		opts, _ := ToBSON(items[1:]).(bson.D)
+		indexes = append(indexes, Index{Keys: keys, Options: opts})
+	}
+	return indexes, nil
+}
+
+// Document generates the nth document of the collection
+func (s *Spec) Document(r *rand.Rand, n int64) bson.D {
+	return s.fields.Generate(r, n).(bson.D)
+}
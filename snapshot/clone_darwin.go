@@ -0,0 +1,11 @@
+package snapshot
+
+import (
+	"os/exec"
+)
+
+// clone src to a new file dst sharing its blocks, failing if the filesystem can't
+// cp -c uses clonefile(2), which APFS supports.
+func cloneFile(src string, dst string) error {
+	return exec.Command("cp", "-c", src, dst).Run()
+}
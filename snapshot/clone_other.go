@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package snapshot
+
+import (
+	"errors"
+)
+
+// clone src to a new file dst sharing its blocks; files are always copied on this platform
+func cloneFile(src string, dst string) error {
+	return errors.New("cloning not supported")
+}
@@ -0,0 +1,83 @@
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// copy the directory tree src to dst, which must not exist, leaving out excluded files
+// Regular files are cloned copy-on-write if the filesystem can, otherwise copied; symbolic links are recreated and
+// other files, such as sockets, skipped. Returns the bytes copied, and how many of them were cloned.
+func copyTree(src string, dst string, exclude []string) (int64, int64, error) {
+	var size, cloned int64
+	err := filepath.Walk(src, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if excluded(fn, exclude) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(src, fn)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, fi.Mode().Perm()|0700)
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(fn)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case fi.Mode().IsRegular():
+			wasCloned, err := copyFile(fn, target, fi.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+			if wasCloned {
+				cloned += fi.Size()
+			}
+		}
+		return nil
+	})
+	return size, cloned, err
+}
+
+// copy a file, cloning it if possible, and give the copy mode; reports whether the file was cloned
+func copyFile(src string, dst string, mode os.FileMode) (bool, error) {
+	wasCloned := cloneFile(src, dst) == nil
+	if !wasCloned {
+		_ = os.Remove(dst)
+		err := copyData(src, dst, mode)
+		if err != nil {
+			return false, err
+		}
+	}
+	// modes such as a keyfile's 0600 matter to mongod, and the umask may have changed them
+	return wasCloned, os.Chmod(dst, mode)
+}
+
+func copyData(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
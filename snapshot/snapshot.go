@@ -0,0 +1,190 @@
+// Package snapshot saves copies of a deployment's directories and puts them back, cloning files copy-on-write where
+// the filesystem supports it so that snapshots of large data directories are quick and take little extra space
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Snapshots are directories under the snapshot path:
+	<snapshots>/<name>/snapshot.json	what was saved and where it came from
+	<snapshots>/<name>/<n>/	a copy of the nth directory saved
+A snapshot being saved is built in <name>.partial and renamed when it is complete.
+*/
+
+const manifestFile = "snapshot.json"
+const partialExt = ".partial"
+
+// A Snapshot of a deployment's directories
+type Snapshot struct {
+	Name       string `json:"-"`
+	Path       string `json:"-"` // directory holding the snapshot
+	Deployment string
+	Created    time.Time
+	Size       int64             // bytes saved
+	Cloned     int64             // bytes of Size cloned copy-on-write rather than copied
+	Dirs       []string          // directories saved, in the order of their copies
+	Releases   map[string]string // release each member was running when saved, by member; members not running are absent
+}
+
+// Save copies dirs into a new snapshot called name under root, leaving out excluded files
+// A path in exclude also excludes the files whose names begin with it, such as rotated logs.
+func Save(root string, name string, deployment string, dirs []string, exclude []string, releases map[string]string) (*Snapshot, error) {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") || strings.HasSuffix(name, partialExt) {
+		return nil, fmt.Errorf("invalid snapshot name %q", name)
+	}
+	s := &Snapshot{
+		Name:       name,
+		Path:       filepath.Join(root, name),
+		Deployment: deployment,
+		Created:    time.Now(),
+		Dirs:       dirs,
+		Releases:   releases,
+	}
+	if _, err := os.Stat(s.Path); err == nil {
+		return nil, fmt.Errorf("snapshot %s already exists", name)
+	}
+	partial := s.Path + partialExt
+	_ = os.RemoveAll(partial) // left by a save that failed
+	err := os.MkdirAll(partial, 0777)
+	if err != nil {
+		return nil, fmt.Errorf("error creating snapshot directory: %v", err)
+	}
+	for i, dir := range dirs {
+		size, cloned, err := copyTree(dir, filepath.Join(partial, strconv.Itoa(i)), exclude)
+		if err != nil {
+			_ = os.RemoveAll(partial)
+			return nil, fmt.Errorf("error saving %s: %v", dir, err)
+		}
+		s.Size += size
+		s.Cloned += cloned
+	}
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(partial, manifestFile), out, 0666)
+	}
+	if err == nil {
+		err = os.Rename(partial, s.Path)
+	}
+	if err != nil {
+		_ = os.RemoveAll(partial)
+		return nil, fmt.Errorf("error saving snapshot %s: %v", name, err)
+	}
+	return s, nil
+}
+
+// Open reads the snapshot called name under root
+func Open(root string, name string) (*Snapshot, error) {
+	s := &Snapshot{Name: name, Path: filepath.Join(root, name)}
+	in, err := ioutil.ReadFile(filepath.Join(s.Path, manifestFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot %s not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot %s: %v", name, err)
+	}
+	err = json.Unmarshal(in, s)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing snapshot %s: %v", name, err)
+	}
+	return s, nil
+}
+
+// List the snapshots under root, oldest first
+func List(root string) ([]*Snapshot, error) {
+	files, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, f := range files {
+		if !f.IsDir() || strings.HasSuffix(f.Name(), partialExt) {
+			continue
+		}
+		s, err := Open(root, f.Name())
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.Before(snapshots[j].Created) })
+	return snapshots, nil
+}
+
+// Restore replaces each directory saved with its copy, keeping the excluded files that are there now
+// A directory is moved aside while its copy is put back, and moved back if that fails.
+func (s *Snapshot) Restore(exclude []string) error {
+	for i, dir := range s.Dirs {
+		old := dir + ".before-restore"
+		_ = os.RemoveAll(old)
+		_, err := os.Stat(dir)
+		exists := err == nil
+		if exists {
+			err = os.Rename(dir, old)
+			if err != nil {
+				return fmt.Errorf("error moving %s aside: %v", dir, err)
+			}
+		}
+		_, _, err = copyTree(filepath.Join(s.Path, strconv.Itoa(i)), dir, nil)
+		if err == nil && exists {
+			err = keepExcluded(old, dir, exclude)
+		}
+		if err != nil {
+			if exists {
+				_ = os.RemoveAll(dir)
+				_ = os.Rename(old, dir)
+			}
+			return fmt.Errorf("error restoring %s: %v", dir, err)
+		}
+		_ = os.RemoveAll(old)
+	}
+	return nil
+}
+
+// Delete the snapshot
+func (s *Snapshot) Delete() error {
+	return os.RemoveAll(s.Path)
+}
+
+// move the excluded files in old, a directory moved aside, to the same place in dir
+func keepExcluded(old string, dir string, exclude []string) error {
+	return filepath.Walk(old, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(old, fn)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+		if !excluded(target, exclude) {
+			return nil
+		}
+		err = os.MkdirAll(filepath.Dir(target), 0777)
+		if err != nil {
+			return err
+		}
+		return os.Rename(fn, target)
+	})
+}
+
+func excluded(fn string, exclude []string) bool {
+	for _, e := range exclude {
+		if e != "" && strings.HasPrefix(fn, e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(fn), 0777)
+		if err == nil {
+			err = ioutil.WriteFile(fn, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// every file under dir and its content
+func readFiles(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		content, err := ioutil.ReadFile(fn)
+		rel, _ := filepath.Rel(dir, fn)
+		files[filepath.ToSlash(rel)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSaveRestore(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "snapshots")
+	dep := filepath.Join(tmp, "runtime", "rs")
+	data := filepath.Join(tmp, "elsewhere", "data")
+	writeFiles(t, dep, map[string]string{
+		"rs1.yaml":               "storage: ...",
+		"keyfile":                "secret",
+		"rs1/data/collection.wt": "v1",
+		"rs1/mongod.log":         "log 1",
+		"rs1/mongod.log.2021":    "rotated",
+	})
+	_ = os.Chmod(filepath.Join(dep, "keyfile"), 0600)
+	writeFiles(t, data, map[string]string{"index.wt": "i1"})
+	exclude := []string{filepath.Join(dep, "rs1", "mongod.log")}
+
+	s, err := Save(root, "before", "rs", []string{dep, data}, exclude, map[string]string{"rs1": "4.4.1"})
+	if err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	if s.Size != int64(len("storage: ...secretv1i1")) {
+		t.Errorf("Save(): got size %d", s.Size)
+	}
+	if _, err = Save(root, "before", "rs", []string{dep}, nil, nil); err == nil {
+		t.Errorf("Save(): no error saving a snapshot that exists")
+	}
+	if _, err = Save(root, "../x", "rs", []string{dep}, nil, nil); err == nil {
+		t.Errorf("Save(): no error for an invalid name")
+	}
+
+	// change the deployment, then roll it back
+	writeFiles(t, dep, map[string]string{
+		"rs1/data/collection.wt": "v2",
+		"rs1/data/new.wt":        "new",
+		"rs1/mongod.log":         "log 2",
+	})
+	writeFiles(t, data, map[string]string{"index.wt": "i2"})
+
+	snapshots, err := List(root)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("List(): got %v, %v", snapshots, err)
+	}
+	s = snapshots[0]
+	if s.Name != "before" || s.Deployment != "rs" || !reflect.DeepEqual(s.Releases, map[string]string{"rs1": "4.4.1"}) {
+		t.Errorf("List(): got %+v", s)
+	}
+	err = s.Restore(exclude)
+	if err != nil {
+		t.Fatalf("Restore(): %v", err)
+	}
+	want := map[string]string{
+		"rs1.yaml":               "storage: ...",
+		"keyfile":                "secret",
+		"rs1/data/collection.wt": "v1",
+		"rs1/mongod.log":         "log 2",
+		"rs1/mongod.log.2021":    "rotated",
+	}
+	if got := readFiles(t, dep); !reflect.DeepEqual(got, want) {
+		t.Errorf("Restore(): got %v, wanted %v", got, want)
+	}
+	if got := readFiles(t, data); !reflect.DeepEqual(got, map[string]string{"index.wt": "i1"}) {
+		t.Errorf("Restore(): got %v in the data directory", got)
+	}
+	if fi, err := os.Stat(filepath.Join(dep, "keyfile")); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Restore(): keyfile mode not kept")
+	}
+	if _, err := os.Stat(dep + ".before-restore"); !os.IsNotExist(err) {
+		t.Errorf("Restore(): old directory left behind")
+	}
+
+	err = s.Delete()
+	if err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+	if snapshots, _ = List(root); len(snapshots) != 0 {
+		t.Errorf("Delete(): snapshot still listed")
+	}
+}
@@ -2,6 +2,94 @@
 
 package staticContent
 
+const Ldap_directory_ldif = `# Directory served by the ldap stand-in unless another LDIF file is given
+# Users bind as uid=<name>,ou=users,dc=repro,dc=test; mongod queries as cn=query,dc=repro,dc=test.
+# Groups name their members, so the authz query template is ou=groups,dc=repro,dc=test??one?(member={USER})
+dn: dc=repro,dc=test
+objectClass: domain
+dc: repro
+
+dn: cn=query,dc=repro,dc=test
+objectClass: person
+cn: query
+sn: query
+userPassword: query-password
+
+dn: ou=users,dc=repro,dc=test
+objectClass: organizationalUnit
+ou: users
+
+dn: uid=alice,ou=users,dc=repro,dc=test
+objectClass: inetOrgPerson
+uid: alice
+cn: Alice
+sn: Anderson
+mail: alice@repro.test
+userPassword: alice-password
+
+dn: uid=bob,ou=users,dc=repro,dc=test
+objectClass: inetOrgPerson
+uid: bob
+cn: Bob
+sn: Brown
+mail: bob@repro.test
+userPassword: bob-password
+
+dn: ou=groups,dc=repro,dc=test
+objectClass: organizationalUnit
+ou: groups
+
+dn: cn=dba,ou=groups,dc=repro,dc=test
+objectClass: groupOfNames
+cn: dba
+member: uid=alice,ou=users,dc=repro,dc=test
+
+dn: cn=readers,ou=groups,dc=repro,dc=test
+objectClass: groupOfNames
+cn: readers
+member: uid=alice,ou=users,dc=repro,dc=test
+member: uid=bob,ou=users,dc=repro,dc=test
+`
+
+const Profile_inmemory_yaml = `# In-memory storage engine (MongoDB Enterprise only)
+storage:
+  engine: inMemory
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: null
+`
+
+const Profile_minimal_yaml = `# Smallest footprint, for running several deployments on a laptop
+storage:
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: 0.25
+setParameter:
+  diagnosticDataCollectionEnabled: false
+`
+
+const Profile_prod_like_yaml = `# Settings typical of a production deployment
+storage:
+  wiredTiger:
+    engineConfig:
+      cacheSizeGB: 1
+systemLog:
+  logAppend: true
+  timeStampFormat: iso8601-utc
+security:
+  authorization: enabled
+operationProfiling:
+  mode: slowOp
+`
+
+const Profile_tls_everywhere_yaml = `# TLS required on every connection; certificates are read from the deployment directory
+net:
+  tls:
+    mode: requireTLS
+    certificateKeyFile: ${memberPath}/server.pem
+    CAFile: ${deploymentPath}/ca.pem
+`
+
 const Struct_html = `<html lang="en">
 <head>
     <meta http-equiv="content-type" content="text/html; charset=UTF-8">
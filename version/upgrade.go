@@ -0,0 +1,75 @@
+package version
+
+import (
+	"fmt"
+)
+
+// Release series in order; a deployment can only be upgraded to the next series, or downgraded to the one before
+// From 5.0 on only the major releases are listed: the rapid releases between them, e.g. 6.1, are not upgrade steps.
+var releaseSeries = []string{"2.6", "3.0", "3.2", "3.4", "3.6", "4.0", "4.2", "4.4", "5.0", "6.0", "7.0", "8.0"}
+
+// Series is the release series, e.g. "4.2" for 4.2.9, which is also the featureCompatibilityVersion it sets
+func (r ReleaseType) Series() string {
+	return fmt.Sprintf("%d.%d", r.Version, r.Major)
+}
+
+// HasFCV reports whether the release has a featureCompatibilityVersion, which was added in 3.4
+func (r ReleaseType) HasFCV() bool {
+	return r.AtLeast(3, 4)
+}
+
+// FCVs lists the featureCompatibilityVersions a release can run with: its own series and the one before it, which
+// it keeps after an upgrade until the featureCompatibilityVersion is raised. It is empty before 3.4.
+// A rapid release, e.g. 6.2, can also run with its major release's, e.g. 6.0, 6.1 and 6.2.
+func (r ReleaseType) FCVs() []string {
+	if !r.HasFCV() {
+		return nil
+	}
+	if r.AtLeast(5, 0) && r.Major > 0 {
+		fcvs := []string{fmt.Sprintf("%d.0", r.Version)}
+		if r.Major > 1 {
+			fcvs = append(fcvs, fmt.Sprintf("%d.%d", r.Version, r.Major-1))
+		}
+		return append(fcvs, r.Series())
+	}
+	i := seriesIndex(r.Series())
+	if i <= 0 {
+		return []string{r.Series()}
+	}
+	return []string{releaseSeries[i-1], r.Series()}
+}
+
+// UpgradePath lists the release series a deployment moves through, in order, to get from one release to another
+// The last series is the target's; the others must each be installed and fully rolled out in turn. Releases in the
+// same series are one step apart, and downgrades follow the same series in reverse.
+func UpgradePath(from ReleaseType, to ReleaseType) ([]string, error) {
+	if from.Compare(to) == 0 {
+		return nil, fmt.Errorf("already running %s", to)
+	}
+	i, j := seriesIndex(from.Series()), seriesIndex(to.Series())
+	if i < 0 || j < 0 {
+		return nil, fmt.Errorf("no known path from %s to %s", from, to)
+	}
+	var path []string
+	for i != j {
+		if i < j {
+			i++
+		} else {
+			i--
+		}
+		path = append(path, releaseSeries[i])
+	}
+	if len(path) == 0 {
+		path = append(path, to.Series())
+	}
+	return path, nil
+}
+
+func seriesIndex(series string) int {
+	for i, s := range releaseSeries {
+		if s == series {
+			return i
+		}
+	}
+	return -1
+}
@@ -245,8 +245,8 @@ func (v *Version) Validate() error {
 	if invalid {
 		return fmt.Errorf("%s is not a valid distribution", v.Distro)
 	}
-	if (v.Release.Version < 2) || (v.Release.Version > 4) {
-		return fmt.Errorf("release Version %d must be 2, 3, or 4", v.Release.Version)
+	if (v.Release.Version < 2) || (v.Release.Version > 8) {
+		return fmt.Errorf("release Version %d must be 2 through 8", v.Release.Version)
 	}
 	if (v.Release.Major < 0) || (v.Release.Major > 6) {
 		return fmt.Errorf("major Release %d must be 0 through 6", v.Release.Major)
@@ -256,3 +256,63 @@ func (v *Version) Validate() error {
 	}
 	return nil
 }
+
+// Compare two releases, returning -1 if r is older than o, 0 if they are the same release and +1 if r is newer
+// Edition is ignored. A release with a modifier (e.g. "rc1") is older than the release without one, and modifiers
+// order by their trailing number, so "rc2" < "rc10".
+func (r ReleaseType) Compare(o ReleaseType) int {
+	switch {
+	case r.Version != o.Version:
+		return sign(r.Version - o.Version)
+	case r.Major != o.Major:
+		return sign(r.Major - o.Major)
+	case r.Minor != o.Minor:
+		return sign(r.Minor - o.Minor)
+	case r.Modifier == o.Modifier:
+		return 0
+	case r.Modifier == "":
+		return 1
+	case o.Modifier == "":
+		return -1
+	}
+	rp, rn := splitModifier(r.Modifier)
+	op, on := splitModifier(o.Modifier)
+	if rp != op {
+		return strings.Compare(rp, op)
+	}
+	return sign(rn - on)
+}
+
+// AtLeast reports whether r is in release series x.y or a newer one, e.g. r.AtLeast(4, 2) for 4.2.0-rc1 and later
+// Pre-releases count as their series, since they have its features.
+func (r ReleaseType) AtLeast(version int, major int) bool {
+	return r.Version > version || (r.Version == version && r.Major >= major)
+}
+
+func (r ReleaseType) String() string {
+	s := fmt.Sprintf("%d.%d.%d", r.Version, r.Major, r.Minor)
+	if r.Modifier != "" {
+		s += "-" + r.Modifier
+	}
+	return s
+}
+
+// split a modifier such as "rc12" into its prefix and number
+func splitModifier(m string) (string, int) {
+	i := len(m)
+	for i > 0 && m[i-1] >= '0' && m[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(m[i:])
+	return m[:i], n
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
@@ -1,6 +1,7 @@
 package version
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -272,6 +273,11 @@ func TestVersion_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			"5.0",
+			Version{"x86_64", "linux", "ubuntu2004", ReleaseType{5, 0, 13, "", false}},
+			false,
+		},
 		{
 			"bad Arch",
 			Version{"foobar", "linux", "ubuntu1804", ReleaseType{4, 2, 5, "", true}},
@@ -318,3 +324,87 @@ func TestVersion_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestReleaseType_Compare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"4.2.9", "4.2.9", 0},
+		{"4.2.9", "4.2.10", -1},
+		{"4.4.0", "4.2.10", 1},
+		{"3.6.17", "4.0.0", -1},
+		{"4.4.0-rc1", "4.4.0", -1},
+		{"4.4.0", "4.4.0-rc14", 1},
+		{"4.4.0-rc2", "4.4.0-rc10", -1},
+		{"4.4.0-alpha1", "4.4.0-rc0", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			a, _ := ToRelease(tt.a)
+			b, _ := ToRelease(tt.b)
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("Compare(): %s vs %s got %d, wanted %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := b.Compare(a); got != -tt.want {
+				t.Errorf("Compare(): %s vs %s got %d, wanted %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgradePath(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+		wantErr  bool
+	}{
+		{"4.2.9", "4.2.10", "4.2", false},
+		{"4.2.9", "4.4.1", "4.4", false},
+		{"3.6.17", "4.4.1", "4.0,4.2,4.4", false},
+		{"4.4.1", "5.0.13", "5.0", false},
+		{"4.4.1", "6.0.2", "5.0,6.0", false},
+		{"4.4.1", "4.0.19", "4.2,4.0", false},
+		{"4.4.0-rc1", "4.4.0", "4.4", false},
+		{"4.2.9", "4.2.9", "", true},
+		{"4.2.9", "4.6.0", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"/"+tt.to, func(t *testing.T) {
+			from, _ := ToRelease(tt.from)
+			to, _ := ToRelease(tt.to)
+			got, err := UpgradePath(from, to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpgradePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("UpgradePath(): got %v, wanted %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseType_FCVs(t *testing.T) {
+	tests := []struct {
+		release string
+		want    string
+	}{
+		{"7.0.2", "6.0,7.0"},
+		{"6.0.2", "5.0,6.0"},
+		{"6.1.0", "6.0,6.1"},
+		{"5.2.1", "5.0,5.1,5.2"},
+		{"5.0.13", "4.4,5.0"},
+		{"4.4.1", "4.2,4.4"},
+		{"4.0.19", "3.6,4.0"},
+		{"3.4.24", "3.2,3.4"},
+		{"3.2.22", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			r, _ := ToRelease(tt.release)
+			if got := strings.Join(r.FCVs(), ","); got != tt.want {
+				t.Errorf("FCVs(): got %s, wanted %s", got, tt.want)
+			}
+		})
+	}
+}